@@ -54,6 +54,15 @@ func getCreds() (string, string) {
 	return userName, pass
 }
 
+func runPeriodicJobs() {
+	for {
+		models.DeleteExpiredUsers()
+		models.PurgeTrash()
+		models.AutoCloseTopics()
+		time.Sleep(1 * time.Hour)
+	}
+}
+
 func main() {
 	rand.Seed(time.Now().UnixNano())
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -157,12 +166,23 @@ func main() {
 	mux.HandleFunc("/note", views.NoteHandler)
 
 	mux.HandleFunc("/admin", views.AdminIndexHandler)
+	mux.HandleFunc("/admin/bans", views.AdminBansHandler)
+	mux.HandleFunc("/admin/roles", views.AdminRolesHandler)
+	mux.HandleFunc("/audit", views.AuditLogHandler)
+	mux.HandleFunc("/rules", views.ContentRulesHandler)
+	mux.HandleFunc("/revisions", views.RevisionsHandler)
+	mux.HandleFunc("/trash", views.TrashHandler)
+	mux.HandleFunc("/bulk", views.BulkModerateHandler)
+	mux.HandleFunc("/queue", views.QueueHandler)
 
 	mux.HandleFunc("/pm", views.PrivateMessageHandler)
 	mux.HandleFunc("/pm/new", views.PrivateMessageCreateHandler)
 	mux.HandleFunc("/pm/delete", views.PrivateMessageDeleteHandler)
 
 	mux.HandleFunc("/groups/edit", views.GroupEditHandler)
+	mux.HandleFunc("/groups/bans", views.GroupBansHandler)
+	mux.HandleFunc("/groups/members", views.GroupMembersHandler)
+	mux.HandleFunc("/groups/slowmode", views.GroupSlowModeHandler)
 	mux.HandleFunc("/groups/subscribe", views.GroupSubscribeHandler)
 	mux.HandleFunc("/groups/unsubscribe", views.GroupUnsubscribeHandler)
 	mux.HandleFunc("/groups", views.GroupIndexHandler)
@@ -177,6 +197,9 @@ func main() {
 	mux.HandleFunc("/comments/edit", views.CommentUpdateHandler)
 	mux.HandleFunc("/comments", views.CommentIndexHandler)
 
+	mux.HandleFunc("/reports/new", views.ReportCreateHandler)
+	mux.HandleFunc("/reports", views.ReportsHandler)
+
 	mux.HandleFunc("/signup", views.SignupHandler)
 	mux.HandleFunc("/login", views.LoginHandler)
 	mux.HandleFunc("/logout", views.LogoutHandler)
@@ -188,7 +211,15 @@ func main() {
 	mux.HandleFunc("/users/update", views.UserProfileUpdateHandler)
 	mux.HandleFunc("/users/comments", views.UserCommentsHandler)
 	mux.HandleFunc("/users/topics", views.UserTopicsHandler)
+	mux.HandleFunc("/users/mentions", views.UserMentionsHandler)
 	mux.HandleFunc("/users/groups", views.UserGroupsHandler)
+	mux.HandleFunc("/users/rename", views.UserRenameHandler)
+	mux.HandleFunc("/users/impersonate", views.UserImpersonateHandler)
+	mux.HandleFunc("/users/impersonate/end", views.UserImpersonateEndHandler)
+	mux.HandleFunc("/users/export", views.UserExportHandler)
+	mux.HandleFunc("/users/delete", views.UserDeleteHandler)
+
+	go runPeriodicJobs()
 
 	if *fcgiMode {
 		fcgi.Serve(nil, mux)
@@ -0,0 +1,125 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"time"
+)
+
+// Moderation actions recorded in the audit log.
+const (
+	AuditTopicEdit       string = "topic_edit"
+	AuditTopicDelete     string = "topic_delete"
+	AuditTopicUndelete   string = "topic_undelete"
+	AuditTopicClose      string = "topic_close"
+	AuditTopicReopen     string = "topic_reopen"
+	AuditTopicSticky     string = "topic_sticky"
+	AuditTopicMove       string = "topic_move"
+	AuditTopicSplit      string = "topic_split"
+	AuditTopicMerge      string = "topic_merge"
+	AuditTopicApprove    string = "topic_approve"
+	AuditTopicReject     string = "topic_reject"
+	AuditTopicSpam       string = "topic_spam"
+	AuditTopicNotSpam    string = "topic_not_spam"
+	AuditTopicRevert     string = "topic_revert"
+	AuditTopicRedact     string = "topic_redact"
+	AuditCommentEdit     string = "comment_edit"
+	AuditCommentDelete   string = "comment_delete"
+	AuditCommentUndelete string = "comment_undelete"
+	AuditCommentSticky   string = "comment_sticky"
+	AuditCommentApprove  string = "comment_approve"
+	AuditCommentReject   string = "comment_reject"
+	AuditCommentSpam     string = "comment_spam"
+	AuditCommentNotSpam  string = "comment_not_spam"
+	AuditCommentRevert   string = "comment_revert"
+	AuditCommentRedact   string = "comment_redact"
+	AuditGroupCreate     string = "group_create"
+	AuditGroupEdit       string = "group_edit"
+	AuditGroupRename     string = "group_rename"
+	AuditGroupMods       string = "group_mods"
+	AuditGroupAdmins     string = "group_admins"
+	AuditGroupDelete     string = "group_delete"
+	AuditGroupUndelete   string = "group_undelete"
+	AuditGroupArchive    string = "group_archive"
+	AuditGroupUnarchive  string = "group_unarchive"
+	AuditGroupMerge      string = "group_merge"
+	AuditGroupBan        string = "group_ban"
+	AuditGroupMute       string = "group_mute"
+	AuditGroupLift       string = "group_lift"
+	AuditGroupSlowMode   string = "group_slow_mode"
+	AuditGroupAutoClose  string = "group_autoclose"
+	AuditUserEdit        string = "user_edit"
+	AuditUserBan         string = "user_ban"
+	AuditUserUnban       string = "user_unban"
+	AuditUserTrust       string = "user_trust_level"
+	AuditUserNuke        string = "user_nuke"
+	AuditUserShadowBan   string = "user_shadowban"
+	AuditUserShadowLift  string = "user_shadowban_lift"
+	AuditBan             string = "ban"
+	AuditBanLift         string = "ban_lift"
+	AuditRuleCreate      string = "rule_create"
+	AuditRuleDelete      string = "rule_delete"
+)
+
+// redactedText replaces redacted post text in audit log entries.
+const redactedText = "[redacted]"
+
+type AuditEntry struct {
+	ID          string
+	ActorName   string
+	Action      string
+	TargetType  string
+	TargetID    string
+	GroupName   string
+	Before      string
+	After       string
+	CreatedDate time.Time
+}
+
+// LogAction appends an entry to the audit log. Entries are never deleted, and only changed to erase
+// text redacted from a post's edit history. The group is "" for actions outside groups.
+func LogAction(actorID int64, action string, targetType string, targetID string, groupID string, before string, after string) {
+	var gid interface{}
+	if groupID != "" {
+		gid = groupID
+	}
+	db.Exec(`INSERT INTO auditlog(actorid, action, targettype, targetid, groupid, before_val, after_val, created_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`,
+		actorID, action, targetType, targetID, gid, before, after, time.Now().Unix())
+}
+
+// ReadAuditLog returns audit log entries older than the entry with the given date and ID, newest
+// first, for the group or, if groupID is "", for the whole forum. A lastDate of 0 starts from the
+// newest entry and a limit of 0 returns all of them.
+func ReadAuditLog(groupID string, lastDate int64, lastID string, limit int) []AuditEntry {
+	query := `SELECT auditlog.id, COALESCE(users.username, ''), auditlog.action, auditlog.targettype, auditlog.targetid, COALESCE(groups.name, ''),
+		auditlog.before_val, auditlog.after_val, auditlog.created_date
+		FROM auditlog LEFT JOIN users ON auditlog.actorid=users.id LEFT JOIN groups ON auditlog.groupid=groups.id
+		WHERE 1=1`
+	var args []interface{}
+	if lastDate > 0 {
+		query = query + ` AND (auditlog.created_date < ? OR (auditlog.created_date = ? AND auditlog.id < ?))`
+		args = append(args, lastDate, lastDate, lastID)
+	}
+	if groupID != "" {
+		query = query + ` AND auditlog.groupid=?`
+		args = append(args, groupID)
+	}
+	query = query + ` ORDER BY auditlog.created_date DESC, auditlog.id DESC`
+	if limit > 0 {
+		query = query + ` LIMIT ?`
+		args = append(args, limit)
+	}
+	var entries []AuditEntry
+	rows := db.Query(query+`;`, args...)
+	for rows.Next() {
+		var e AuditEntry
+		var cDate int64
+		rows.Scan(&e.ID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID, &e.GroupName, &e.Before, &e.After, &cDate)
+		e.CreatedDate = time.Unix(cDate, 0)
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"strconv"
+	"time"
+)
+
+// AutoClose holds the settings of a group for closing old topics. A zero value turns a setting off.
+type AutoClose struct {
+	IdleDays    int // Close topics without activity for this many days.
+	MaxComments int // Close topics with this many comments.
+	StaleDays   int // Ask for confirmation before replying to topics without activity for this many days.
+}
+
+func (ac AutoClose) IsValid() bool {
+	return ac.IdleDays >= 0 && ac.MaxComments >= 0 && ac.StaleDays >= 0
+}
+
+// String describes the settings for the audit log.
+func (ac AutoClose) String() string {
+	return "close after days without activity: " + strconv.Itoa(ac.IdleDays) + "\nclose after comments: " + strconv.Itoa(ac.MaxComments) +
+		"\nconfirm replies after days without activity: " + strconv.Itoa(ac.StaleDays)
+}
+
+func ReadAutoClose(groupID string) AutoClose {
+	var ac AutoClose
+	db.QueryRow(`SELECT autoclose_days, autoclose_comments, stale_reply_days FROM groups WHERE id=?;`, groupID).Scan(&ac.IdleDays, &ac.MaxComments, &ac.StaleDays)
+	return ac
+}
+
+func UpdateAutoClose(groupID string, ac AutoClose) {
+	db.Exec(`UPDATE groups SET autoclose_days=?, autoclose_comments=?, stale_reply_days=? WHERE id=?;`, ac.IdleDays, ac.MaxComments, ac.StaleDays, groupID)
+}
+
+// CloseTopic closes the topic. The reason is shown on the topic; it is "" when a moderator closes it.
+func CloseTopic(topicID string, reason string) {
+	db.Exec(`UPDATE topics SET is_closed=1, close_reason=? WHERE id=?;`, reason, topicID)
+}
+
+// ReopenTopic reopens the topic. A reopened topic is not closed for its number of comments again,
+// and the time it was reopened counts as activity.
+func ReopenTopic(topicID string) {
+	db.Exec(`UPDATE topics SET is_closed=0, close_reason='', reopened_date=? WHERE id=?;`, time.Now().Unix(), topicID)
+}
+
+// AutoCloseTopics closes the topics that are past the limits of their group. It is run periodically.
+func AutoCloseTopics() {
+	type group struct {
+		id string
+		ac AutoClose
+	}
+	var groups []group
+	rows := db.Query(`SELECT id, autoclose_days, autoclose_comments FROM groups WHERE autoclose_days>0 OR autoclose_comments>0;`)
+	for rows.Next() {
+		var g group
+		rows.Scan(&g.id, &g.ac.IdleDays, &g.ac.MaxComments)
+		groups = append(groups, g)
+	}
+	for _, g := range groups {
+		if g.ac.IdleDays > 0 {
+			since := time.Now().Add(-time.Duration(g.ac.IdleDays) * 24 * time.Hour).Unix()
+			db.Exec(`UPDATE topics SET is_closed=1, close_reason=? WHERE groupid=? AND is_closed=0 AND is_deleted=0 AND activity_date<? AND reopened_date<?;`,
+				"Closed automatically after "+strconv.Itoa(g.ac.IdleDays)+" days without activity.", g.id, since, since)
+		}
+		if g.ac.MaxComments > 0 {
+			db.Exec(`UPDATE topics SET is_closed=1, close_reason=? WHERE groupid=? AND is_closed=0 AND is_deleted=0 AND num_comments>=? AND reopened_date=0;`,
+				"Closed automatically after "+strconv.Itoa(g.ac.MaxComments)+" comments.", g.id, g.ac.MaxComments)
+		}
+	}
+}
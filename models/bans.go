@@ -0,0 +1,200 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/s-gv/orangeforum/models/db"
+	"net"
+	"strings"
+	"time"
+)
+
+type Ban struct {
+	ID          string
+	UserName    string
+	AdminName   string
+	Reason      string
+	Network     string
+	IsLifted    bool
+	CreatedDate time.Time
+	ExpiryDate  time.Time
+}
+
+// IsPermanent reports whether the ban has no expiry date.
+func (ban Ban) IsPermanent() bool {
+	return ban.ExpiryDate.IsZero()
+}
+
+// IsActive reports whether the ban has not been lifted and has not expired.
+func (ban Ban) IsActive() bool {
+	return !ban.IsLifted && (ban.IsPermanent() || ban.ExpiryDate.After(time.Now()))
+}
+
+// CreateBan bans a user, a network, or both. A zero expiry makes the ban permanent.
+func CreateBan(userID sql.NullInt64, adminID int64, reason string, network string, expiry time.Time) {
+	var expiryDate int64
+	if !expiry.IsZero() {
+		expiryDate = expiry.Unix()
+	}
+	db.Exec(`INSERT INTO bans(userid, adminid, reason, network, created_date, expiry_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		userID, adminID, reason, network, time.Now().Unix(), expiryDate)
+	if userID.Valid {
+		db.Exec(`DELETE FROM sessions WHERE userid=?;`, userID)
+	}
+}
+
+func LiftBan(banID string) {
+	db.Exec(`UPDATE bans SET is_lifted=1 WHERE id=?;`, banID)
+}
+
+func LiftUserBans(userID int64) {
+	db.Exec(`UPDATE bans SET is_lifted=1 WHERE userid=?;`, userID)
+}
+
+const banColumns = `bans.id, COALESCE(users.username, ''), COALESCE(admins.username, ''), bans.reason, bans.network, bans.is_lifted, bans.created_date, bans.expiry_date`
+const banTables = `bans LEFT JOIN users ON bans.userid=users.id LEFT JOIN users admins ON bans.adminid=admins.id`
+
+func scanBan(rows *db.Rows) Ban {
+	var ban Ban
+	var cDate, eDate int64
+	rows.Scan(&ban.ID, &ban.UserName, &ban.AdminName, &ban.Reason, &ban.Network, &ban.IsLifted, &cDate, &eDate)
+	ban.CreatedDate = time.Unix(cDate, 0)
+	if eDate != 0 {
+		ban.ExpiryDate = time.Unix(eDate, 0)
+	}
+	return ban
+}
+
+// ReadBans returns the most recent bans, including lifted and expired ones.
+func ReadBans(limit int) []Ban {
+	var bans []Ban
+	rows := db.Query(`SELECT `+banColumns+` FROM `+banTables+` ORDER BY bans.created_date DESC LIMIT ?;`, limit)
+	for rows.Next() {
+		bans = append(bans, scanBan(rows))
+	}
+	return bans
+}
+
+// ReadActiveUserBan returns the active ban on the user that lasts the longest.
+func ReadActiveUserBan(userID int64) (Ban, error) {
+	var found Ban
+	ok := false
+	rows := db.Query(`SELECT `+banColumns+` FROM `+banTables+` WHERE bans.userid=? AND bans.is_lifted=0 AND (bans.expiry_date=0 OR bans.expiry_date>?);`,
+		userID, time.Now().Unix())
+	for rows.Next() {
+		ban := scanBan(rows)
+		if !ok || ban.IsPermanent() || (!found.IsPermanent() && ban.ExpiryDate.After(found.ExpiryDate)) {
+			found = ban
+			ok = true
+		}
+	}
+	if !ok {
+		return Ban{}, errors.New("User not banned.")
+	}
+	return found, nil
+}
+
+// ReadActiveNetworkBan returns an active ban whose network matches one of the client addresses.
+func ReadActiveNetworkBan(addrs []string) (Ban, error) {
+	var found Ban
+	ok := false
+	rows := db.Query(`SELECT `+banColumns+` FROM `+banTables+` WHERE bans.network != '' AND bans.is_lifted=0 AND (bans.expiry_date=0 OR bans.expiry_date>?);`,
+		time.Now().Unix())
+	for rows.Next() {
+		ban := scanBan(rows)
+		for _, addr := range addrs {
+			if !ok && BanMatchesAddr(ban.Network, addr) {
+				found = ban
+				ok = true
+			}
+		}
+	}
+	if !ok {
+		return Ban{}, errors.New("Network not banned.")
+	}
+	return found, nil
+}
+
+// IsValidBanNetwork reports whether network is an IP address, a CIDR block, or an i2p destination.
+func IsValidBanNetwork(network string) bool {
+	if _, _, err := net.ParseCIDR(network); err == nil {
+		return true
+	}
+	if net.ParseIP(network) != nil {
+		return true
+	}
+	return network != "" && !strings.ContainsAny(network, " \t/:")
+}
+
+// BanMatchesAddr reports whether addr is covered by network. IP addresses are matched against
+// an IP or CIDR block; anything else is compared as an i2p destination.
+func BanMatchesAddr(network string, addr string) bool {
+	if addr == "" || network == "" {
+		return false
+	}
+	if _, ipNet, err := net.ParseCIDR(network); err == nil {
+		ip := net.ParseIP(addr)
+		return ip != nil && ipNet.Contains(ip)
+	}
+	if ip := net.ParseIP(network); ip != nil {
+		return ip.Equal(net.ParseIP(addr))
+	}
+	return strings.TrimSuffix(strings.ToLower(network), ".b32.i2p") == strings.TrimSuffix(strings.ToLower(addr), ".b32.i2p")
+}
+
+// ShadowBanUser hides the new topics and comments of the user from everyone but the user and
+// the moderators who approve posts.
+func ShadowBanUser(userID int64) {
+	db.Exec(`UPDATE users SET is_shadowbanned=1 WHERE id=?;`, userID)
+}
+
+// LiftShadowBan reveals the posts the user made while shadow-banned.
+func LiftShadowBan(userID int64) {
+	var topicIDs []string
+	rows := db.Query(`SELECT DISTINCT topicid FROM comments WHERE userid=? AND is_shadowed=1;`, userID)
+	for rows.Next() {
+		var topicID string
+		rows.Scan(&topicID)
+		topicIDs = append(topicIDs, topicID)
+	}
+	db.Exec(`UPDATE users SET is_shadowbanned=0 WHERE id=?;`, userID)
+	db.Exec(`UPDATE topics SET is_shadowed=0 WHERE userid=?;`, userID)
+	db.Exec(`UPDATE comments SET is_shadowed=0 WHERE userid=?;`, userID)
+	for _, topicID := range topicIDs {
+		RenumberComments(topicID)
+	}
+}
+
+// ReadShadowBannedUsers returns the names of the shadow-banned users.
+func ReadShadowBannedUsers() []string {
+	var userNames []string
+	rows := db.Query(`SELECT username FROM users WHERE is_shadowbanned=1 ORDER BY username;`)
+	for rows.Next() {
+		var userName string
+		rows.Scan(&userName)
+		userNames = append(userNames, userName)
+	}
+	return userNames
+}
+
+// ReadPostedGroupIDs returns the groups the user has started topics or commented in.
+func ReadPostedGroupIDs(userID string) []string {
+	var groupIDs []string
+	rows := db.Query(`SELECT groupid FROM topics WHERE userid=? UNION SELECT topics.groupid FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.userid=?;`, userID, userID)
+	for rows.Next() {
+		var groupID string
+		rows.Scan(&groupID)
+		groupIDs = append(groupIDs, groupID)
+	}
+	return groupIDs
+}
+
+func IsShadowBanned(userID int64) bool {
+	var isShadowBanned bool
+	db.QueryRow(`SELECT is_shadowbanned FROM users WHERE id=?;`, userID).Scan(&isShadowBanned)
+	return isShadowBanned
+}
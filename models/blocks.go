@@ -0,0 +1,28 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"time"
+)
+
+// BlockUser stops blockedID from @mentioning userID.
+func BlockUser(userID int64, blockedID int64) {
+	if IsBlocked(userID, blockedID) {
+		return
+	}
+	db.Exec(`INSERT INTO blocks(userid, blockedid, created_date) VALUES(?, ?, ?);`, userID, blockedID, time.Now().Unix())
+}
+
+func UnblockUser(userID int64, blockedID int64) {
+	db.Exec(`DELETE FROM blocks WHERE userid=? AND blockedid=?;`, userID, blockedID)
+}
+
+// IsBlocked reports whether userID has blocked blockedID.
+func IsBlocked(userID int64, blockedID int64) bool {
+	var tmp string
+	return db.QueryRow(`SELECT id FROM blocks WHERE userid=? AND blockedid=?;`, userID, blockedID).Scan(&tmp) == nil
+}
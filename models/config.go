@@ -7,25 +7,50 @@ package models
 import "github.com/s-gv/orangeforum/models/db"
 
 const (
-	ForumName              string = "forum_name"
-	HeaderMsg              string = "header_msg"
-	LoginMsg               string = "login_msg"
-	SignupMsg              string = "signup_msg"
-	CensoredWords          string = "censored_words"
-	SignupDisabled         string = "signup_disabled"
-	GroupCreationDisabled  string = "group_creation_disabled"
-	ImageUploadEnabled     string = "image_upload_enabled"
-	AllowGroupSubscription string = "allow_group_subscription"
-	AllowTopicSubscription string = "allow_topic_subscription"
-	ReadOnlyMode           string = "read_only"
-	DataDir                string = "data_dir"
-	BodyAppendage          string = "body_appendage"
-	DefaultFromMail        string = "default_from_mail"
-	SMTPHost               string = "smtp_host"
-	SMTPPort               string = "smtp_port"
-	SMTPUser               string = "smtp_user"
-	SMTPPass               string = "smtp_pass"
-	Version                string = "version"
+	ForumName                string = "forum_name"
+	HeaderMsg                string = "header_msg"
+	LoginMsg                 string = "login_msg"
+	SignupMsg                string = "signup_msg"
+	CensoredWords            string = "censored_words"
+	SignupDisabled           string = "signup_disabled"
+	GroupCreationDisabled    string = "group_creation_disabled"
+	ImageUploadEnabled       string = "image_upload_enabled"
+	AllowGroupSubscription   string = "allow_group_subscription"
+	AllowTopicSubscription   string = "allow_topic_subscription"
+	ReadOnlyMode             string = "read_only"
+	DataDir                  string = "data_dir"
+	BodyAppendage            string = "body_appendage"
+	DefaultFromMail          string = "default_from_mail"
+	SMTPHost                 string = "smtp_host"
+	SMTPPort                 string = "smtp_port"
+	SMTPUser                 string = "smtp_user"
+	SMTPPass                 string = "smtp_pass"
+	AccountDeletionPolicy    string = "account_deletion_policy"
+	AccountDeletionGraceDays string = "account_deletion_grace_days"
+	AllowUserNameChange      string = "allow_username_change"
+	UserNameChangeInterval   string = "username_change_interval"
+	UserNameCooldown         string = "username_cooldown"
+	AllowImpersonationWrites string = "allow_impersonation_writes"
+	TrustBasicDays           string = "trust_basic_days"
+	TrustBasicPosts          string = "trust_basic_posts"
+	TrustMemberDays          string = "trust_member_days"
+	TrustMemberPosts         string = "trust_member_posts"
+	NewUserMaxLinks          string = "new_user_max_links"
+	NewUserTopicsPerDay      string = "new_user_topics_per_day"
+	PremodLevel              string = "premod_level"
+	SpamThreshold            string = "spam_threshold"
+	TrashRetentionDays       string = "trash_retention_days"
+	TopicsPerHour            string = "topics_per_hour"
+	CommentsPerHour          string = "comments_per_hour"
+	MessagesPerHour          string = "messages_per_hour"
+	MentionsPerPost          string = "mentions_per_post"
+	MentionsPerHour          string = "mentions_per_hour"
+	Version                  string = "version"
+)
+
+const (
+	AccountDeletionAnonymize  string = "anonymize"
+	AccountDeletionHardDelete string = "delete"
 )
 
 func IsMigrationNeeded() bool {
@@ -64,24 +89,43 @@ func Config(key string) string {
 
 func ConfigAllVals() map[string]interface{} {
 	vals := map[string]interface{}{
-		ForumName:              Config(ForumName),
-		HeaderMsg:              Config(HeaderMsg),
-		LoginMsg:               Config(LoginMsg),
-		SignupMsg:              Config(SignupMsg),
-		CensoredWords:          Config(CensoredWords),
-		SignupDisabled:         Config(SignupDisabled) == "1",
-		GroupCreationDisabled:  Config(GroupCreationDisabled) == "1",
-		ImageUploadEnabled:     Config(ImageUploadEnabled) == "1",
-		AllowGroupSubscription: Config(AllowGroupSubscription) == "1",
-		AllowTopicSubscription: Config(AllowTopicSubscription) == "1",
-		ReadOnlyMode:           Config(ReadOnlyMode) == "1",
-		DataDir:                Config(DataDir),
-		BodyAppendage:          Config(BodyAppendage),
-		DefaultFromMail:        Config(DefaultFromMail),
-		SMTPHost:               Config(SMTPHost),
-		SMTPPort:               Config(SMTPPort),
-		SMTPUser:               Config(SMTPUser),
-		SMTPPass:               Config(SMTPPass),
+		ForumName:                Config(ForumName),
+		HeaderMsg:                Config(HeaderMsg),
+		LoginMsg:                 Config(LoginMsg),
+		SignupMsg:                Config(SignupMsg),
+		SignupDisabled:           Config(SignupDisabled) == "1",
+		GroupCreationDisabled:    Config(GroupCreationDisabled) == "1",
+		ImageUploadEnabled:       Config(ImageUploadEnabled) == "1",
+		AllowGroupSubscription:   Config(AllowGroupSubscription) == "1",
+		AllowTopicSubscription:   Config(AllowTopicSubscription) == "1",
+		ReadOnlyMode:             Config(ReadOnlyMode) == "1",
+		DataDir:                  Config(DataDir),
+		BodyAppendage:            Config(BodyAppendage),
+		DefaultFromMail:          Config(DefaultFromMail),
+		SMTPHost:                 Config(SMTPHost),
+		SMTPPort:                 Config(SMTPPort),
+		SMTPUser:                 Config(SMTPUser),
+		SMTPPass:                 Config(SMTPPass),
+		AccountDeletionPolicy:    Config(AccountDeletionPolicy),
+		AccountDeletionGraceDays: Config(AccountDeletionGraceDays),
+		AllowUserNameChange:      Config(AllowUserNameChange) == "1",
+		UserNameChangeInterval:   Config(UserNameChangeInterval),
+		UserNameCooldown:         Config(UserNameCooldown),
+		AllowImpersonationWrites: Config(AllowImpersonationWrites) == "1",
+		TrustBasicDays:           Config(TrustBasicDays),
+		TrustBasicPosts:          Config(TrustBasicPosts),
+		TrustMemberDays:          Config(TrustMemberDays),
+		TrustMemberPosts:         Config(TrustMemberPosts),
+		NewUserMaxLinks:          Config(NewUserMaxLinks),
+		NewUserTopicsPerDay:      Config(NewUserTopicsPerDay),
+		PremodLevel:              Config(PremodLevel),
+		SpamThreshold:            Config(SpamThreshold),
+		TrashRetentionDays:       Config(TrashRetentionDays),
+		TopicsPerHour:            Config(TopicsPerHour),
+		CommentsPerHour:          Config(CommentsPerHour),
+		MessagesPerHour:          Config(MessagesPerHour),
+		MentionsPerPost:          Config(MentionsPerPost),
+		MentionsPerHour:          Config(MentionsPerHour),
 	}
 	return vals
 }
@@ -27,6 +27,15 @@ type Rows struct {
 }
 
 func Init(driverName string, dataSourceName string) {
+	if driverName == "sqlite3" {
+		// database/sql opens more connections as it needs them, so foreign keys are turned on for
+		// every connection through the data source name rather than with a PRAGMA on one of them.
+		if strings.Contains(dataSourceName, "?") {
+			dataSourceName = dataSourceName + "&_foreign_keys=1"
+		} else {
+			dataSourceName = dataSourceName + "?_foreign_keys=1"
+		}
+	}
 	mydb, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		log.Panicf("[ERROR] Error opening DB: %s\n", err)
@@ -36,7 +45,6 @@ func Init(driverName string, dataSourceName string) {
 	if driverName == "sqlite3" {
 		db.Exec("PRAGMA journal_mode = WAL;")
 		db.Exec("PRAGMA synchronous = FULL;")
-		db.Exec("PRAGMA foreign_keys = ON;")
 	}
 }
 
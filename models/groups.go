@@ -5,6 +5,8 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
 	"github.com/s-gv/orangeforum/models/db"
 	"time"
 )
@@ -60,3 +62,217 @@ func ReadGroupIDByName(name string) string {
 	}
 	return ""
 }
+
+const (
+	GroupBanKindBan  string = "ban"
+	GroupBanKindMute string = "mute"
+)
+
+type GroupBan struct {
+	ID          string
+	UserName    string
+	AdminName   string
+	Kind        string
+	Reason      string
+	CreatedDate time.Time
+	ExpiryDate  time.Time
+}
+
+func (ban GroupBan) IsPermanent() bool {
+	return ban.ExpiryDate.IsZero()
+}
+
+func (ban GroupBan) IsMute() bool {
+	return ban.Kind == GroupBanKindMute
+}
+
+// CreateGroupBan stops a user from posting in a group. A zero expiry makes the ban permanent.
+func CreateGroupBan(groupID string, userID int64, adminID int64, kind string, reason string, expiry time.Time) {
+	var expiryDate int64
+	if !expiry.IsZero() {
+		expiryDate = expiry.Unix()
+	}
+	db.Exec(`INSERT INTO groupbans(groupid, userid, adminid, kind, reason, created_date, expiry_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		groupID, userID, adminID, kind, reason, time.Now().Unix(), expiryDate)
+}
+
+func LiftGroupBan(groupID string, banID string) {
+	db.Exec(`UPDATE groupbans SET is_lifted=1 WHERE id=? AND groupid=?;`, banID, groupID)
+}
+
+func readGroupBans(query string, args ...interface{}) []GroupBan {
+	var bans []GroupBan
+	rows := db.Query(query, args...)
+	for rows.Next() {
+		var ban GroupBan
+		var cDate, eDate int64
+		rows.Scan(&ban.ID, &ban.UserName, &ban.AdminName, &ban.Kind, &ban.Reason, &cDate, &eDate)
+		ban.CreatedDate = time.Unix(cDate, 0)
+		if eDate != 0 {
+			ban.ExpiryDate = time.Unix(eDate, 0)
+		}
+		bans = append(bans, ban)
+	}
+	return bans
+}
+
+// ReadActiveGroupBans returns the bans and mutes in the group that have not expired or been lifted.
+func ReadActiveGroupBans(groupID string) []GroupBan {
+	return readGroupBans(`SELECT groupbans.id, users.username, COALESCE(admins.username, ''), groupbans.kind, groupbans.reason, groupbans.created_date, groupbans.expiry_date
+		FROM groupbans INNER JOIN users ON groupbans.userid=users.id LEFT JOIN users admins ON groupbans.adminid=admins.id
+		WHERE groupbans.groupid=? AND groupbans.is_lifted=0 AND (groupbans.expiry_date=0 OR groupbans.expiry_date>?)
+		ORDER BY groupbans.created_date DESC;`, groupID, time.Now().Unix())
+}
+
+// ReadActiveGroupBan returns the active ban or mute on the user in the group, preferring bans over mutes.
+func ReadActiveGroupBan(groupID string, userID int64) (GroupBan, error) {
+	bans := readGroupBans(`SELECT groupbans.id, users.username, COALESCE(admins.username, ''), groupbans.kind, groupbans.reason, groupbans.created_date, groupbans.expiry_date
+		FROM groupbans INNER JOIN users ON groupbans.userid=users.id LEFT JOIN users admins ON groupbans.adminid=admins.id
+		WHERE groupbans.groupid=? AND groupbans.userid=? AND groupbans.is_lifted=0 AND (groupbans.expiry_date=0 OR groupbans.expiry_date>?)
+		ORDER BY groupbans.created_date DESC;`, groupID, userID, time.Now().Unix())
+	for _, ban := range bans {
+		if !ban.IsMute() {
+			return ban, nil
+		}
+	}
+	if len(bans) > 0 {
+		return bans[0], nil
+	}
+	return GroupBan{}, errors.New("User not banned in group.")
+}
+
+const (
+	GroupMemberStatusMember    string = "member"
+	GroupMemberStatusRequested string = "requested"
+	GroupMemberStatusInvited   string = "invited"
+)
+
+// ReadGroupMemberStatus returns the membership status of the user in the group, or "" if there is none.
+func ReadGroupMemberStatus(groupID string, userID int64) string {
+	var status string
+	db.QueryRow(`SELECT status FROM groupmembers WHERE groupid=? AND userid=?;`, groupID, userID).Scan(&status)
+	return status
+}
+
+func SetGroupMemberStatus(groupID string, userID int64, status string) {
+	if ReadGroupMemberStatus(groupID, userID) != "" {
+		db.Exec(`UPDATE groupmembers SET status=?, created_date=? WHERE groupid=? AND userid=?;`, status, time.Now().Unix(), groupID, userID)
+	} else {
+		db.Exec(`INSERT INTO groupmembers(groupid, userid, status, created_date) VALUES(?, ?, ?, ?);`, groupID, userID, status, time.Now().Unix())
+	}
+}
+
+func DeleteGroupMember(groupID string, userID int64) {
+	db.Exec(`DELETE FROM groupmembers WHERE groupid=? AND userid=?;`, groupID, userID)
+}
+
+func ReadGroupMembers(groupID string, status string) []string {
+	var userNames []string
+	rows := db.Query(`SELECT users.username FROM groupmembers INNER JOIN users ON groupmembers.userid=users.id WHERE groupmembers.groupid=? AND groupmembers.status=? ORDER BY users.username;`, groupID, status)
+	for rows.Next() {
+		var userName string
+		rows.Scan(&userName)
+		userNames = append(userNames, userName)
+	}
+	return userNames
+}
+
+// CanUserViewGroup reports whether the user may see the group and its topics. Private groups are
+// visible only to their members, mods and admins, and to superadmins.
+func CanUserViewGroup(groupID string, userID sql.NullInt64) bool {
+	var isPrivate bool
+	if db.QueryRow(`SELECT is_private FROM groups WHERE id=?;`, groupID).Scan(&isPrivate) != nil {
+		return false
+	}
+	if !isPrivate {
+		return true
+	}
+	if !userID.Valid {
+		return false
+	}
+	cond, args := VisibleGroupsCond(userID)
+	var tmp string
+	return db.QueryRow(`SELECT id FROM groups WHERE id=? AND `+cond+`;`, append([]interface{}{groupID}, args...)...).Scan(&tmp) == nil
+}
+
+// GroupViewersCond is an SQL condition on the users and groups tables that holds when the user
+// may see the group. It is used to filter subscription emails.
+const GroupViewersCond = `(groups.is_private=0 OR users.is_superadmin=1 OR users.id IN (SELECT userid FROM groupmembers WHERE groupid=groups.id AND status='member') OR users.id IN (SELECT userid FROM mods WHERE groupid=groups.id) OR users.id IN (SELECT userid FROM admins WHERE groupid=groups.id))`
+
+// VisibleGroupsCond returns an SQL condition on the groups table, and its arguments, that holds
+// for the groups the user may see.
+func VisibleGroupsCond(userID sql.NullInt64) (string, []interface{}) {
+	uid := userID.Int64
+	if !userID.Valid {
+		uid = 0
+	}
+	return `(groups.is_private=0 OR groups.id IN (SELECT groupid FROM groupmembers WHERE userid=? AND status='member') OR groups.id IN (SELECT groupid FROM mods WHERE userid=?) OR groups.id IN (SELECT groupid FROM admins WHERE userid=?) OR EXISTS (SELECT 1 FROM users WHERE id=? AND is_superadmin=1))`,
+		[]interface{}{uid, uid, uid, uid}
+}
+
+// Group posting policies. In announcement groups only users who can post announcements start
+// topics, but everyone can reply. Read-only groups accept no new topics, comments or edits.
+const (
+	GroupPolicyOpen         string = "open"
+	GroupPolicyAnnouncement string = "announce"
+	GroupPolicyReadOnly     string = "readonly"
+)
+
+func IsValidGroupPolicy(policy string) bool {
+	return policy == GroupPolicyOpen || policy == GroupPolicyAnnouncement || policy == GroupPolicyReadOnly
+}
+
+// ReadGroupPolicy returns the posting policy of the group. Archived groups are always read-only.
+func ReadGroupPolicy(groupID string) string {
+	policy := GroupPolicyOpen
+	isArchived := false
+	db.QueryRow(`SELECT post_policy, is_archived FROM groups WHERE id=?;`, groupID).Scan(&policy, &isArchived)
+	if isArchived {
+		return GroupPolicyReadOnly
+	}
+	return policy
+}
+
+// RenameGroup changes the group name and remembers the old one so that old links keep working.
+func RenameGroup(groupID string, newName string) {
+	var oldName string
+	if db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&oldName) != nil || oldName == newName {
+		return
+	}
+	db.Exec(`INSERT INTO groupnamehistory(groupid, name, created_date) VALUES(?, ?, ?);`, groupID, oldName, time.Now().Unix())
+	db.Exec(`UPDATE groups SET name=?, updated_date=? WHERE id=?;`, newName, time.Now().Unix(), groupID)
+}
+
+// ReadGroupNameByOldName returns the current name of the group that last used oldName.
+func ReadGroupNameByOldName(oldName string) (string, error) {
+	r := db.QueryRow(`SELECT groups.name FROM groupnamehistory INNER JOIN groups ON groupnamehistory.groupid=groups.id WHERE groupnamehistory.name=? ORDER BY groupnamehistory.created_date DESC, groupnamehistory.id DESC LIMIT 1;`, oldName)
+	var name string
+	if err := r.Scan(&name); err == nil {
+		return name, nil
+	}
+	return "", errors.New("Group not found.")
+}
+
+// MergeGroups moves the topics, subscribers, mods, admins, members and bans of the source group
+// into the target group and deletes the source group. Links to the source group go to the target.
+func MergeGroups(sourceID string, targetID string) {
+	var sourceName string
+	if db.QueryRow(`SELECT name FROM groups WHERE id=?;`, sourceID).Scan(&sourceName) != nil {
+		return
+	}
+	now := time.Now().Unix()
+	db.Exec(`UPDATE topics SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	for _, table := range []string{"groupsubscriptions", "mods", "admins", "groupmembers"} {
+		db.Exec(`UPDATE `+table+` SET groupid=? WHERE groupid=? AND userid NOT IN (SELECT userid FROM `+table+` WHERE groupid=?);`, targetID, sourceID, targetID)
+	}
+	db.Exec(`UPDATE groupbans SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	db.Exec(`UPDATE userroles SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	db.Exec(`UPDATE groupnamehistory SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	db.Exec(`UPDATE contentrules SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	db.Exec(`UPDATE reports SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	db.Exec(`UPDATE auditlog SET groupid=? WHERE groupid=?;`, targetID, sourceID)
+	InvalidateContentRules()
+	db.Exec(`INSERT INTO groupnamehistory(groupid, name, created_date) VALUES(?, ?, ?);`, targetID, sourceName, now)
+	db.Exec(`DELETE FROM groups WHERE id=?;`, sourceID)
+	db.Exec(`UPDATE groups SET updated_date=? WHERE id=?;`, now, targetID)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models/db"
+	"regexp"
+	"time"
+)
+
+// Mention is a topic, comment, or private message in which a user was mentioned with @username.
+// Messages have no topic or group.
+type Mention struct {
+	ID          string
+	AuthorName  string
+	TargetType  string
+	TargetID    string
+	TopicID     string
+	TopicTitle  string
+	GroupID     string
+	IsRead      bool
+	CreatedDate time.Time
+}
+
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@/])@(\w+)`)
+var mentionCodeRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// ParseMentions returns the usernames mentioned in the text, each once. Mentions in code are ignored.
+func ParseMentions(content string) []string {
+	content = mentionCodeRe.ReplaceAllString(content, " ")
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range mentionRe.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// CountRecentMentions returns the number of mentions the author made in the last hour.
+func CountRecentMentions(authorID int64) int {
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM mentions WHERE authorid=? AND created_date>?;`, authorID, time.Now().Add(-time.Hour).Unix()).Scan(&n)
+	return n
+}
+
+// CreateMentions records the users the author mentioned in a topic, comment, or message, and
+// returns the IDs of the users mentioned in it for the first time. The author, users who blocked
+// the author, users who cannot see the group of the post, and anyone but the recipient of a message
+// are skipped.
+func CreateMentions(authorID int64, targetType string, targetID string, userNames []string) []int64 {
+	var userIDs []int64
+	for _, userName := range userNames {
+		var userID int64
+		var err error
+		if targetType == "message" {
+			err = db.QueryRow(`SELECT users.id FROM users INNER JOIN messages ON messages.toid=users.id WHERE users.username=? AND messages.id=?;`, userName, targetID).Scan(&userID)
+		} else {
+			query := `SELECT users.id FROM users INNER JOIN topics ON topics.id=? INNER JOIN groups ON groups.id=topics.groupid WHERE users.username=? AND ` + GroupViewersCond + `;`
+			topicID := targetID
+			if targetType == "comment" {
+				db.QueryRow(`SELECT topicid FROM comments WHERE id=?;`, targetID).Scan(&topicID)
+			}
+			err = db.QueryRow(query, topicID, userName).Scan(&userID)
+		}
+		if err != nil || userID == authorID || IsBlocked(userID, authorID) {
+			continue
+		}
+		var tmp string
+		if db.QueryRow(`SELECT id FROM mentions WHERE userid=? AND targettype=? AND targetid=?;`, userID, targetType, targetID).Scan(&tmp) == nil {
+			continue
+		}
+		db.Exec(`INSERT INTO mentions(userid, authorid, targettype, targetid, created_date) VALUES(?, ?, ?, ?, ?);`,
+			userID, authorID, targetType, targetID, time.Now().Unix())
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// ReadMentions returns up to limit mentions of the user older than the mention with the given date
+// and ID, newest first. A lastDate of 0 starts from the newest mention. Mentions in posts that are
+// deleted, pending approval, or shadowed, or in groups the user cannot see, are left out.
+func ReadMentions(userID int64, lastDate int64, lastID string, limit int) []Mention {
+	cond, condArgs := VisibleGroupsCond(sql.NullInt64{Int64: userID, Valid: true})
+	query := `SELECT mentions.id, users.username, mentions.targettype, mentions.targetid, mentions.is_read, mentions.created_date, topics.id, topics.title, topics.groupid
+		FROM mentions INNER JOIN users ON mentions.authorid=users.id
+		LEFT JOIN comments ON mentions.targettype='comment' AND comments.id=mentions.targetid
+		LEFT JOIN topics ON (mentions.targettype='topic' AND topics.id=mentions.targetid) OR (mentions.targettype='comment' AND topics.id=comments.topicid)
+		LEFT JOIN groups ON groups.id=topics.groupid
+		LEFT JOIN messages ON mentions.targettype='message' AND messages.id=mentions.targetid
+		WHERE mentions.userid=? AND (messages.id IS NOT NULL OR (topics.is_deleted=0 AND topics.is_pending=0 AND topics.is_shadowed=0 AND
+			(mentions.targettype='topic' OR (comments.is_deleted=0 AND comments.is_pending=0 AND comments.is_shadowed=0)) AND ` + cond + `))`
+	args := append([]interface{}{userID}, condArgs...)
+	if lastDate > 0 {
+		query = query + ` AND (mentions.created_date < ? OR (mentions.created_date = ? AND mentions.id < ?))`
+		args = append(args, lastDate, lastDate, lastID)
+	}
+	query = query + ` ORDER BY mentions.created_date DESC, mentions.id DESC LIMIT ?;`
+	args = append(args, limit)
+	var mentions []Mention
+	rows := db.Query(query, args...)
+	for rows.Next() {
+		var m Mention
+		var topicID, topicTitle, groupID sql.NullString
+		var cDate int64
+		rows.Scan(&m.ID, &m.AuthorName, &m.TargetType, &m.TargetID, &m.IsRead, &cDate, &topicID, &topicTitle, &groupID)
+		m.TopicID, m.TopicTitle, m.GroupID = topicID.String, topicTitle.String, groupID.String
+		m.CreatedDate = time.Unix(cDate, 0)
+		mentions = append(mentions, m)
+	}
+	return mentions
+}
+
+// HasUnreadMentions reports whether the user has been mentioned since they last read their mentions.
+func HasUnreadMentions(userID int64) bool {
+	var tmp string
+	return db.QueryRow(`SELECT id FROM mentions WHERE userid=? AND is_read=0 LIMIT 1;`, userID).Scan(&tmp) == nil
+}
+
+func MarkMentionsRead(userID int64) {
+	db.Exec(`UPDATE mentions SET is_read=1 WHERE userid=? AND is_read=0;`, userID)
+}
+
+// deleteOrphanMentions removes the mentions in topics, comments, and messages that no longer exist.
+func deleteOrphanMentions() {
+	db.Exec(`DELETE FROM mentions WHERE (targettype='topic' AND targetid NOT IN (SELECT id FROM topics)) OR (targettype='comment' AND targetid NOT IN (SELECT id FROM comments)) OR (targettype='message' AND targetid NOT IN (SELECT id FROM messages));`)
+}
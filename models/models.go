@@ -7,9 +7,12 @@ package models
 import (
 	"github.com/s-gv/orangeforum/models/db"
 	"log"
+	"regexp"
+	"strings"
+	"time"
 )
 
-const ModelVersion = 4
+const ModelVersion = 28
 
 func Migration1() {
 	db.Exec(`CREATE TABLE configs(name VARCHAR(250), val TEXT);`)
@@ -28,10 +31,16 @@ func Migration1() {
 		       		updated_date INTEGER,
 		       		reset_token_date INTEGER DEFAULT 0
 	);`)
+	// db.Exec(`ALTER TABLE users ADD COLUMN delete_date INTEGER DEFAULT 0;`) // Migration 5
+	// db.Exec(`ALTER TABLE users ADD COLUMN trust_level INTEGER DEFAULT -1;`) // Migration 15
+	// db.Exec(`ALTER TABLE users ADD COLUMN is_shadowbanned INTEGER DEFAULT 0;`) // Migration 23
+	// db.Exec(`ALTER TABLE users ADD COLUMN mention_emails INTEGER DEFAULT 1;`) // Migration 26
+	// db.Exec(`ALTER TABLE users ADD COLUMN thread_view INTEGER DEFAULT -1;`) // Migration 27
 	db.Exec(`CREATE UNIQUE INDEX users_username_index on users(username);`)
 	db.Exec(`CREATE INDEX users_email_index on users(email);`)
 	db.Exec(`CREATE INDEX users_reset_token_index on users(reset_token);`)
 	db.Exec(`CREATE INDEX users_created_index on users(created_date);`)
+	// db.Exec(`CREATE INDEX users_delete_date_index on users(delete_date);`) // Migration 5
 
 	db.Exec(`CREATE TABLE groups(
 				id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -44,6 +53,15 @@ func Migration1() {
 		       		updated_date INTEGER
 	);`)
 	// db.Exec(`ALTER TABLE groups ADD COLUMN is_private INTEGER DEFAULT 0;`) // Migration 2.
+	// db.Exec(`ALTER TABLE groups ADD COLUMN post_policy VARCHAR(16) DEFAULT 'open';`) // Migration 12
+	// db.Exec(`ALTER TABLE groups ADD COLUMN is_archived INTEGER DEFAULT 0;`) // Migration 14
+	// db.Exec(`ALTER TABLE groups ADD COLUMN premod_level INTEGER DEFAULT 0;`) // Migration 18
+	// db.Exec(`ALTER TABLE groups ADD COLUMN deleted_date INTEGER DEFAULT 0;`) // Migration 22
+	// db.Exec(`ALTER TABLE groups ADD COLUMN slow_mode_secs INTEGER DEFAULT 0;`) // Migration 24
+	// db.Exec(`ALTER TABLE groups ADD COLUMN autoclose_days INTEGER DEFAULT 0;`) // Migration 25
+	// db.Exec(`ALTER TABLE groups ADD COLUMN autoclose_comments INTEGER DEFAULT 0;`) // Migration 25
+	// db.Exec(`ALTER TABLE groups ADD COLUMN stale_reply_days INTEGER DEFAULT 0;`) // Migration 25
+	// db.Exec(`ALTER TABLE groups ADD COLUMN is_threaded INTEGER DEFAULT 0;`) // Migration 27
 	db.Exec(`CREATE INDEX groups_sticky_index on groups(is_sticky);`)
 	db.Exec(`CREATE INDEX groups_closed_sticky_index on groups(is_closed, is_sticky DESC);`)
 	db.Exec(`CREATE UNIQUE INDEX groups_name_index on groups(name);`)
@@ -64,6 +82,13 @@ func Migration1() {
 				updated_date INTEGER
 	);`)
 	// db.Exec(`ALTER TABLE topics ADD COLUMN activity_date INTEGER;`) // Migration 2. Default value set to created_date
+	// db.Exec(`ALTER TABLE topics ADD COLUMN is_pending INTEGER DEFAULT 0;`) // Migration 18
+	// db.Exec(`ALTER TABLE topics ADD COLUMN spam_score INTEGER DEFAULT 0;`) // Migration 19
+	// db.Exec(`ALTER TABLE topics ADD COLUMN edited_date INTEGER DEFAULT 0;`) // Migration 21
+	// db.Exec(`ALTER TABLE topics ADD COLUMN deleted_date INTEGER DEFAULT 0;`) // Migration 22
+	// db.Exec(`ALTER TABLE topics ADD COLUMN is_shadowed INTEGER DEFAULT 0;`) // Migration 23
+	// db.Exec(`ALTER TABLE topics ADD COLUMN close_reason VARCHAR(250) DEFAULT '';`) // Migration 25
+	// db.Exec(`ALTER TABLE topics ADD COLUMN reopened_date INTEGER DEFAULT 0;`) // Migration 25
 	db.Exec(`CREATE INDEX topics_userid_created_index on topics(userid, created_date);`)
 	db.Exec(`CREATE INDEX topics_groupid_sticky_created_index on topics(groupid, is_sticky DESC, created_date DESC);`)
 	db.Exec(`CREATE INDEX topics_created_index on topics(created_date);`)
@@ -84,6 +109,11 @@ func Migration1() {
 	);`)
 	// db.Exec(`ALTER TABLES comments DROP COLUMN is_sticky;`) // Migration 3
 	// db.Exec(`ALTER TABLE comments ADD COLUMN pos INTEGER DEFAULT 0;`) // Migration 3
+	// db.Exec(`ALTER TABLE comments ADD COLUMN is_pending INTEGER DEFAULT 0;`) // Migration 18
+	// db.Exec(`ALTER TABLE comments ADD COLUMN spam_score INTEGER DEFAULT 0;`) // Migration 19
+	// db.Exec(`ALTER TABLE comments ADD COLUMN edited_date INTEGER DEFAULT 0;`) // Migration 21
+	// db.Exec(`ALTER TABLE comments ADD COLUMN deleted_date INTEGER DEFAULT 0;`) // Migration 22
+	// db.Exec(`ALTER TABLE comments ADD COLUMN is_shadowed INTEGER DEFAULT 0;`) // Migration 23
 	db.Exec(`CREATE INDEX comments_userid_created_index on comments(userid, created_date);`)
 	db.Exec(`CREATE INDEX comments_parentid_index on comments(parentid);`)
 	db.Exec(`CREATE INDEX comments_topicid_sticky_created_index on comments(topicid, is_sticky DESC, created_date);`)
@@ -151,6 +181,8 @@ func Migration1() {
 				created_date INTEGER NOT NULL,
 				updated_date INTEGER NOT NULL
 	);`)
+	// db.Exec(`ALTER TABLE sessions ADD COLUMN impersonatorid INTEGER REFERENCES users(id) ON DELETE CASCADE;`) // Migration 7
+	// db.Exec(`ALTER TABLE sessions ADD COLUMN impersonate_date INTEGER DEFAULT 0;`) // Migration 7
 	db.Exec(`CREATE INDEX sessions_sessionid_index on sessions(sessionid);`)
 	db.Exec(`CREATE INDEX sessions_userid_index on sessions(userid);`)
 
@@ -206,6 +238,333 @@ func Migration4() {
 	db.Exec(`CREATE INDEX messages_toid_isread_index on messages(toid, is_read);`)
 }
 
+func Migration5() {
+	db.Exec(`ALTER TABLE users ADD COLUMN delete_date INTEGER DEFAULT 0;`)
+	db.Exec(`CREATE INDEX users_delete_date_index on users(delete_date);`)
+}
+
+func Migration6() {
+	db.Exec(`CREATE TABLE usernamehistory(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				username VARCHAR(32) NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX usernamehistory_userid_created_index on usernamehistory(userid, created_date DESC);`)
+	db.Exec(`CREATE INDEX usernamehistory_username_created_index on usernamehistory(username, created_date DESC);`)
+}
+
+func Migration7() {
+	db.Exec(`ALTER TABLE sessions ADD COLUMN impersonatorid INTEGER REFERENCES users(id) ON DELETE CASCADE;`)
+	db.Exec(`ALTER TABLE sessions ADD COLUMN impersonate_date INTEGER DEFAULT 0;`)
+
+	db.Exec(`CREATE TABLE impersonations(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				adminid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				action VARCHAR(16) NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX impersonations_created_index on impersonations(created_date DESC);`)
+}
+
+func Migration8() {
+	db.Exec(`CREATE TABLE bans(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				adminid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				reason TEXT DEFAULT '',
+				network VARCHAR(250) DEFAULT '',
+				is_lifted INTEGER DEFAULT 0,
+				created_date INTEGER NOT NULL,
+				expiry_date INTEGER DEFAULT 0
+	);`)
+	db.Exec(`CREATE INDEX bans_userid_index on bans(userid);`)
+	db.Exec(`CREATE INDEX bans_network_index on bans(network);`)
+	db.Exec(`CREATE INDEX bans_created_index on bans(created_date DESC);`)
+
+	// users.is_banned is superseded by the bans table.
+	db.Exec(`INSERT INTO bans(userid, created_date) SELECT id, updated_date FROM users WHERE is_banned=1 AND username!=?;`, DeletedUserName)
+}
+
+func Migration9() {
+	db.Exec(`CREATE TABLE groupbans(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				adminid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				kind VARCHAR(16) NOT NULL,
+				reason TEXT DEFAULT '',
+				is_lifted INTEGER DEFAULT 0,
+				created_date INTEGER NOT NULL,
+				expiry_date INTEGER DEFAULT 0
+	);`)
+	db.Exec(`CREATE INDEX groupbans_groupid_userid_index on groupbans(groupid, userid);`)
+	db.Exec(`CREATE INDEX groupbans_groupid_created_index on groupbans(groupid, created_date DESC);`)
+}
+
+func Migration10() {
+	db.Exec(`CREATE TABLE groupmembers(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				status VARCHAR(16) NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX groupmembers_groupid_userid_index on groupmembers(groupid, userid);`)
+	db.Exec(`CREATE INDEX groupmembers_userid_index on groupmembers(userid);`)
+}
+
+func Migration11() {
+	db.Exec(`CREATE TABLE roles(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(32) NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX roles_name_index on roles(name);`)
+
+	db.Exec(`CREATE TABLE rolecapabilities(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				roleid INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+				capability VARCHAR(32) NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX rolecapabilities_roleid_capability_index on rolecapabilities(roleid, capability);`)
+
+	db.Exec(`CREATE TABLE userroles(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				roleid INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX userroles_userid_index on userroles(userid);`)
+
+	// Superadmins, and the admins and mods of groups, keep their roles through users.is_superadmin and the admins and mods tables.
+	seedRoles()
+}
+
+func Migration12() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN post_policy VARCHAR(16) DEFAULT 'open';`)
+
+	for _, name := range []string{RoleMod, RoleAdmin, RoleSuperAdmin} {
+		GrantCapability(ReadRoleIDByName(name), CapPostAnnouncement)
+	}
+}
+
+func Migration13() {
+	db.Exec(`CREATE TABLE topicredirects(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				oldtopicid INTEGER NOT NULL,
+				newtopicid INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX topicredirects_oldtopicid_index on topicredirects(oldtopicid);`)
+	db.Exec(`CREATE INDEX topicredirects_newtopicid_index on topicredirects(newtopicid);`)
+
+	for _, name := range []string{RoleMod, RoleAdmin, RoleSuperAdmin} {
+		GrantCapability(ReadRoleIDByName(name), CapMoveTopics)
+	}
+}
+
+func Migration14() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN is_archived INTEGER DEFAULT 0;`)
+
+	db.Exec(`CREATE TABLE groupnamehistory(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				name VARCHAR(200) NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX groupnamehistory_name_created_index on groupnamehistory(name, created_date DESC);`)
+	db.Exec(`CREATE INDEX groupnamehistory_groupid_index on groupnamehistory(groupid);`)
+}
+
+func Migration15() {
+	db.Exec(`ALTER TABLE users ADD COLUMN trust_level INTEGER DEFAULT -1;`)
+}
+
+func Migration16() {
+	db.Exec(`CREATE TABLE reports(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				reporterid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				targettype VARCHAR(16) NOT NULL,
+				targetid INTEGER NOT NULL,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				category VARCHAR(32) NOT NULL,
+				reason TEXT DEFAULT '',
+				status VARCHAR(16) NOT NULL,
+				claimerid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				resolution TEXT DEFAULT '',
+				created_date INTEGER NOT NULL,
+				resolved_date INTEGER DEFAULT 0
+	);`)
+	db.Exec(`CREATE INDEX reports_groupid_status_index on reports(groupid, status);`)
+	db.Exec(`CREATE INDEX reports_target_index on reports(targettype, targetid);`)
+	db.Exec(`CREATE INDEX reports_created_index on reports(created_date DESC);`)
+
+	for _, name := range []string{RoleMod, RoleAdmin, RoleSuperAdmin} {
+		GrantCapability(ReadRoleIDByName(name), CapHandleReports)
+	}
+}
+
+func Migration17() {
+	db.Exec(`CREATE TABLE auditlog(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				actorid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				action VARCHAR(32) NOT NULL,
+				targettype VARCHAR(16) NOT NULL,
+				targetid INTEGER NOT NULL,
+				groupid INTEGER REFERENCES groups(id) ON DELETE SET NULL,
+				before_val TEXT DEFAULT '',
+				after_val TEXT DEFAULT '',
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX auditlog_created_index on auditlog(created_date DESC);`)
+	db.Exec(`CREATE INDEX auditlog_groupid_created_index on auditlog(groupid, created_date DESC);`)
+}
+
+func Migration18() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN premod_level INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE topics ADD COLUMN is_pending INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE comments ADD COLUMN is_pending INTEGER DEFAULT 0;`)
+
+	for _, name := range []string{RoleMod, RoleAdmin, RoleSuperAdmin} {
+		GrantCapability(ReadRoleIDByName(name), CapApprovePosts)
+	}
+}
+
+func Migration19() {
+	db.Exec(`ALTER TABLE topics ADD COLUMN spam_score INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE comments ADD COLUMN spam_score INTEGER DEFAULT 0;`)
+
+	db.Exec(`CREATE TABLE spamtokens(
+				token VARCHAR(64) NOT NULL,
+				num_spam INTEGER DEFAULT 0,
+				num_ham INTEGER DEFAULT 0
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX spamtokens_token_index on spamtokens(token);`)
+
+	db.Exec(`CREATE TABLE spamtraining(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				targettype VARCHAR(16) NOT NULL,
+				targetid INTEGER NOT NULL,
+				content TEXT DEFAULT '',
+				is_spam INTEGER NOT NULL,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX spamtraining_target_index on spamtraining(targettype, targetid);`)
+	db.Exec(`CREATE INDEX spamtraining_spam_index on spamtraining(is_spam);`)
+}
+
+func Migration20() {
+	db.Exec(`CREATE TABLE contentrules(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				groupid INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+				pattern_type VARCHAR(16) NOT NULL,
+				pattern TEXT NOT NULL,
+				action VARCHAR(16) NOT NULL,
+				replacement TEXT DEFAULT '',
+				exceptions TEXT DEFAULT '',
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX contentrules_groupid_index on contentrules(groupid);`)
+
+	// Censored words become site-wide mask rules. Plain words now only match whole words.
+	plainWord := regexp.MustCompile(`^\w+$`)
+	for _, word := range strings.Split(Config(CensoredWords), ",") {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		patternType := RulePatternRegex
+		if plainWord.MatchString(word) {
+			patternType = RulePatternWord
+		}
+		db.Exec(`INSERT INTO contentrules(pattern_type, pattern, action, created_date) VALUES(?, ?, ?, ?);`, patternType, word, RuleActionMask, time.Now().Unix())
+	}
+}
+
+func Migration21() {
+	db.Exec(`ALTER TABLE topics ADD COLUMN edited_date INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE comments ADD COLUMN edited_date INTEGER DEFAULT 0;`)
+
+	db.Exec(`CREATE TABLE revisions(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				targettype VARCHAR(16) NOT NULL,
+				targetid INTEGER NOT NULL,
+				userid INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				title VARCHAR(200) DEFAULT '',
+				content TEXT DEFAULT '',
+				is_redacted INTEGER DEFAULT 0,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX revisions_target_index on revisions(targettype, targetid);`)
+}
+
+func Migration22() {
+	db.Exec(`ALTER TABLE topics ADD COLUMN deleted_date INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE comments ADD COLUMN deleted_date INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE groups ADD COLUMN deleted_date INTEGER DEFAULT 0;`)
+
+	// The retention period of items deleted before this migration starts now.
+	now := time.Now().Unix()
+	db.Exec(`UPDATE topics SET deleted_date=? WHERE is_deleted=1;`, now)
+	db.Exec(`UPDATE comments SET deleted_date=? WHERE is_deleted=1;`, now)
+	db.Exec(`UPDATE groups SET deleted_date=? WHERE is_closed=1;`, now)
+	db.Exec(`CREATE INDEX topics_deleted_index on topics(is_deleted, deleted_date);`)
+	db.Exec(`CREATE INDEX comments_deleted_index on comments(is_deleted, deleted_date);`)
+}
+
+func Migration23() {
+	db.Exec(`ALTER TABLE users ADD COLUMN is_shadowbanned INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE topics ADD COLUMN is_shadowed INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE comments ADD COLUMN is_shadowed INTEGER DEFAULT 0;`)
+}
+
+func Migration24() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN slow_mode_secs INTEGER DEFAULT 0;`)
+}
+
+func Migration25() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN autoclose_days INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE groups ADD COLUMN autoclose_comments INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE groups ADD COLUMN stale_reply_days INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE topics ADD COLUMN close_reason VARCHAR(250) DEFAULT '';`)
+	db.Exec(`ALTER TABLE topics ADD COLUMN reopened_date INTEGER DEFAULT 0;`)
+}
+
+func Migration26() {
+	db.Exec(`ALTER TABLE users ADD COLUMN mention_emails INTEGER DEFAULT 1;`)
+
+	db.Exec(`CREATE TABLE mentions(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				authorid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				targettype VARCHAR(16) NOT NULL,
+				targetid INTEGER NOT NULL,
+				is_read INTEGER DEFAULT 0,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE INDEX mentions_userid_index on mentions(userid, created_date);`)
+	db.Exec(`CREATE INDEX mentions_authorid_index on mentions(authorid, created_date);`)
+	db.Exec(`CREATE INDEX mentions_target_index on mentions(targettype, targetid);`)
+}
+
+func Migration27() {
+	db.Exec(`ALTER TABLE groups ADD COLUMN is_threaded INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE users ADD COLUMN thread_view INTEGER DEFAULT -1;`)
+}
+
+func Migration28() {
+	db.Exec(`CREATE TABLE blocks(
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				userid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				blockedid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+				created_date INTEGER NOT NULL
+	);`)
+	db.Exec(`CREATE UNIQUE INDEX blocks_userid_blockedid_index on blocks(userid, blockedid);`)
+}
+
 func Migrate() {
 	dbver := db.Version()
 	if dbver == ModelVersion {
@@ -244,6 +603,122 @@ func Migrate() {
 			Migration4()
 
 			WriteConfig(Version, "4")
+		} else if dbver == 4 {
+			Migration5()
+
+			WriteConfig(Version, "5")
+			WriteConfig(AccountDeletionPolicy, AccountDeletionAnonymize)
+			WriteConfig(AccountDeletionGraceDays, "14")
+		} else if dbver == 5 {
+			Migration6()
+
+			WriteConfig(Version, "6")
+			WriteConfig(AllowUserNameChange, "0")
+			WriteConfig(UserNameChangeInterval, "30")
+			WriteConfig(UserNameCooldown, "30")
+		} else if dbver == 6 {
+			Migration7()
+
+			WriteConfig(Version, "7")
+			WriteConfig(AllowImpersonationWrites, "0")
+		} else if dbver == 7 {
+			Migration8()
+
+			WriteConfig(Version, "8")
+		} else if dbver == 8 {
+			Migration9()
+
+			WriteConfig(Version, "9")
+		} else if dbver == 9 {
+			Migration10()
+
+			WriteConfig(Version, "10")
+		} else if dbver == 10 {
+			Migration11()
+
+			WriteConfig(Version, "11")
+		} else if dbver == 11 {
+			Migration12()
+
+			WriteConfig(Version, "12")
+		} else if dbver == 12 {
+			Migration13()
+
+			WriteConfig(Version, "13")
+		} else if dbver == 13 {
+			Migration14()
+
+			WriteConfig(Version, "14")
+		} else if dbver == 14 {
+			Migration15()
+
+			WriteConfig(Version, "15")
+			WriteConfig(TrustBasicDays, "1")
+			WriteConfig(TrustBasicPosts, "3")
+			WriteConfig(TrustMemberDays, "30")
+			WriteConfig(TrustMemberPosts, "30")
+			WriteConfig(NewUserMaxLinks, "2")
+			WriteConfig(NewUserTopicsPerDay, "3")
+		} else if dbver == 15 {
+			Migration16()
+
+			WriteConfig(Version, "16")
+		} else if dbver == 16 {
+			Migration17()
+
+			WriteConfig(Version, "17")
+		} else if dbver == 17 {
+			Migration18()
+
+			WriteConfig(Version, "18")
+			WriteConfig(PremodLevel, "0")
+		} else if dbver == 18 {
+			Migration19()
+
+			WriteConfig(Version, "19")
+			WriteConfig(SpamThreshold, "90")
+		} else if dbver == 19 {
+			Migration20()
+
+			WriteConfig(Version, "20")
+		} else if dbver == 20 {
+			Migration21()
+
+			WriteConfig(Version, "21")
+		} else if dbver == 21 {
+			Migration22()
+
+			WriteConfig(Version, "22")
+			WriteConfig(TrashRetentionDays, "30")
+		} else if dbver == 22 {
+			Migration23()
+
+			WriteConfig(Version, "23")
+		} else if dbver == 23 {
+			Migration24()
+
+			WriteConfig(Version, "24")
+			WriteConfig(TopicsPerHour, "10")
+			WriteConfig(CommentsPerHour, "60")
+			WriteConfig(MessagesPerHour, "30")
+		} else if dbver == 24 {
+			Migration25()
+
+			WriteConfig(Version, "25")
+		} else if dbver == 25 {
+			Migration26()
+
+			WriteConfig(Version, "26")
+			WriteConfig(MentionsPerPost, "10")
+			WriteConfig(MentionsPerHour, "30")
+		} else if dbver == 26 {
+			Migration27()
+
+			WriteConfig(Version, "27")
+		} else if dbver == 27 {
+			Migration28()
+
+			WriteConfig(Version, "28")
 		}
 		dbver = db.Version()
 	}
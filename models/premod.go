@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"strconv"
+	"time"
+)
+
+// Pre-moderation levels. Topics and comments by users with a trust level below the level of the
+// group (or the forum-wide level, if higher) are held until a mod approves them.
+const (
+	PremodOff      int = 0
+	PremodNew      int = 1
+	PremodBasic    int = 2
+	PremodEveryone int = 3
+)
+
+func IsValidPremodLevel(level int) bool {
+	return level >= PremodOff && level <= PremodEveryone
+}
+
+// ReadPremodLevel returns the pre-moderation level that applies to posts in the group.
+func ReadPremodLevel(groupID string) int {
+	level := PremodOff
+	db.QueryRow(`SELECT premod_level FROM groups WHERE id=?;`, groupID).Scan(&level)
+	if forumLevel, err := strconv.Atoi(Config(PremodLevel)); err == nil && forumLevel > level {
+		level = forumLevel
+	}
+	return level
+}
+
+type PendingPost struct {
+	ID          string
+	Type        string
+	TopicID     string
+	TopicTitle  string
+	GroupID     string
+	GroupName   string
+	OwnerName   string
+	Content     string
+	SpamScore   int
+	CreatedDate time.Time
+}
+
+// ReadPendingPosts returns the topics and comments awaiting approval in the group, oldest first.
+// If groupID is empty, posts from all groups are returned.
+func ReadPendingPosts(groupID string) []PendingPost {
+	cond := `groups.id=?`
+	args := []interface{}{groupID, groupID}
+	if groupID == "" {
+		cond = `1=1`
+		args = nil
+	}
+	var posts []PendingPost
+	rows := db.Query(`SELECT 'topic', topics.id, topics.id, topics.title, groups.id, groups.name, users.username, topics.content, topics.spam_score, topics.created_date FROM topics INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON topics.userid=users.id WHERE topics.is_pending=1 AND topics.is_deleted=0 AND `+cond+`
+		UNION ALL SELECT 'comment', comments.id, topics.id, topics.title, groups.id, groups.name, users.username, comments.content, comments.spam_score, comments.created_date FROM comments INNER JOIN topics ON comments.topicid=topics.id INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON comments.userid=users.id WHERE comments.is_pending=1 AND comments.is_deleted=0 AND `+cond+`
+		ORDER BY 10;`, args...)
+	for rows.Next() {
+		p := PendingPost{}
+		var cDate int64
+		rows.Scan(&p.Type, &p.ID, &p.TopicID, &p.TopicTitle, &p.GroupID, &p.GroupName, &p.OwnerName, &p.Content, &p.SpamScore, &cDate)
+		p.CreatedDate = time.Unix(cDate, 0)
+		posts = append(posts, p)
+	}
+	return posts
+}
+
+// NumPendingPosts returns the number of posts awaiting approval in the group, or in all groups if
+// groupID is empty.
+func NumPendingPosts(groupID string) int {
+	var numTopics, numComments int
+	if groupID == "" {
+		db.QueryRow(`SELECT COUNT(*) FROM topics WHERE is_pending=1 AND is_deleted=0;`).Scan(&numTopics)
+		db.QueryRow(`SELECT COUNT(*) FROM comments WHERE is_pending=1 AND is_deleted=0;`).Scan(&numComments)
+	} else {
+		db.QueryRow(`SELECT COUNT(*) FROM topics WHERE groupid=? AND is_pending=1 AND is_deleted=0;`, groupID).Scan(&numTopics)
+		db.QueryRow(`SELECT COUNT(*) FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE topics.groupid=? AND comments.is_pending=1 AND comments.is_deleted=0;`, groupID).Scan(&numComments)
+	}
+	return numTopics + numComments
+}
+
+// ApproveTopic publishes a pending topic. It is bumped to the top of the group as if just posted.
+func ApproveTopic(topicID string) {
+	db.Exec(`UPDATE topics SET is_pending=0, activity_date=? WHERE id=?;`, time.Now().Unix(), topicID)
+}
+
+// ApproveComment publishes a pending comment and counts it towards the activity of the topic.
+func ApproveComment(commentID string) {
+	var topicID string
+	if db.QueryRow(`SELECT topicid FROM comments WHERE id=? AND is_pending=1;`, commentID).Scan(&topicID) != nil {
+		return
+	}
+	db.Exec(`UPDATE comments SET is_pending=0 WHERE id=?;`, commentID)
+	db.Exec(`UPDATE topics SET num_comments=num_comments+1, activity_date=? WHERE id=?;`, time.Now().Unix(), topicID)
+}
+
+// RejectTopic and RejectComment delete the pending post. It stays pending so it is never counted.
+func RejectTopic(topicID string) {
+	DeleteTopic(topicID)
+}
+
+func RejectComment(commentID string) {
+	DeleteComment(commentID)
+}
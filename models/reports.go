@@ -0,0 +1,171 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/s-gv/orangeforum/models/db"
+	"time"
+)
+
+const (
+	ReportTargetTopic   string = "topic"
+	ReportTargetComment string = "comment"
+	ReportTargetMessage string = "message"
+	ReportTargetUser    string = "user"
+)
+
+// Reports start open, may be claimed by a moderator, and end resolved or dismissed.
+const (
+	ReportStatusOpen      string = "open"
+	ReportStatusClaimed   string = "claimed"
+	ReportStatusResolved  string = "resolved"
+	ReportStatusDismissed string = "dismissed"
+)
+
+var ReportCategories = []string{"spam", "abuse", "off-topic", "illegal", "other"}
+
+func IsValidReportTarget(targetType string) bool {
+	return targetType == ReportTargetTopic || targetType == ReportTargetComment || targetType == ReportTargetMessage || targetType == ReportTargetUser
+}
+
+func IsValidReportCategory(category string) bool {
+	for _, c := range ReportCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+type Report struct {
+	ID           string
+	ReporterName string
+	TargetType   string
+	TargetID     string
+	GroupID      string
+	GroupName    string
+	Category     string
+	Reason       string
+	Status       string
+	ClaimerName  string
+	Resolution   string
+	CreatedDate  time.Time
+	ResolvedDate time.Time
+}
+
+func (report Report) IsActive() bool {
+	return report.Status == ReportStatusOpen || report.Status == ReportStatusClaimed
+}
+
+// CreateReport files a report unless the reporter already has an active report on the target.
+// Reports on topics and comments belong to the group they were posted in; others have no group.
+func CreateReport(reporterID int64, targetType string, targetID string, groupID string, category string, reason string) {
+	var tmp string
+	if db.QueryRow(`SELECT id FROM reports WHERE reporterid=? AND targettype=? AND targetid=? AND status IN (?, ?);`,
+		reporterID, targetType, targetID, ReportStatusOpen, ReportStatusClaimed).Scan(&tmp) == nil {
+		return
+	}
+	var gid interface{}
+	if groupID != "" {
+		gid = groupID
+	}
+	db.Exec(`INSERT INTO reports(reporterid, targettype, targetid, groupid, category, reason, status, created_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`,
+		reporterID, targetType, targetID, gid, category, reason, ReportStatusOpen, time.Now().Unix())
+}
+
+// ClaimReport marks an open report as being worked on by the moderator. Reports claimed by
+// someone else are left alone. It reports whether the moderator holds the claim.
+func ClaimReport(reportID string, userID int64) bool {
+	db.Exec(`UPDATE reports SET status=?, claimerid=? WHERE id=? AND status=?;`,
+		ReportStatusClaimed, userID, reportID, ReportStatusOpen)
+	var claimerID sql.NullInt64
+	db.QueryRow(`SELECT claimerid FROM reports WHERE id=? AND status=?;`, reportID, ReportStatusClaimed).Scan(&claimerID)
+	return claimerID.Valid && claimerID.Int64 == userID
+}
+
+// CloseReport marks the report resolved or dismissed with a note from the moderator.
+func CloseReport(reportID string, userID int64, status string, resolution string) {
+	db.Exec(`UPDATE reports SET status=?, claimerid=?, resolution=?, resolved_date=? WHERE id=?;`,
+		status, userID, resolution, time.Now().Unix(), reportID)
+}
+
+const reportsQuery = `SELECT reports.id, COALESCE(reporters.username, ''), reports.targettype, reports.targetid, COALESCE(reports.groupid, 0), COALESCE(groups.name, ''),
+		reports.category, reports.reason, reports.status, COALESCE(claimers.username, ''), reports.resolution, reports.created_date, reports.resolved_date
+		FROM reports LEFT JOIN users reporters ON reports.reporterid=reporters.id LEFT JOIN users claimers ON reports.claimerid=claimers.id
+		LEFT JOIN groups ON reports.groupid=groups.id `
+
+func readReports(query string, args ...interface{}) []Report {
+	var reports []Report
+	rows := db.Query(reportsQuery+query, args...)
+	for rows.Next() {
+		var report Report
+		var cDate, rDate int64
+		rows.Scan(&report.ID, &report.ReporterName, &report.TargetType, &report.TargetID, &report.GroupID, &report.GroupName,
+			&report.Category, &report.Reason, &report.Status, &report.ClaimerName, &report.Resolution, &cDate, &rDate)
+		if report.GroupID == "0" {
+			report.GroupID = ""
+		}
+		report.CreatedDate = time.Unix(cDate, 0)
+		if rDate != 0 {
+			report.ResolvedDate = time.Unix(rDate, 0)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func ReadReport(reportID string) (Report, error) {
+	reports := readReports(`WHERE reports.id=?;`, reportID)
+	if len(reports) == 0 {
+		return Report{}, errors.New("Report not found.")
+	}
+	return reports[0], nil
+}
+
+// ReadReports returns the most recent reports in the group, or in every group and outside groups
+// if groupID is "". If active is true, only open and claimed reports are returned.
+func ReadReports(groupID string, active bool) []Report {
+	cond := `WHERE 1=1`
+	var args []interface{}
+	if groupID != "" {
+		cond = cond + ` AND reports.groupid=?`
+		args = append(args, groupID)
+	}
+	if active {
+		cond = cond + ` AND reports.status IN (?, ?)`
+		args = append(args, ReportStatusOpen, ReportStatusClaimed)
+	}
+	return readReports(cond+` ORDER BY reports.created_date DESC LIMIT 100;`, args...)
+}
+
+// NumActiveReports returns the number of open and claimed reports in the group, or everywhere if groupID is "".
+func NumActiveReports(groupID string) int {
+	var n int
+	if groupID != "" {
+		db.QueryRow(`SELECT COUNT(*) FROM reports WHERE groupid=? AND status IN (?, ?);`, groupID, ReportStatusOpen, ReportStatusClaimed).Scan(&n)
+	} else {
+		db.QueryRow(`SELECT COUNT(*) FROM reports WHERE status IN (?, ?);`, ReportStatusOpen, ReportStatusClaimed).Scan(&n)
+	}
+	return n
+}
+
+// ReadTopicReportCounts returns the number of active reports on the topic and on each of its comments.
+func ReadTopicReportCounts(topicID string) (int, map[string]int) {
+	var numTopicReports int
+	db.QueryRow(`SELECT COUNT(*) FROM reports WHERE targettype=? AND targetid=? AND status IN (?, ?);`,
+		ReportTargetTopic, topicID, ReportStatusOpen, ReportStatusClaimed).Scan(&numTopicReports)
+	counts := make(map[string]int)
+	rows := db.Query(`SELECT targetid, COUNT(*) FROM reports WHERE targettype=? AND status IN (?, ?) AND targetid IN (SELECT id FROM comments WHERE topicid=?) GROUP BY targetid;`,
+		ReportTargetComment, ReportStatusOpen, ReportStatusClaimed, topicID)
+	for rows.Next() {
+		var commentID string
+		var n int
+		rows.Scan(&commentID, &n)
+		counts[commentID] = n
+	}
+	return numTopicReports, counts
+}
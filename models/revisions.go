@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/s-gv/orangeforum/models/db"
+	"time"
+)
+
+// Revision is one version of a topic or comment. Comments have no title. The last revision of a
+// post is its current text.
+type Revision struct {
+	ID          string
+	TargetType  string
+	TargetID    string
+	UserName    string
+	Title       string
+	Content     string
+	IsRedacted  bool
+	CreatedDate time.Time
+}
+
+// EditPost changes the title and content of a topic (the title is ignored for comments) and keeps
+// the old text as a revision. The first edit also records the original post.
+func EditPost(targetType string, targetID string, editorID int64, title string, content string) {
+	var numRevisions int
+	db.QueryRow(`SELECT COUNT(*) FROM revisions WHERE targettype=? AND targetid=?;`, targetType, targetID).Scan(&numRevisions)
+	if numRevisions == 0 {
+		var oldTitle, oldContent string
+		var ownerID sql.NullInt64
+		var cDate int64
+		if targetType == "topic" {
+			db.QueryRow(`SELECT title, content, userid, created_date FROM topics WHERE id=?;`, targetID).Scan(&oldTitle, &oldContent, &ownerID, &cDate)
+		} else {
+			db.QueryRow(`SELECT content, userid, created_date FROM comments WHERE id=?;`, targetID).Scan(&oldContent, &ownerID, &cDate)
+		}
+		db.Exec(`INSERT INTO revisions(targettype, targetid, userid, title, content, created_date) VALUES(?, ?, ?, ?, ?, ?);`,
+			targetType, targetID, ownerID, oldTitle, oldContent, cDate)
+	}
+	now := time.Now().Unix()
+	if targetType == "topic" {
+		db.Exec(`UPDATE topics SET title=?, content=?, edited_date=? WHERE id=?;`, title, content, now, targetID)
+	} else {
+		title = ""
+		db.Exec(`UPDATE comments SET content=?, edited_date=? WHERE id=?;`, content, now, targetID)
+	}
+	db.Exec(`INSERT INTO revisions(targettype, targetid, userid, title, content, created_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		targetType, targetID, editorID, title, content, now)
+}
+
+// deleteOrphanRevisions removes the revisions of topics and comments that no longer exist.
+func deleteOrphanRevisions() {
+	db.Exec(`DELETE FROM revisions WHERE (targettype='topic' AND targetid NOT IN (SELECT id FROM topics)) OR (targettype='comment' AND targetid NOT IN (SELECT id FROM comments));`)
+}
+
+// ReadRevisions returns the revisions of a topic or comment, oldest first.
+func ReadRevisions(targetType string, targetID string) []Revision {
+	var revisions []Revision
+	rows := db.Query(`SELECT revisions.id, users.username, revisions.title, revisions.content, revisions.is_redacted, revisions.created_date FROM revisions LEFT JOIN users ON revisions.userid=users.id WHERE revisions.targettype=? AND revisions.targetid=? ORDER BY revisions.id;`, targetType, targetID)
+	for rows.Next() {
+		rev := Revision{TargetType: targetType, TargetID: targetID}
+		var userName sql.NullString
+		var cDate int64
+		rows.Scan(&rev.ID, &userName, &rev.Title, &rev.Content, &rev.IsRedacted, &cDate)
+		rev.UserName = userName.String
+		rev.CreatedDate = time.Unix(cDate, 0)
+		revisions = append(revisions, rev)
+	}
+	return revisions
+}
+
+func ReadRevision(revisionID string) (Revision, error) {
+	var rev Revision
+	var cDate int64
+	if db.QueryRow(`SELECT id, targettype, targetid, title, content, is_redacted, created_date FROM revisions WHERE id=?;`, revisionID).Scan(
+		&rev.ID, &rev.TargetType, &rev.TargetID, &rev.Title, &rev.Content, &rev.IsRedacted, &cDate) != nil {
+		return rev, errors.New("Revision not found.")
+	}
+	rev.CreatedDate = time.Unix(cDate, 0)
+	return rev, nil
+}
+
+// RedactRevision erases the text of an old revision, along with the copies of it kept in the audit
+// log and by the spam classifier. The current text of a post cannot be redacted; edit the post first.
+func RedactRevision(revisionID string) error {
+	rev, err := ReadRevision(revisionID)
+	if err != nil {
+		return err
+	}
+	var lastID string
+	db.QueryRow(`SELECT id FROM revisions WHERE targettype=? AND targetid=? ORDER BY id DESC LIMIT 1;`, rev.TargetType, rev.TargetID).Scan(&lastID)
+	if lastID == rev.ID {
+		return errors.New("The current version cannot be redacted. Edit the post first.")
+	}
+	db.Exec(`UPDATE revisions SET title='', content='', is_redacted=1 WHERE id=?;`, revisionID)
+	auditText, postText := rev.Content, rev.Content
+	if rev.TargetType == "topic" {
+		auditText = rev.Title + "\n\n" + rev.Content
+		postText = rev.Title + "\n" + rev.Content
+	}
+	db.Exec(`UPDATE auditlog SET before_val=? WHERE targettype=? AND targetid=? AND before_val=?;`, redactedText, rev.TargetType, rev.TargetID, auditText)
+	db.Exec(`UPDATE auditlog SET after_val=? WHERE targettype=? AND targetid=? AND after_val=?;`, redactedText, rev.TargetType, rev.TargetID, auditText)
+	untrainSpamText(rev.TargetType, rev.TargetID, postText)
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models/db"
+	"strconv"
+	"time"
+)
+
+// Capabilities that can be granted to roles.
+const (
+	CapPostTopic        string = "post_topic"
+	CapPostComment      string = "post_comment"
+	CapUploadImages     string = "upload_images"
+	CapSticky           string = "sticky"
+	CapClose            string = "close"
+	CapEditOthers       string = "edit_others"
+	CapDeleteOthers     string = "delete_others"
+	CapBanUsers         string = "ban_users"
+	CapEditGroup        string = "edit_group"
+	CapManageMods       string = "manage_mods"
+	CapManageMembers    string = "manage_members"
+	CapStickyGroup      string = "sticky_group"
+	CapPostAnnouncement string = "post_announcement"
+	CapMoveTopics       string = "move_topics"
+	CapHandleReports    string = "handle_reports"
+	CapApprovePosts     string = "approve_posts"
+)
+
+var Capabilities = []string{
+	CapPostTopic, CapPostComment, CapUploadImages, CapSticky, CapClose, CapEditOthers, CapDeleteOthers,
+	CapBanUsers, CapEditGroup, CapManageMods, CapManageMembers, CapStickyGroup, CapPostAnnouncement,
+	CapMoveTopics, CapHandleReports, CapApprovePosts,
+}
+
+// Seeded roles. Every logged in user has the user role. Superadmins, and the admins and mods of a
+// group, get the other seeded roles from users.is_superadmin and the admins and mods tables.
+const (
+	RoleUser       string = "user"
+	RoleMod        string = "mod"
+	RoleAdmin      string = "admin"
+	RoleSuperAdmin string = "superadmin"
+)
+
+var defaultRoleCapabilities = map[string][]string{
+	RoleUser:       {CapPostTopic, CapPostComment, CapUploadImages},
+	RoleMod:        {CapPostTopic, CapPostComment, CapUploadImages, CapSticky, CapClose, CapEditOthers, CapDeleteOthers, CapBanUsers, CapPostAnnouncement, CapMoveTopics, CapHandleReports, CapApprovePosts},
+	RoleAdmin:      {CapPostTopic, CapPostComment, CapUploadImages, CapSticky, CapClose, CapEditOthers, CapDeleteOthers, CapBanUsers, CapPostAnnouncement, CapMoveTopics, CapHandleReports, CapApprovePosts, CapEditGroup, CapManageMods, CapManageMembers},
+	RoleSuperAdmin: Capabilities,
+}
+
+type Role struct {
+	ID           string
+	Name         string
+	Capabilities map[string]bool
+}
+
+// IsSeeded reports whether the role is one of the built-in roles whose members are implied.
+func (role Role) IsSeeded() bool {
+	_, ok := defaultRoleCapabilities[role.Name]
+	return ok
+}
+
+func seedRoles() {
+	for _, name := range []string{RoleUser, RoleMod, RoleAdmin, RoleSuperAdmin} {
+		CreateRole(name)
+		roleID := ReadRoleIDByName(name)
+		for _, capability := range defaultRoleCapabilities[name] {
+			GrantCapability(roleID, capability)
+		}
+	}
+}
+
+func CreateRole(name string) {
+	db.Exec(`INSERT INTO roles(name, created_date) VALUES(?, ?);`, name, time.Now().Unix())
+}
+
+func DeleteRole(roleID string) {
+	db.Exec(`DELETE FROM roles WHERE id=?;`, roleID)
+}
+
+func ReadRoleIDByName(name string) string {
+	var roleID string
+	db.QueryRow(`SELECT id FROM roles WHERE name=?;`, name).Scan(&roleID)
+	return roleID
+}
+
+func ReadRoles() []Role {
+	var roles []Role
+	rows := db.Query(`SELECT id, name FROM roles ORDER BY id;`)
+	for rows.Next() {
+		role := Role{Capabilities: make(map[string]bool)}
+		rows.Scan(&role.ID, &role.Name)
+		roles = append(roles, role)
+	}
+	rows = db.Query(`SELECT roleid, capability FROM rolecapabilities;`)
+	for rows.Next() {
+		var roleID, capability string
+		rows.Scan(&roleID, &capability)
+		for _, role := range roles {
+			if role.ID == roleID {
+				role.Capabilities[capability] = true
+			}
+		}
+	}
+	return roles
+}
+
+func GrantCapability(roleID string, capability string) {
+	var tmp string
+	if db.QueryRow(`SELECT id FROM rolecapabilities WHERE roleid=? AND capability=?;`, roleID, capability).Scan(&tmp) != nil {
+		db.Exec(`INSERT INTO rolecapabilities(roleid, capability) VALUES(?, ?);`, roleID, capability)
+	}
+}
+
+func RevokeCapability(roleID string, capability string) {
+	db.Exec(`DELETE FROM rolecapabilities WHERE roleid=? AND capability=?;`, roleID, capability)
+}
+
+// AssignRole gives the user a role across the site, or only in one group if groupID is not empty.
+func AssignRole(userID int64, roleID string, groupID string) {
+	gid := sql.NullInt64{}
+	if id, err := strconv.ParseInt(groupID, 10, 64); err == nil {
+		gid = sql.NullInt64{Int64: id, Valid: true}
+	}
+	db.Exec(`INSERT INTO userroles(userid, roleid, groupid, created_date) VALUES(?, ?, ?, ?);`, userID, roleID, gid, time.Now().Unix())
+}
+
+func UnassignRole(userRoleID string) {
+	db.Exec(`DELETE FROM userroles WHERE id=?;`, userRoleID)
+}
+
+type UserRole struct {
+	ID        string
+	UserName  string
+	RoleName  string
+	GroupName string
+}
+
+func ReadUserRoles() []UserRole {
+	var userRoles []UserRole
+	rows := db.Query(`SELECT userroles.id, users.username, roles.name, COALESCE(groups.name, '') FROM userroles INNER JOIN users ON userroles.userid=users.id INNER JOIN roles ON userroles.roleid=roles.id LEFT JOIN groups ON userroles.groupid=groups.id ORDER BY users.username;`)
+	for rows.Next() {
+		var ur UserRole
+		rows.Scan(&ur.ID, &ur.UserName, &ur.RoleName, &ur.GroupName)
+		userRoles = append(userRoles, ur)
+	}
+	return userRoles
+}
+
+// Can reports whether the user has the capability in the group through any of their roles.
+// Pass an empty groupID to check site-level roles only.
+func Can(userID sql.NullInt64, capability string, groupID string) bool {
+	if !userID.Valid {
+		return false
+	}
+	gid, _ := strconv.ParseInt(groupID, 10, 64)
+	var tmp string
+	return db.QueryRow(`SELECT rolecapabilities.id FROM rolecapabilities INNER JOIN roles ON rolecapabilities.roleid=roles.id WHERE rolecapabilities.capability=? AND (
+		roles.name=?
+		OR (roles.name=? AND EXISTS (SELECT 1 FROM users WHERE id=? AND is_superadmin=1))
+		OR (roles.name=? AND EXISTS (SELECT 1 FROM admins WHERE userid=? AND groupid=?))
+		OR (roles.name=? AND EXISTS (SELECT 1 FROM mods WHERE userid=? AND groupid=?))
+		OR roles.id IN (SELECT roleid FROM userroles WHERE userid=? AND (groupid IS NULL OR groupid=?))
+	) LIMIT 1;`, capability,
+		RoleUser,
+		RoleSuperAdmin, userID.Int64,
+		RoleAdmin, userID.Int64, gid,
+		RoleMod, userID.Int64, gid,
+		userID.Int64, gid).Scan(&tmp) == nil
+}
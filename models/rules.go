@@ -0,0 +1,260 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/s-gv/orangeforum/models/db"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Content rule patterns. Words and wildcards (* for any letters, ? for one letter) match whole
+// words only. All patterns are case-insensitive.
+const (
+	RulePatternWord     string = "word"
+	RulePatternWildcard string = "wildcard"
+	RulePatternRegex    string = "regex"
+)
+
+// Content rule actions. Mask and replace rewrite matches when posts are shown. Reject and hold
+// apply when posts are made.
+const (
+	RuleActionMask    string = "mask"
+	RuleActionReplace string = "replace"
+	RuleActionReject  string = "reject"
+	RuleActionHold    string = "hold"
+)
+
+type ContentRule struct {
+	ID          string
+	GroupID     string
+	PatternType string
+	Pattern     string
+	Action      string
+	Replacement string
+	Exceptions  string
+	CreatedDate time.Time
+}
+
+type RuleMatch struct {
+	RuleID string
+	Action string
+	Text   string
+}
+
+// RuleResult is the content after mask and replace rules, and the matches of every rule.
+type RuleResult struct {
+	Content string
+	Matches []RuleMatch
+}
+
+// Rejection returns the text matched by the first reject rule, or "".
+func (res RuleResult) Rejection() string {
+	for _, m := range res.Matches {
+		if m.Action == RuleActionReject {
+			return m.Text
+		}
+	}
+	return ""
+}
+
+// Hold returns the text matched by the first hold rule, or "".
+func (res RuleResult) Hold() string {
+	for _, m := range res.Matches {
+		if m.Action == RuleActionHold {
+			return m.Text
+		}
+	}
+	return ""
+}
+
+type compiledRule struct {
+	rule       ContentRule
+	re         *regexp.Regexp
+	exceptions []*regexp.Regexp
+}
+
+// Compiled rules are cached per group, with "" for the site-wide rules. The cache is dropped
+// whenever a rule changes; contentRulesGen keeps a load that raced with a change from being cached.
+var contentRulesMu sync.RWMutex
+var contentRulesCache = map[string][]compiledRule{}
+var contentRulesGen int
+
+func compileRulePattern(patternType string, pattern string) (*regexp.Regexp, error) {
+	if patternType == RulePatternWord {
+		return regexp.Compile(`(?i)\b` + regexp.QuoteMeta(pattern) + `\b`)
+	}
+	if patternType == RulePatternWildcard {
+		p := regexp.QuoteMeta(pattern)
+		p = strings.Replace(p, `\*`, `\w*`, -1)
+		p = strings.Replace(p, `\?`, `\w`, -1)
+		return regexp.Compile(`(?i)\b` + p + `\b`)
+	}
+	if patternType == RulePatternRegex {
+		return regexp.Compile(`(?i)` + pattern)
+	}
+	return nil, errors.New("Unknown pattern type.")
+}
+
+func compileContentRule(rule ContentRule) (compiledRule, error) {
+	re, err := compileRulePattern(rule.PatternType, rule.Pattern)
+	if err != nil {
+		return compiledRule{}, err
+	}
+	cr := compiledRule{rule: rule, re: re}
+	for _, exception := range strings.Split(rule.Exceptions, ",") {
+		if exception = strings.TrimSpace(exception); exception != "" {
+			cr.exceptions = append(cr.exceptions, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(exception)))
+		}
+	}
+	return cr, nil
+}
+
+// ValidateContentRule returns an error if the rule cannot be used.
+func ValidateContentRule(rule ContentRule) error {
+	if rule.Pattern == "" || len(rule.Pattern) > 200 {
+		return errors.New("Pattern should have 1-200 characters.")
+	}
+	if rule.Action != RuleActionMask && rule.Action != RuleActionReplace && rule.Action != RuleActionReject && rule.Action != RuleActionHold {
+		return errors.New("Unknown action.")
+	}
+	if len(rule.Replacement) > 200 || len(rule.Exceptions) > 1000 {
+		return errors.New("Replacement or exceptions too long.")
+	}
+	if _, err := compileRulePattern(rule.PatternType, rule.Pattern); err != nil {
+		return errors.New("Invalid pattern: " + err.Error())
+	}
+	return nil
+}
+
+// CreateContentRule adds the rule and returns its ID.
+func CreateContentRule(rule ContentRule) string {
+	groupID := sql.NullString{String: rule.GroupID, Valid: rule.GroupID != ""}
+	now := time.Now().Unix()
+	db.Exec(`INSERT INTO contentrules(groupid, pattern_type, pattern, action, replacement, exceptions, created_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		groupID, rule.PatternType, rule.Pattern, rule.Action, rule.Replacement, rule.Exceptions, now)
+	var ruleID string
+	db.QueryRow(`SELECT id FROM contentrules WHERE pattern_type=? AND pattern=? AND action=? AND created_date=? ORDER BY id DESC LIMIT 1;`,
+		rule.PatternType, rule.Pattern, rule.Action, now).Scan(&ruleID)
+	InvalidateContentRules()
+	return ruleID
+}
+
+func DeleteContentRule(ruleID string) {
+	db.Exec(`DELETE FROM contentrules WHERE id=?;`, ruleID)
+	InvalidateContentRules()
+}
+
+// InvalidateContentRules drops the compiled rules. Call it after changing the contentrules table.
+func InvalidateContentRules() {
+	contentRulesMu.Lock()
+	contentRulesCache = map[string][]compiledRule{}
+	contentRulesGen++
+	contentRulesMu.Unlock()
+}
+
+func ReadContentRule(ruleID string) (ContentRule, error) {
+	var rule ContentRule
+	var groupID sql.NullString
+	var cDate int64
+	if db.QueryRow(`SELECT id, groupid, pattern_type, pattern, action, replacement, exceptions, created_date FROM contentrules WHERE id=?;`, ruleID).Scan(
+		&rule.ID, &groupID, &rule.PatternType, &rule.Pattern, &rule.Action, &rule.Replacement, &rule.Exceptions, &cDate) != nil {
+		return rule, errors.New("Rule not found.")
+	}
+	rule.GroupID = groupID.String
+	rule.CreatedDate = time.Unix(cDate, 0)
+	return rule, nil
+}
+
+// ReadContentRules returns the rules of the group, or the site-wide rules if groupID is empty.
+func ReadContentRules(groupID string) []ContentRule {
+	var rows *db.Rows
+	if groupID == "" {
+		rows = db.Query(`SELECT id, pattern_type, pattern, action, replacement, exceptions, created_date FROM contentrules WHERE groupid IS NULL ORDER BY id;`)
+	} else {
+		rows = db.Query(`SELECT id, pattern_type, pattern, action, replacement, exceptions, created_date FROM contentrules WHERE groupid=? ORDER BY id;`, groupID)
+	}
+	var rules []ContentRule
+	for rows.Next() {
+		rule := ContentRule{GroupID: groupID}
+		var cDate int64
+		rows.Scan(&rule.ID, &rule.PatternType, &rule.Pattern, &rule.Action, &rule.Replacement, &rule.Exceptions, &cDate)
+		rule.CreatedDate = time.Unix(cDate, 0)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func compiledContentRules(groupID string) []compiledRule {
+	contentRulesMu.RLock()
+	rules, ok := contentRulesCache[groupID]
+	gen := contentRulesGen
+	contentRulesMu.RUnlock()
+	if ok {
+		return rules
+	}
+	rules = []compiledRule{}
+	for _, rule := range ReadContentRules(groupID) {
+		if cr, err := compileContentRule(rule); err == nil {
+			rules = append(rules, cr)
+		}
+	}
+	contentRulesMu.Lock()
+	if gen == contentRulesGen {
+		contentRulesCache[groupID] = rules
+	}
+	contentRulesMu.Unlock()
+	return rules
+}
+
+// ApplyContentRules runs the site-wide rules and then the rules of the group on the content.
+// Matches that fall inside an exception of the rule are skipped.
+func ApplyContentRules(groupID string, content string) RuleResult {
+	rules := compiledContentRules("")
+	if groupID != "" {
+		rules = append(append([]compiledRule{}, rules...), compiledContentRules(groupID)...)
+	}
+	res := RuleResult{Content: content}
+	for _, cr := range rules {
+		var allowed [][]int
+		for _, exception := range cr.exceptions {
+			allowed = append(allowed, exception.FindAllStringIndex(res.Content, -1)...)
+		}
+		var out []byte
+		last := 0
+		for _, loc := range cr.re.FindAllStringIndex(res.Content, -1) {
+			if loc[0] == loc[1] || isInSpans(loc, allowed) {
+				continue
+			}
+			text := res.Content[loc[0]:loc[1]]
+			res.Matches = append(res.Matches, RuleMatch{RuleID: cr.rule.ID, Action: cr.rule.Action, Text: text})
+			if cr.rule.Action == RuleActionMask {
+				out = append(append(out, res.Content[last:loc[0]]...), strings.Repeat("*", utf8.RuneCountInString(text))...)
+				last = loc[1]
+			} else if cr.rule.Action == RuleActionReplace {
+				out = append(append(out, res.Content[last:loc[0]]...), cr.rule.Replacement...)
+				last = loc[1]
+			}
+		}
+		if last > 0 {
+			res.Content = string(append(out, res.Content[last:]...))
+		}
+	}
+	return res
+}
+
+func isInSpans(loc []int, spans [][]int) bool {
+	for _, span := range spans {
+		if loc[0] >= span[0] && loc[1] <= span[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The spam classifier is a naive Bayes classifier that counts, for every token, the number of spam
+// and non-spam posts it appeared in. It learns from posts marked by mods and scores nothing until
+// it has seen minSpamTrainingPosts posts of each kind.
+const minSpamTrainingPosts = 5
+
+// Only the first maxSpamTokens distinct tokens of a post are learnt or scored.
+const maxSpamTokens = 200
+
+func spamTokens(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(token) < 2 || len(token) > 40 || seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+		if len(tokens) == maxSpamTokens {
+			break
+		}
+	}
+	return tokens
+}
+
+// readPostText returns the text of a topic (title and content) or a comment.
+func readPostText(targetType string, targetID string) string {
+	var title, content string
+	if targetType == "topic" {
+		db.QueryRow(`SELECT title, content FROM topics WHERE id=?;`, targetID).Scan(&title, &content)
+		return title + "\n" + content
+	}
+	db.QueryRow(`SELECT content FROM comments WHERE id=?;`, targetID).Scan(&content)
+	return content
+}
+
+func addSpamTokens(text string, isSpam bool, delta int) {
+	column := "num_ham"
+	if isSpam {
+		column = "num_spam"
+	}
+	for _, token := range spamTokens(text) {
+		db.Exec(`INSERT INTO spamtokens(token, num_spam, num_ham) VALUES(?, 0, 0) ON CONFLICT(token) DO NOTHING;`, token)
+		db.Exec(`UPDATE spamtokens SET `+column+`=`+column+`+? WHERE token=?;`, delta, token)
+	}
+}
+
+// TrainSpam teaches the classifier that a topic or comment is spam or not. A post is counted only
+// once; marking it again the other way moves it to the other side.
+func TrainSpam(targetType string, targetID string, isSpam bool) {
+	var oldContent string
+	var wasSpam bool
+	if db.QueryRow(`SELECT content, is_spam FROM spamtraining WHERE targettype=? AND targetid=?;`, targetType, targetID).Scan(&oldContent, &wasSpam) == nil {
+		if wasSpam == isSpam {
+			return
+		}
+		addSpamTokens(oldContent, wasSpam, -1)
+		db.Exec(`DELETE FROM spamtraining WHERE targettype=? AND targetid=?;`, targetType, targetID)
+	}
+	content := readPostText(targetType, targetID)
+	addSpamTokens(content, isSpam, 1)
+	db.Exec(`INSERT INTO spamtraining(targettype, targetid, content, is_spam, created_date) VALUES(?, ?, ?, ?, ?);`, targetType, targetID, content, isSpam, time.Now().Unix())
+}
+
+// untrainSpamText forgets the training copy of a post if it holds the given text. The post still
+// counts towards the number of posts of its kind.
+func untrainSpamText(targetType string, targetID string, text string) {
+	var isSpam bool
+	if db.QueryRow(`SELECT is_spam FROM spamtraining WHERE targettype=? AND targetid=? AND content=?;`, targetType, targetID, text).Scan(&isSpam) != nil {
+		return
+	}
+	addSpamTokens(text, isSpam, -1)
+	db.Exec(`UPDATE spamtraining SET content='' WHERE targettype=? AND targetid=?;`, targetType, targetID)
+}
+
+// SpamScore returns the probability, in percent, that the text is spam.
+func SpamScore(text string) int {
+	var numSpam, numHam int
+	db.QueryRow(`SELECT COUNT(*) FROM spamtraining WHERE is_spam=1;`).Scan(&numSpam)
+	db.QueryRow(`SELECT COUNT(*) FROM spamtraining WHERE is_spam=0;`).Scan(&numHam)
+	if numSpam < minSpamTrainingPosts || numHam < minSpamTrainingPosts {
+		return 0
+	}
+	logOdds := math.Log(float64(numSpam) / float64(numHam))
+	if tokens := spamTokens(text); len(tokens) > 0 {
+		args := make([]interface{}, len(tokens))
+		for i, token := range tokens {
+			args[i] = token
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tokens)), ", ")
+		rows := db.Query(`SELECT num_spam, num_ham FROM spamtokens WHERE token IN (`+placeholders+`);`, args...)
+		for rows.Next() {
+			var tokenSpam, tokenHam int
+			rows.Scan(&tokenSpam, &tokenHam)
+			pSpam := (float64(tokenSpam) + 1) / (float64(numSpam) + 2)
+			pHam := (float64(tokenHam) + 1) / (float64(numHam) + 2)
+			logOdds += math.Log(pSpam / pHam)
+		}
+	}
+	return int(100 / (1 + math.Exp(-logOdds)))
+}
+
+// IsLikelySpam reports whether a post with the score should be held for a mod to approve.
+func IsLikelySpam(score int) bool {
+	threshold, err := strconv.Atoi(Config(SpamThreshold))
+	return err == nil && threshold > 0 && score >= threshold
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models/db"
+	"sync"
+)
+
+// MaxThreadDepth is how deeply replies nest in the threaded view. A reply to a comment at the
+// deepest level is shown next to the comment instead of under it.
+const MaxThreadDepth = 6
+
+// Thread views a user can pick. ThreadViewDefault uses the setting of the group.
+const (
+	ThreadViewDefault  = -1
+	ThreadViewFlat     = 0
+	ThreadViewThreaded = 1
+)
+
+func IsValidThreadView(view int) bool {
+	return view == ThreadViewDefault || view == ThreadViewFlat || view == ThreadViewThreaded
+}
+
+// IsThreaded reports whether the user sees the comments of the group as threads. Users who have
+// not picked a view, and visitors who are not logged in, get the default of the group.
+func IsThreaded(userID sql.NullInt64, groupID string) bool {
+	view := ThreadViewDefault
+	if userID.Valid {
+		db.QueryRow(`SELECT thread_view FROM users WHERE id=?;`, userID).Scan(&view)
+	}
+	if view != ThreadViewDefault {
+		return view == ThreadViewThreaded
+	}
+	var isThreaded bool
+	db.QueryRow(`SELECT is_threaded FROM groups WHERE id=?;`, groupID).Scan(&isThreaded)
+	return isThreaded
+}
+
+// ReplyParent returns the parent to store for a reply to the comment, keeping the thread within
+// MaxThreadDepth.
+func ReplyParent(commentID string) sql.NullInt64 {
+	var ancestors []int64
+	seen := make(map[int64]bool)
+	id := sql.NullInt64{}
+	db.QueryRow(`SELECT id FROM comments WHERE id=?;`, commentID).Scan(&id)
+	for id.Valid && !seen[id.Int64] {
+		seen[id.Int64] = true
+		ancestors = append(ancestors, id.Int64)
+		var parentID sql.NullInt64
+		db.QueryRow(`SELECT parentid FROM comments WHERE id=?;`, id.Int64).Scan(&parentID)
+		id = parentID
+	}
+	if len(ancestors) == 0 {
+		return sql.NullInt64{}
+	}
+	if len(ancestors) >= MaxThreadDepth {
+		return sql.NullInt64{Int64: ancestors[len(ancestors)-MaxThreadDepth+1], Valid: true}
+	}
+	return sql.NullInt64{Int64: ancestors[0], Valid: true}
+}
+
+// ThreadComment is the place of a comment in the threaded view of a topic.
+type ThreadComment struct {
+	ID       string
+	ParentID string
+	Depth    int
+}
+
+// Thread layouts are cached per topic. The cache is dropped whenever comments are added, removed,
+// or reordered; threadPagesGen keeps a layout read during such a change from being cached.
+var threadPagesMu sync.RWMutex
+var threadPagesCache = map[string]threadLayout{}
+var threadPagesGen int
+
+// The cache is emptied when it holds more than maxThreadLayouts topics.
+const maxThreadLayouts = 1000
+
+type threadLayout struct {
+	perPage int
+	pages   [][]ThreadComment
+}
+
+// InvalidateThreadPages drops the cached thread layouts. Call it after adding comments to a topic,
+// removing them, or changing their order or parents.
+func InvalidateThreadPages() {
+	threadPagesMu.Lock()
+	threadPagesCache = map[string]threadLayout{}
+	threadPagesGen++
+	threadPagesMu.Unlock()
+}
+
+// ReadThreadPages lays out the comments of a topic as threads: sticky comments first, then each
+// comment followed by its replies, in the order they were posted. Pages hold about perPage
+// comments and only break between threads, so a page can be longer when a thread is. The pages
+// are shared with other callers and must not be changed.
+func ReadThreadPages(topicID string, perPage int) [][]ThreadComment {
+	threadPagesMu.RLock()
+	layout, ok := threadPagesCache[topicID]
+	gen := threadPagesGen
+	threadPagesMu.RUnlock()
+	if ok && layout.perPage == perPage {
+		return layout.pages
+	}
+	pages := layOutThreads(topicID, perPage)
+	threadPagesMu.Lock()
+	if gen == threadPagesGen {
+		if len(threadPagesCache) >= maxThreadLayouts {
+			threadPagesCache = map[string]threadLayout{}
+		}
+		threadPagesCache[topicID] = threadLayout{perPage: perPage, pages: pages}
+	}
+	threadPagesMu.Unlock()
+	return pages
+}
+
+func layOutThreads(topicID string, perPage int) [][]ThreadComment {
+	var order []string
+	parents := make(map[string]string)
+	rows := db.Query(`SELECT id, parentid FROM comments WHERE topicid=? ORDER BY pos;`, topicID)
+	for rows.Next() {
+		var id string
+		var parentID sql.NullString
+		rows.Scan(&id, &parentID)
+		order = append(order, id)
+		parents[id] = parentID.String
+	}
+	children := make(map[string][]string)
+	var roots []string
+	for _, id := range order {
+		if _, ok := parents[parents[id]]; ok {
+			children[parents[id]] = append(children[parents[id]], id)
+		} else {
+			roots = append(roots, id)
+		}
+	}
+
+	var walk func(id string, parentID string, depth int, thread []ThreadComment) []ThreadComment
+	walk = func(id string, parentID string, depth int, thread []ThreadComment) []ThreadComment {
+		thread = append(thread, ThreadComment{ID: id, ParentID: parentID, Depth: depth})
+		childDepth := depth + 1
+		if childDepth >= MaxThreadDepth {
+			childDepth = depth
+		}
+		for _, child := range children[id] {
+			thread = walk(child, id, childDepth, thread)
+		}
+		return thread
+	}
+	var pages [][]ThreadComment
+	var page []ThreadComment
+	for _, root := range roots {
+		thread := walk(root, "", 0, nil)
+		if len(page) > 0 && len(page)+len(thread) > perPage {
+			pages = append(pages, page)
+			page = nil
+		}
+		page = append(page, thread...)
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// ThreadPage returns the page of the threaded view of the topic that shows the comment.
+func ThreadPage(topicID string, commentID string, perPage int) int {
+	for i, page := range ReadThreadPages(topicID, perPage) {
+		for _, c := range page {
+			if c.ID == commentID {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// detachReplies makes the replies to the comments matching cond top-level comments, so that they
+// are not removed with them by the ON DELETE CASCADE foreign key.
+func detachReplies(cond string, args ...interface{}) {
+	db.Exec(`UPDATE comments SET parentid=NULL WHERE parentid IN (SELECT id FROM comments WHERE `+cond+`);`, args...)
+	InvalidateThreadPages()
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/s-gv/orangeforum/models/db"
+	"log"
+	"time"
+)
+
+// MoveTopic moves a topic and its comments to another group. Comment positions are unchanged.
+func MoveTopic(topicID string, groupID string) {
+	db.Exec(`UPDATE topics SET groupid=?, updated_date=? WHERE id=?;`, groupID, time.Now().Unix(), topicID)
+}
+
+// SplitTopic moves the comment and every comment posted after it, sticky or not, to a new topic
+// in the same group, owned by the author of the first moved comment. Replies whose parent ends up in the other
+// topic start a thread of their own. Subscribers of the old topic are subscribed to the new one.
+// It returns the new topic's ID.
+func SplitTopic(topicID string, commentID string, title string) string {
+	var groupID string
+	var ownerID, id, cDate int64
+	if db.QueryRow(`SELECT topics.groupid, comments.userid, comments.id, comments.created_date FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.id=? AND comments.topicid=?;`, commentID, topicID).Scan(
+		&groupID, &ownerID, &id, &cDate) != nil {
+		return ""
+	}
+	now := time.Now().Unix()
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		title, "", ownerID, groupID, cDate, now, now)
+	var newTopicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=? AND userid=? AND title=? ORDER BY id DESC LIMIT 1;`, groupID, ownerID, title).Scan(&newTopicID)
+
+	db.Exec(`UPDATE comments SET topicid=? WHERE topicid=? AND (created_date>? OR (created_date=? AND id>=?));`, newTopicID, topicID, cDate, cDate, id)
+	for _, id := range []string{topicID, newTopicID} {
+		db.Exec(`UPDATE comments SET parentid=NULL WHERE topicid=? AND parentid NOT IN (SELECT id FROM comments WHERE topicid=?);`, id, id)
+	}
+	copyTopicSubscriptions(topicID, newTopicID)
+	RenumberComments(topicID)
+	RenumberComments(newTopicID)
+	return newTopicID
+}
+
+// MergeTopics appends the source topic, as a comment, and its comments to the target topic in
+// the order they were posted. Subscribers are carried over, and links to the source topic
+// redirect to the target.
+func MergeTopics(sourceID string, targetID string) {
+	var ownerID, cDate int64
+	var content string
+	if db.QueryRow(`SELECT userid, content, created_date FROM topics WHERE id=?;`, sourceID).Scan(&ownerID, &content, &cDate) != nil {
+		return
+	}
+	if content != "" {
+		db.Exec(`INSERT INTO comments(content, image, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+			content, "", targetID, ownerID, 0, cDate, cDate)
+	}
+	db.Exec(`UPDATE comments SET topicid=? WHERE topicid=?;`, targetID, sourceID)
+	copyTopicSubscriptions(sourceID, targetID)
+	db.Exec(`UPDATE topicredirects SET newtopicid=? WHERE newtopicid=?;`, targetID, sourceID)
+	db.Exec(`INSERT INTO topicredirects(oldtopicid, newtopicid, created_date) VALUES(?, ?, ?);`, sourceID, targetID, time.Now().Unix())
+	db.Exec(`DELETE FROM topics WHERE id=?;`, sourceID)
+	RenumberComments(targetID)
+}
+
+// ReadTopicRedirect returns the topic that a merged topic now lives in, or "".
+func ReadTopicRedirect(topicID string) string {
+	var newTopicID string
+	db.QueryRow(`SELECT newtopicid FROM topicredirects WHERE oldtopicid=?;`, topicID).Scan(&newTopicID)
+	return newTopicID
+}
+
+// RenumberComments orders the comments of a topic by the time they were posted, keeping sticky
+// comments sticky, and updates the topic's comment count and activity date. Comments pending
+// approval keep their place but are not counted.
+func RenumberComments(topicID string) {
+	type comment struct {
+		id       string
+		isSticky bool
+	}
+	var comments []comment
+	var numPublished int
+	var lastDate int64
+	rows := db.Query(`SELECT id, pos, is_pending, is_shadowed, created_date FROM comments WHERE topicid=? ORDER BY created_date, id;`, topicID)
+	for rows.Next() {
+		var c comment
+		var pos int
+		var isPending, isShadowed bool
+		var cDate int64
+		rows.Scan(&c.id, &pos, &isPending, &isShadowed, &cDate)
+		c.isSticky = pos < 0
+		comments = append(comments, c)
+		if !isPending && !isShadowed {
+			numPublished++
+			lastDate = cDate
+		}
+	}
+	for i, c := range comments {
+		pos := i + 1
+		if c.isSticky {
+			pos = -pos
+		}
+		db.Exec(`UPDATE comments SET pos=? WHERE id=?;`, pos, c.id)
+	}
+	if numPublished > 0 {
+		db.Exec(`UPDATE topics SET num_comments=?, activity_date=? WHERE id=?;`, numPublished, lastDate, topicID)
+	} else {
+		db.Exec(`UPDATE topics SET num_comments=0, activity_date=created_date WHERE id=?;`, topicID)
+	}
+	InvalidateThreadPages()
+}
+
+func copyTopicSubscriptions(fromTopicID string, toTopicID string) {
+	var userIDs []int64
+	rows := db.Query(`SELECT userid FROM topicsubscriptions WHERE topicid=? AND userid NOT IN (SELECT userid FROM topicsubscriptions WHERE topicid=?);`, fromTopicID, toTopicID)
+	for rows.Next() {
+		var userID int64
+		rows.Scan(&userID)
+		userIDs = append(userIDs, userID)
+	}
+	for _, userID := range userIDs {
+		db.Exec(`INSERT INTO topicsubscriptions(userid, topicid, token, created_date) VALUES(?, ?, ?, ?);`, userID, toTopicID, randToken(64), time.Now().Unix())
+	}
+}
+
+func randToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("[ERROR] Unable to generate random number: %s\n", err.Error())
+	}
+	return base64.URLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// TrashItem is a deleted topic, comment, or group. Comments have the title of their topic.
+type TrashItem struct {
+	Type        string
+	ID          string
+	TopicID     string
+	Title       string
+	Content     string
+	GroupID     string
+	GroupName   string
+	OwnerName   string
+	DeletedDate time.Time
+}
+
+var maxTrashItems = 200
+
+// DeleteTopic moves the topic to the trash. It is purged when the retention period is over.
+func DeleteTopic(topicID string) {
+	db.Exec(`UPDATE topics SET is_deleted=1, deleted_date=? WHERE id=?;`, time.Now().Unix(), topicID)
+}
+
+func UndeleteTopic(topicID string) {
+	db.Exec(`UPDATE topics SET is_deleted=0, deleted_date=0 WHERE id=?;`, topicID)
+}
+
+func DeleteComment(commentID string) {
+	db.Exec(`UPDATE comments SET is_deleted=1, deleted_date=? WHERE id=?;`, time.Now().Unix(), commentID)
+}
+
+func UndeleteComment(commentID string) {
+	db.Exec(`UPDATE comments SET is_deleted=0, deleted_date=0 WHERE id=?;`, commentID)
+}
+
+func DeleteGroup(groupID string) {
+	db.Exec(`UPDATE groups SET is_closed=1, deleted_date=? WHERE id=?;`, time.Now().Unix(), groupID)
+}
+
+func UndeleteGroup(groupID string) {
+	db.Exec(`UPDATE groups SET is_closed=0, deleted_date=0 WHERE id=?;`, groupID)
+}
+
+// DeleteUserContent moves every topic and comment of the user to the trash.
+func DeleteUserContent(userID int64) {
+	now := time.Now().Unix()
+	db.Exec(`UPDATE topics SET is_deleted=1, deleted_date=? WHERE userid=? AND is_deleted=0;`, now, userID)
+	db.Exec(`UPDATE comments SET is_deleted=1, deleted_date=? WHERE userid=? AND is_deleted=0;`, now, userID)
+}
+
+// ReadTrash returns the deleted topics and comments of the group, most recently deleted first. If
+// groupID is empty, the deleted items of all groups and the deleted groups are returned.
+func ReadTrash(groupID string) []TrashItem {
+	cond := ""
+	var args []interface{}
+	if groupID != "" {
+		cond = " AND topics.groupid=?"
+		args = append(args, groupID)
+	}
+	args = append(args, maxTrashItems)
+
+	var items []TrashItem
+	rows := db.Query(`SELECT topics.id, topics.title, topics.content, topics.groupid, groups.name, users.username, topics.deleted_date FROM topics INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON topics.userid=users.id WHERE topics.is_deleted=1`+cond+` ORDER BY topics.deleted_date DESC LIMIT ?;`, args...)
+	for rows.Next() {
+		item := TrashItem{Type: "topic"}
+		var dDate int64
+		rows.Scan(&item.ID, &item.Title, &item.Content, &item.GroupID, &item.GroupName, &item.OwnerName, &dDate)
+		item.TopicID = item.ID
+		item.DeletedDate = time.Unix(dDate, 0)
+		items = append(items, item)
+	}
+	rows = db.Query(`SELECT comments.id, topics.id, topics.title, comments.content, topics.groupid, groups.name, users.username, comments.deleted_date FROM comments INNER JOIN topics ON comments.topicid=topics.id INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON comments.userid=users.id WHERE comments.is_deleted=1`+cond+` ORDER BY comments.deleted_date DESC LIMIT ?;`, args...)
+	for rows.Next() {
+		item := TrashItem{Type: "comment"}
+		var dDate int64
+		rows.Scan(&item.ID, &item.TopicID, &item.Title, &item.Content, &item.GroupID, &item.GroupName, &item.OwnerName, &dDate)
+		item.DeletedDate = time.Unix(dDate, 0)
+		items = append(items, item)
+	}
+	if groupID == "" {
+		rows = db.Query(`SELECT id, name, description, deleted_date FROM groups WHERE is_closed=1 ORDER BY deleted_date DESC LIMIT ?;`, maxTrashItems)
+		for rows.Next() {
+			item := TrashItem{Type: "group"}
+			var dDate int64
+			rows.Scan(&item.ID, &item.GroupName, &item.Content, &dDate)
+			item.GroupID = item.ID
+			item.DeletedDate = time.Unix(dDate, 0)
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedDate.After(items[j].DeletedDate) })
+	return items
+}
+
+// PurgeTrash permanently removes the topics, comments, and groups that have been in the trash for
+// longer than the retention period, along with their images. A retention of 0 days keeps them.
+func PurgeTrash() {
+	if ConfigInt(TrashRetentionDays) == 0 {
+		return
+	}
+	cutoff := time.Now().Add(-configDays(TrashRetentionDays)).Unix()
+	purgedCond := `((comments.is_deleted=1 AND comments.deleted_date < ?) OR (topics.is_deleted=1 AND topics.deleted_date < ?) OR (groups.is_closed=1 AND groups.deleted_date < ?))`
+
+	var images []string
+	rows := db.Query(`SELECT comments.image FROM comments INNER JOIN topics ON comments.topicid=topics.id INNER JOIN groups ON topics.groupid=groups.id WHERE comments.image != '' AND `+purgedCond+`;`, cutoff, cutoff, cutoff)
+	for rows.Next() {
+		var image string
+		rows.Scan(&image)
+		images = append(images, image)
+	}
+	rows = db.Query(`SELECT topics.image FROM topics INNER JOIN groups ON topics.groupid=groups.id WHERE topics.image != '' AND ((topics.is_deleted=1 AND topics.deleted_date < ?) OR (groups.is_closed=1 AND groups.deleted_date < ?));`, cutoff, cutoff)
+	for rows.Next() {
+		var image string
+		rows.Scan(&image)
+		images = append(images, image)
+	}
+	var topicIDs []string
+	rows = db.Query(`SELECT DISTINCT topicid FROM comments WHERE is_deleted=1 AND deleted_date < ?;`, cutoff)
+	for rows.Next() {
+		var topicID string
+		rows.Scan(&topicID)
+		topicIDs = append(topicIDs, topicID)
+	}
+
+	purgedTopicsCond := `(is_deleted=1 AND deleted_date < ?) OR groupid IN (SELECT id FROM groups WHERE is_closed=1 AND deleted_date < ?)`
+	db.Exec(`DELETE FROM comments WHERE topicid IN (SELECT id FROM topics WHERE `+purgedTopicsCond+`);`, cutoff, cutoff)
+	db.Exec(`DELETE FROM topics WHERE `+purgedTopicsCond+`;`, cutoff, cutoff)
+	db.Exec(`DELETE FROM groups WHERE is_closed=1 AND deleted_date < ?;`, cutoff)
+	detachReplies(`is_deleted=1 AND deleted_date < ?`, cutoff)
+	db.Exec(`DELETE FROM comments WHERE is_deleted=1 AND deleted_date < ?;`, cutoff)
+	deleteOrphanRevisions()
+	deleteOrphanMentions()
+	for _, topicID := range topicIDs {
+		var tmp string
+		if db.QueryRow(`SELECT id FROM topics WHERE id=?;`, topicID).Scan(&tmp) == nil {
+			RenumberComments(topicID)
+		}
+	}
+	if dataDir := Config(DataDir); dataDir != "" {
+		for _, image := range images {
+			if err := os.Remove(dataDir + image); err != nil {
+				log.Printf("[ERROR] Error removing image %s: %s\n", image, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package models
+
+import (
+	"github.com/s-gv/orangeforum/models/db"
+	"strconv"
+	"time"
+)
+
+// Trust levels. New users have limits on links, image uploads, private messages and how many
+// topics they start. Basic users have no such limits. Members are long-standing users.
+const (
+	TrustLevelAuto   int = -1
+	TrustLevelNew    int = 0
+	TrustLevelBasic  int = 1
+	TrustLevelMember int = 2
+)
+
+var trustLevelNames = map[int]string{
+	TrustLevelNew:    "new",
+	TrustLevelBasic:  "basic",
+	TrustLevelMember: "member",
+}
+
+func TrustLevelName(level int) string {
+	return trustLevelNames[level]
+}
+
+func IsValidTrustLevel(level int) bool {
+	_, ok := trustLevelNames[level]
+	return ok || level == TrustLevelAuto
+}
+
+// ConfigInt returns the config value as a number, or 0 if it is not a non-negative number.
+func ConfigInt(key string) int {
+	n, err := strconv.Atoi(Config(key))
+	if err != nil || n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// ReadTrustLevelOverride returns the trust level set by an admin, or TrustLevelAuto.
+func ReadTrustLevelOverride(userID int64) int {
+	level := TrustLevelAuto
+	db.QueryRow(`SELECT trust_level FROM users WHERE id=?;`, userID).Scan(&level)
+	return level
+}
+
+func SetTrustLevelOverride(userID int64, level int) {
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, level, userID)
+}
+
+// ReadTrustLevel returns the trust level of the user. Unless overridden by an admin, it is computed
+// from the account age and the number of topics and comments. Users banned or muted within the
+// member threshold period stay new, and superadmins, mods and admins are always members.
+func ReadTrustLevel(userID int64) int {
+	level := TrustLevelAuto
+	var isSuperAdmin bool
+	var cDate int64
+	if db.QueryRow(`SELECT trust_level, is_superadmin, created_date FROM users WHERE id=?;`, userID).Scan(&level, &isSuperAdmin, &cDate) != nil {
+		return TrustLevelNew
+	}
+	if level != TrustLevelAuto {
+		return level
+	}
+	var tmp string
+	if isSuperAdmin || db.QueryRow(`SELECT id FROM mods WHERE userid=? UNION SELECT id FROM admins WHERE userid=?;`, userID, userID).Scan(&tmp) == nil {
+		return TrustLevelMember
+	}
+	since := time.Now().Add(-configDays(TrustMemberDays)).Unix()
+	if db.QueryRow(`SELECT id FROM bans WHERE userid=? AND created_date>? UNION SELECT id FROM groupbans WHERE userid=? AND created_date>?;`,
+		userID, since, userID, since).Scan(&tmp) == nil {
+		return TrustLevelNew
+	}
+
+	var numTopics, numComments int
+	db.QueryRow(`SELECT COUNT(*) FROM topics WHERE userid=? AND is_deleted=0 AND is_pending=0 AND is_shadowed=0;`, userID).Scan(&numTopics)
+	db.QueryRow(`SELECT COUNT(*) FROM comments WHERE userid=? AND is_deleted=0 AND is_pending=0 AND is_shadowed=0;`, userID).Scan(&numComments)
+	numPosts := numTopics + numComments
+	age := time.Since(time.Unix(cDate, 0))
+	if age >= configDays(TrustMemberDays) && numPosts >= ConfigInt(TrustMemberPosts) {
+		return TrustLevelMember
+	}
+	if age >= configDays(TrustBasicDays) && numPosts >= ConfigInt(TrustBasicPosts) {
+		return TrustLevelBasic
+	}
+	return TrustLevelNew
+}
@@ -10,6 +10,9 @@ import (
 	"errors"
 	"github.com/s-gv/orangeforum/models/db"
 	"golang.org/x/crypto/bcrypt"
+	"log"
+	"os"
+	"strconv"
 	"time"
 )
 
@@ -87,3 +90,144 @@ func ProbeUser(userName string) bool {
 	}
 	return true
 }
+
+// DeletedUserName is the placeholder account that anonymized content is re-assigned to.
+// It contains characters that are not allowed at signup so it can never be registered.
+const DeletedUserName = "[deleted]"
+
+func readDeletedUserID() int64 {
+	var id int64
+	if db.QueryRow(`SELECT id FROM users WHERE username=?;`, DeletedUserName).Scan(&id) == nil {
+		return id
+	}
+	db.Exec(`INSERT INTO users(username, passwdhash, is_banned, created_date, updated_date) VALUES(?, ?, ?, ?, ?);`,
+		DeletedUserName, "", true, time.Now().Unix(), time.Now().Unix())
+	db.QueryRow(`SELECT id FROM users WHERE username=?;`, DeletedUserName).Scan(&id)
+	return id
+}
+
+func ScheduleUserDeletion(userID int64) time.Time {
+	deleteDate := time.Now().Add(configDays(AccountDeletionGraceDays))
+	db.Exec(`UPDATE users SET delete_date=? WHERE id=?;`, deleteDate.Unix(), userID)
+	return deleteDate
+}
+
+func CancelUserDeletion(userID int64) {
+	db.Exec(`UPDATE users SET delete_date=0 WHERE id=?;`, userID)
+}
+
+// DeleteUser removes the user account. Depending on the account deletion policy, topics and
+// comments are either re-assigned to the DeletedUserName placeholder or removed along with
+// the account. Replies by others to removed comments are kept as top-level comments.
+func DeleteUser(userID int64) {
+	if Config(AccountDeletionPolicy) == AccountDeletionHardDelete {
+		var topicIDs []int64
+		var images []string
+		rows := db.Query(`SELECT DISTINCT topicid FROM comments WHERE userid=?;`, userID)
+		for rows.Next() {
+			var topicID int64
+			rows.Scan(&topicID)
+			topicIDs = append(topicIDs, topicID)
+		}
+		rows = db.Query(`SELECT comments.image FROM comments LEFT JOIN topics ON comments.topicid=topics.id WHERE (comments.userid=? OR topics.userid=?) AND comments.image != '';`, userID, userID)
+		for rows.Next() {
+			var image string
+			rows.Scan(&image)
+			images = append(images, image)
+		}
+		rows = db.Query(`SELECT image FROM topics WHERE userid=? AND image != '';`, userID)
+		for rows.Next() {
+			var image string
+			rows.Scan(&image)
+			images = append(images, image)
+		}
+
+		detachReplies(`userid=?`, userID)
+		db.Exec(`DELETE FROM comments WHERE userid=? OR topicid IN (SELECT id FROM topics WHERE userid=?);`, userID, userID)
+		db.Exec(`DELETE FROM topics WHERE userid=?;`, userID)
+		db.Exec(`DELETE FROM users WHERE id=?;`, userID)
+
+		deleteOrphanRevisions()
+		deleteOrphanMentions()
+		for _, topicID := range topicIDs {
+			var tmp string
+			if db.QueryRow(`SELECT id FROM topics WHERE id=?;`, topicID).Scan(&tmp) == nil {
+				RenumberComments(strconv.FormatInt(topicID, 10))
+			}
+		}
+		if dataDir := Config(DataDir); dataDir != "" {
+			for _, image := range images {
+				if err := os.Remove(dataDir + image); err != nil {
+					log.Printf("[ERROR] Error removing image %s: %s\n", image, err)
+				}
+			}
+		}
+	} else {
+		deletedUserID := readDeletedUserID()
+		db.Exec(`UPDATE topics SET userid=? WHERE userid=?;`, deletedUserID, userID)
+		db.Exec(`UPDATE comments SET userid=? WHERE userid=?;`, deletedUserID, userID)
+		db.Exec(`UPDATE revisions SET userid=? WHERE userid=?;`, deletedUserID, userID)
+		db.Exec(`DELETE FROM users WHERE id=?;`, userID)
+	}
+}
+
+// DeleteExpiredUsers deletes accounts whose deletion grace period is over.
+func DeleteExpiredUsers() {
+	var userIDs []int64
+	rows := db.Query(`SELECT id FROM users WHERE delete_date > 0 AND delete_date < ?;`, time.Now().Unix())
+	for rows.Next() {
+		var userID int64
+		rows.Scan(&userID)
+		userIDs = append(userIDs, userID)
+	}
+	for _, userID := range userIDs {
+		log.Printf("[INFO] Deleting user %d\n", userID)
+		DeleteUser(userID)
+	}
+}
+
+func configDays(key string) time.Duration {
+	days, err := strconv.Atoi(Config(key))
+	if err != nil || days < 0 {
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RenameUser changes the username and remembers the old one so that old profile links keep working.
+func RenameUser(userID int64, newName string) {
+	var oldName string
+	if db.QueryRow(`SELECT username FROM users WHERE id=?;`, userID).Scan(&oldName) != nil {
+		return
+	}
+	db.Exec(`INSERT INTO usernamehistory(userid, username, created_date) VALUES(?, ?, ?);`, userID, oldName, time.Now().Unix())
+	db.Exec(`UPDATE users SET username=?, updated_date=? WHERE id=?;`, newName, time.Now().Unix(), userID)
+}
+
+// ReadUserNameByOldName returns the current username of the account that last used oldName.
+func ReadUserNameByOldName(oldName string) (string, error) {
+	r := db.QueryRow(`SELECT users.username FROM usernamehistory INNER JOIN users ON usernamehistory.userid=users.id WHERE usernamehistory.username=? ORDER BY usernamehistory.created_date DESC LIMIT 1;`, oldName)
+	var userName string
+	if err := r.Scan(&userName); err == nil {
+		return userName, nil
+	}
+	return "", errors.New("User not found.")
+}
+
+// IsUserNameOnCooldown reports whether userName was released by another account too recently to be taken.
+func IsUserNameOnCooldown(userName string, userID int64) bool {
+	r := db.QueryRow(`SELECT userid FROM usernamehistory WHERE username=? AND userid!=? AND created_date > ? LIMIT 1;`,
+		userName, userID, time.Now().Add(-configDays(UserNameCooldown)).Unix())
+	var tmp int64
+	return r.Scan(&tmp) == nil
+}
+
+// NextUserNameChangeDate returns the earliest time the user is allowed to rename the account again.
+func NextUserNameChangeDate(userID int64) time.Time {
+	r := db.QueryRow(`SELECT created_date FROM usernamehistory WHERE userid=? ORDER BY created_date DESC LIMIT 1;`, userID)
+	var lastChange int64
+	if r.Scan(&lastChange) != nil {
+		return time.Time{}
+	}
+	return time.Unix(lastChange, 0).Add(configDays(UserNameChangeInterval))
+}
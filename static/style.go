@@ -193,18 +193,77 @@ textarea {
 .comment p:first-child {
 	margin-top: 10px;
 }
+.comment pre {
+	overflow-x: auto;
+	padding: 5px;
+	background-color: #f6f6f6;
+}
+.comment blockquote {
+	margin-left: 0px;
+	padding-left: 10px;
+	border-left: 3px solid #ddd;
+	color: #555;
+}
+.comment table {
+	border-collapse: collapse;
+}
+.comment th, .comment td {
+	border: 1px solid #ddd;
+	padding: 3px 6px;
+}
+.comment img {
+	max-width: 100%;
+}
 .comment-row {
 	margin-bottom: 30px;
 }
 .comment-title {
 	font-size: 90%;
 }
+.replies {
+	margin-left: 20px;
+	padding-left: 10px;
+	border-left: 2px solid #e9e9e9;
+}
+.replies summary {
+	font-size: 90%;
+	margin-bottom: 10px;
+	cursor: pointer;
+}
 .topic-row {
 
 }
 .alert {
 	color: red;
 }
+#impersonation {
+	background: #fe9;
+	padding: 10px;
+	text-align: center;
+}
+#impersonation form {
+	margin: 0;
+}
+table.diff {
+	width: 100%;
+	table-layout: fixed;
+	border-collapse: collapse;
+	font-family: monospace;
+	font-size: 90%;
+}
+.diff td {
+	width: 50%;
+	vertical-align: top;
+	white-space: pre-wrap;
+	word-wrap: break-word;
+	padding: 0 5px;
+}
+.diff .del {
+	background: #fdd;
+}
+.diff .add {
+	background: #dfd;
+}
 a, .muted, h3, .comment p {
 	word-wrap: break-word;
 }
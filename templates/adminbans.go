@@ -0,0 +1,82 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const adminbansSrc = `
+{{ define "content" }}
+
+<h1>New ban</h1>
+
+<form action="/admin/bans" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th><label for="username">Username:</label></th>
+		<td><input type="text" name="username" id="username"></td>
+	</tr>
+	<tr>
+		<th><label for="network">IP, CIDR or i2p destination:</label></th>
+		<td><input type="text" name="network" id="network" placeholder="203.0.113.0/24"></td>
+	</tr>
+	<tr>
+		<th><label for="reason">Reason:</label></th>
+		<td><input type="text" name="reason" id="reason" placeholder="Spam"></td>
+	</tr>
+	<tr>
+		<th><label for="days">Ban for (days, 0 = forever):</label></th>
+		<td><input type="number" name="days" id="days" min="0" value="0"></td>
+	</tr>
+{{ if .Common.Msg }}
+	<tr>
+		<th></th>
+		<td><span class="alert">{{ .Common.Msg }}</span></td>
+	</tr>
+{{ end }}
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Ban"></td>
+	</tr>
+</table>
+</form>
+
+<h1>Bans</h1>
+
+{{ if .Bans }}
+{{ range .Bans }}
+<div class="row">
+	<div>
+		{{ if .UserName }}<a href="/users?u={{ .UserName }}">{{ .UserName }}</a>{{ end }}
+		{{ if .Network }}{{ .Network }}{{ end }}
+		{{ if .IsActive }}{{ if .ExpiryDate }}until {{ .ExpiryDate }}{{ else }}forever{{ end }}{{ else }}{{ if .IsLifted }}[lifted]{{ else }}[expired]{{ end }}{{ end }}
+	</div>
+	<div class="muted">
+		{{ if .Reason }}{{ .Reason }} | {{ end }}{{ if .AdminName }}by <a href="/users?u={{ .AdminName }}">{{ .AdminName }}</a> {{ end }}on {{ .CreatedDate }}
+		{{ if .IsActive }}
+		<form action="/admin/bans" method="POST" style="display: inline;">
+			<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+			<input type="hidden" name="id" value="{{ .ID }}">
+			| <button type="submit" name="action" value="Lift" class="link-button">lift</button>
+		</form>
+		{{ end }}
+	</div>
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No bans.</div>
+</div>
+{{ end }}
+
+<h1>Shadow-banned users</h1>
+
+<div class="row">
+{{ if .ShadowBanned }}
+	{{ range .ShadowBanned }}<a href="/users?u={{ . }}">{{ . }}</a> {{ end }}
+{{ else }}
+	<div class="muted">No shadow-banned users.</div>
+{{ end }}
+</div>
+
+{{ end }}`
@@ -7,6 +7,16 @@ package templates
 const adminindexSrc = `
 {{ define "content" }}
 
+<div class="btn-row">
+	<a class="link-btn" href="/admin/bans">Bans</a>
+	<a class="link-btn" href="/admin/roles">Roles</a>
+	<a class="link-btn" href="/audit">Audit log</a>
+	<a class="link-btn" href="/trash">Trash</a>
+	<a class="link-btn" href="/rules">Content rules</a>
+	<a class="link-btn" href="/reports">Reports{{ if .NumReports }} ({{ .NumReports }}){{ end }}</a>
+	<a class="link-btn" href="/queue">Queue{{ if .NumPending }} ({{ .NumPending }}){{ end }}</a>
+</div>
+
 <h1>Config</h1>
 
 <form action="/admin" method="POST">
@@ -28,10 +38,6 @@ const adminindexSrc = `
 		<th><label for="signup_msg">Signup message:</label></th>
 		<td><input type="text" name="signup_msg" id="signup_msg" value="{{ index .Config "signup_msg" }}"></td>
 	</tr>
-	<tr>
-		<th><label for="censored_words"><div class="col-label">Censored words:</label></th>
-		<td><textarea name="censored_words" id="censored_words" rows="4" placeholder="shit, bitch, poop">{{ index .Config "censored_words" }}</textarea></td>
-	</tr>
 	<tr>
 		<th><label for="body_appendage"><div class="col-label">Body Appendage:</label></th>
 		<td><textarea name="body_appendage" id="body_appendage" rows="4" placeholder="<script>Analytics or something</script>">{{ index .Config "body_appendage" }}</textarea></td>
@@ -60,6 +66,90 @@ const adminindexSrc = `
 		<th><label for="smtp_pass">SMTP Password:</label></th>
 		<td><input type="text" name="smtp_pass" id="smtp_pass" value="{{ index .Config "smtp_pass" }}"></td>
 	</tr>
+	<tr>
+		<th><label for="account_deletion_policy">Deleted accounts:</label></th>
+		<td>
+			<select name="account_deletion_policy" id="account_deletion_policy">
+				<option value="anonymize"{{ if eq (index .Config "account_deletion_policy") "anonymize" }} selected{{ end }}>Anonymize their posts</option>
+				<option value="delete"{{ if eq (index .Config "account_deletion_policy") "delete" }} selected{{ end }}>Delete their posts</option>
+			</select>
+		</td>
+	</tr>
+	<tr>
+		<th><label for="account_deletion_grace_days">Account deletion grace period (days):</label></th>
+		<td><input type="number" name="account_deletion_grace_days" id="account_deletion_grace_days" min="0" value="{{ index .Config "account_deletion_grace_days" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="username_change_interval">Days between username changes:</label></th>
+		<td><input type="number" name="username_change_interval" id="username_change_interval" min="0" value="{{ index .Config "username_change_interval" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="username_cooldown">Days before a released username can be taken:</label></th>
+		<td><input type="number" name="username_cooldown" id="username_cooldown" min="0" value="{{ index .Config "username_cooldown" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="trust_basic_days">Days before a new user becomes a basic user:</label></th>
+		<td><input type="number" name="trust_basic_days" id="trust_basic_days" min="0" value="{{ index .Config "trust_basic_days" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="trust_basic_posts">Posts before a new user becomes a basic user:</label></th>
+		<td><input type="number" name="trust_basic_posts" id="trust_basic_posts" min="0" value="{{ index .Config "trust_basic_posts" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="trust_member_days">Days before a user becomes a member:</label></th>
+		<td><input type="number" name="trust_member_days" id="trust_member_days" min="0" value="{{ index .Config "trust_member_days" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="trust_member_posts">Posts before a user becomes a member:</label></th>
+		<td><input type="number" name="trust_member_posts" id="trust_member_posts" min="0" value="{{ index .Config "trust_member_posts" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="new_user_max_links">Links allowed per post for new users:</label></th>
+		<td><input type="number" name="new_user_max_links" id="new_user_max_links" min="0" value="{{ index .Config "new_user_max_links" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="new_user_topics_per_day">Topics new users can start per day:</label></th>
+		<td><input type="number" name="new_user_topics_per_day" id="new_user_topics_per_day" min="0" value="{{ index .Config "new_user_topics_per_day" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="premod_level">Hold posts for approval in all groups:</label></th>
+		<td>
+			<select name="premod_level" id="premod_level">
+				<option value="0"{{ if eq (index .Config "premod_level") "0" }} selected{{ end }}>Off</option>
+				<option value="1"{{ if eq (index .Config "premod_level") "1" }} selected{{ end }}>From new users</option>
+				<option value="2"{{ if eq (index .Config "premod_level") "2" }} selected{{ end }}>From new and basic users</option>
+				<option value="3"{{ if eq (index .Config "premod_level") "3" }} selected{{ end }}>From everyone except mods</option>
+			</select>
+		</td>
+	</tr>
+	<tr>
+		<th><label for="spam_threshold">Hold posts with a spam score of at least (%, 0 = off):</label></th>
+		<td><input type="number" name="spam_threshold" id="spam_threshold" min="0" max="100" value="{{ index .Config "spam_threshold" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="trash_retention_days">Purge deleted posts and groups after (days, 0 = never):</label></th>
+		<td><input type="number" name="trash_retention_days" id="trash_retention_days" min="0" value="{{ index .Config "trash_retention_days" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="topics_per_hour">Topics a user can start per hour (0 = no limit):</label></th>
+		<td><input type="number" name="topics_per_hour" id="topics_per_hour" min="0" value="{{ index .Config "topics_per_hour" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="comments_per_hour">Comments a user can post per hour (0 = no limit):</label></th>
+		<td><input type="number" name="comments_per_hour" id="comments_per_hour" min="0" value="{{ index .Config "comments_per_hour" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="messages_per_hour">Private messages a user can send per hour (0 = no limit):</label></th>
+		<td><input type="number" name="messages_per_hour" id="messages_per_hour" min="0" value="{{ index .Config "messages_per_hour" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="mentions_per_post">Users a post can @mention (0 = no limit):</label></th>
+		<td><input type="number" name="mentions_per_post" id="mentions_per_post" min="0" value="{{ index .Config "mentions_per_post" }}"></td>
+	</tr>
+	<tr>
+		<th><label for="mentions_per_hour">Users a user can @mention per hour (0 = no limit):</label></th>
+		<td><input type="number" name="mentions_per_hour" id="mentions_per_hour" min="0" value="{{ index .Config "mentions_per_hour" }}"></td>
+	</tr>
 	<tr>
 		<th><label for="read_only">Read-only mode:</label></th>
 		<td><input type="checkbox" name="read_only" id="read_only" value="1"{{ if index .Config "read_only" }} checked{{ end }}></td>
@@ -68,6 +158,14 @@ const adminindexSrc = `
 		<th><label for="signup_disabled">Signup disabled:</label></th>
 		<td><input type="checkbox" name="signup_disabled" id="signup_disabled" value="1"{{ if index .Config "signup_disabled" }} checked{{ end }}></td>
 	</tr>
+	<tr>
+		<th><label for="allow_username_change">Allow users to change username:</label></th>
+		<td><input type="checkbox" name="allow_username_change" id="allow_username_change" value="1"{{ if index .Config "allow_username_change" }} checked{{ end }}></td>
+	</tr>
+	<tr>
+		<th><label for="allow_impersonation_writes">Allow posting while viewing as another user:</label></th>
+		<td><input type="checkbox" name="allow_impersonation_writes" id="allow_impersonation_writes" value="1"{{ if index .Config "allow_impersonation_writes" }} checked{{ end }}></td>
+	</tr>
 	<tr>
 		<th><label for="group_creation_disabled">Group creation disabled:</label></th>
 		<td><input type="checkbox" name="group_creation_disabled" id="group_creation_disabled" value="1"{{ if index .Config "group_creation_disabled" }} checked{{ end }}></td>
@@ -150,6 +248,24 @@ const adminindexSrc = `
 </table>
 </form>
 
+<h1>View-as log</h1>
+{{ if .Impersonations }}
+<table>
+	{{ range .Impersonations }}
+	<tr>
+		<td>{{ .CreatedDate }}</td>
+		<td><a href="/users?u={{ .AdminName }}">{{ .AdminName }}</a></td>
+		<td>{{ .Action }}</td>
+		<td><a href="/users?u={{ .UserName }}">{{ .UserName }}</a></td>
+	</tr>
+	{{ end }}
+</table>
+{{ else }}
+<div class="row">
+	<div class="muted">No view-as sessions yet.</div>
+</div>
+{{ end }}
+
 <h1>Stats</h1>
 <table>
 	<tr>
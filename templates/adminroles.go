@@ -0,0 +1,93 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const adminrolesSrc = `
+{{ define "content" }}
+
+<h1>Roles</h1>
+
+{{ if .Common.Msg }}
+<div class="row"><span class="alert">{{ .Common.Msg }}</span></div>
+{{ end }}
+
+<div class="muted">Every user has the user role. Superadmins, and the admins and mods listed on a group's edit page, have the superadmin, admin and mod roles.</div>
+
+{{ range $role := .Roles }}
+<h2>{{ $role.Name }}</h2>
+<form action="/admin/roles" method="POST">
+<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+<input type="hidden" name="id" value="{{ $role.ID }}">
+<div class="row">
+{{ range $.Capabilities }}
+	<label><input type="checkbox" name="cap_{{ . }}"{{ if index $role.Capabilities . }} checked{{ end }}{{ if eq $role.Name $.SuperAdmin }} disabled{{ end }}> {{ . }}</label>
+{{ end }}
+</div>
+{{ if ne $role.Name $.SuperAdmin }}
+<div class="row">
+	<input type="submit" name="action" value="Save">
+	{{ if not $role.IsSeeded }}<input type="submit" name="action" value="Delete">{{ end }}
+</div>
+{{ end }}
+</form>
+{{ end }}
+
+<h2>New role</h2>
+<form action="/admin/roles" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th><label for="name">Name:</label></th>
+		<td><input type="text" name="name" id="name" placeholder="helper"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Create role"></td>
+	</tr>
+</table>
+</form>
+
+<h1>Assignments</h1>
+<form action="/admin/roles" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th><label for="username">Username:</label></th>
+		<td><input type="text" name="username" id="username"></td>
+	</tr>
+	<tr>
+		<th><label for="role">Role:</label></th>
+		<td><select name="role" id="role">
+		{{ range .Roles }}<option value="{{ .Name }}">{{ .Name }}</option>{{ end }}
+		</select></td>
+	</tr>
+	<tr>
+		<th><label for="group">Group (blank = whole site):</label></th>
+		<td><input type="text" name="group" id="group"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Assign"></td>
+	</tr>
+</table>
+</form>
+
+{{ range .UserRoles }}
+<div class="row">
+	<a href="/users?u={{ .UserName }}">{{ .UserName }}</a> is {{ .RoleName }}
+	{{ if .GroupName }}in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}site-wide{{ end }}
+	<form action="/admin/roles" method="POST" style="display: inline;">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="id" value="{{ .ID }}">
+		| <button type="submit" name="action" value="Unassign" class="link-button">remove</button>
+	</form>
+</div>
+{{ else }}
+<div class="row">
+	<div class="muted">No role assignments.</div>
+</div>
+{{ end }}
+
+{{ end }}`
@@ -0,0 +1,40 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const auditSrc = `
+{{ define "content" }}
+
+<h1>{{ if .GroupName }}Audit log of <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}Audit log{{ end }}</h1>
+<div class="row">
+	<div class="muted"><a href="/audit?format=csv{{ if .GroupID }}&gid={{ .GroupID }}{{ end }}">download as CSV</a></div>
+</div>
+
+{{ if .Entries }}
+{{ range .Entries }}
+<div class="row">
+	<div>
+		{{ if .ActorName }}<a href="/users?u={{ .ActorName }}">{{ .ActorName }}</a>{{ else }}[deleted user]{{ end }}
+		{{ .Action }}
+		{{ if eq .TargetType "topic" }}<a href="/topics?id={{ .TargetID }}">topic {{ .TargetID }}</a>
+		{{ else if eq .TargetType "comment" }}<a href="/comments?id={{ .TargetID }}">comment {{ .TargetID }}</a>
+		{{ else }}{{ .TargetType }} {{ .TargetID }}{{ end }}
+		{{ if and .GroupName (not $.GroupID) }}in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ end }}
+	</div>
+	{{ if .Before }}<div class="muted">before: {{ .Before }}</div>{{ end }}
+	{{ if .After }}<div class="muted">after: {{ .After }}</div>{{ end }}
+	<div class="muted">{{ .CreatedDate }}</div>
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No actions logged.</div>
+</div>
+{{ end }}
+{{ if .LastCreatedDate }}
+<div><a href="/audit?lcd={{ .LastCreatedDate }}&lid={{ .LastID }}{{ if .GroupID }}&gid={{ .GroupID }}{{ end }}">More</a></div>
+{{ end }}
+
+{{ end }}`
@@ -10,7 +10,7 @@ const baseSrc = `<!DOCTYPE html>
 	<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
 	<meta http-equiv="X-UA-Compatible" content="IE=edge">
 	<meta name="viewport" content="width=device-width, initial-scale=1">
-	<link rel="stylesheet" type="text/css" href="/static/css/orangeforum.css?v=140">
+	<link rel="stylesheet" type="text/css" href="/static/css/orangeforum.css?v=141">
 	<title>
 		{{ if .Common.PageTitle }}
 			{{ .Common.PageTitle }}
@@ -29,13 +29,22 @@ const baseSrc = `<!DOCTYPE html>
 			</div>
 			<div id="navright">
 				{{ if .Common.UserName }}
-				<a href="/users?u={{ .Common.UserName }}">{{ .Common.UserName }}{{ if .Common.IsNotification }}<span class="alert">&#x2757</span>{{ end }}</a>
+				<a href="/users?u={{ .Common.UserName }}">{{ .Common.UserName }}{{ if or .Common.IsNotification .Common.IsMentioned }}<span class="alert">&#x2757</span>{{ end }}</a>
 				{{ else }}
 				<a href="/login?next={{ .Common.CurrentURL }}">Login</a>
 				{{ end }}
 			</div>
 		</div>
 		<hr>
+		{{ if .Common.ImpersonatorName }}
+		<div id="impersonation">
+			<form action="/users/impersonate/end" method="POST">
+				<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+				Viewing the forum as <b>{{ .Common.UserName }}</b> until {{ .Common.ImpersonationEnd }} ({{ .Common.ImpersonatorName }}).
+				<input type="submit" value="End">
+			</form>
+		</div>
+		{{ end }}
 		<div id="content">
 		{{ block "content" . }}{{ end }}
 		</div>
@@ -46,7 +55,7 @@ const baseSrc = `<!DOCTYPE html>
 		{{ end }}
 		</div>
 	</div>
-	<script src="/static/js/orangeforum.js?v=140"></script>
+	<script src="/static/js/orangeforum.js?v=141"></script>
 	{{ .Common.BodyAppendage }}
 </body>
 </html>`
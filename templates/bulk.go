@@ -0,0 +1,67 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const bulkSrc = `
+{{ define "content" }}
+
+<form action="/bulk" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="type" value="{{ .Type }}">
+<input type="hidden" name="action" value="{{ .Action }}">
+<input type="hidden" name="next" value="{{ .Next }}">
+<input type="hidden" name="confirm" value="1">
+{{ if eq .Type "user" }}
+<h1>Nuke {{ .UserName }}?</h1>
+<input type="hidden" name="ids" value="{{ .UserID }}">
+<div class="row">
+	<div>{{ .UserName }} will be banned forever, and their {{ .NumTopics }} topics and {{ .NumComments }} comments will be moved to the trash.</div>
+</div>
+<table class="form">
+	<tr>
+		<th><label for="ban_reason">Ban reason:</label></th>
+		<td><input type="text" name="ban_reason" id="ban_reason" value="Spam"></td>
+	</tr>
+</table>
+{{ else }}
+<h1>{{ .Action }}{{ if eq .Action "Move" }} to {{ .Group }}{{ end }} {{ len .Items }} {{ .Type }}s?</h1>
+<input type="hidden" name="group" value="{{ .Group }}">
+{{ if .NumSkipped }}
+<div class="row">
+	<div class="alert">{{ .NumSkipped }} selected {{ .Type }}s will be skipped because you cannot do this to them.</div>
+</div>
+{{ end }}
+{{ range .Items }}
+<div class="row">
+	<input type="hidden" name="ids" value="{{ .ID }}">
+	<div>{{ if eq $.Type "comment" }}comment in {{ end }}<a href="/topics?id={{ .TopicID }}">{{ .Title }}</a></div>
+	{{ if .Content }}<div class="muted">{{ .Content }}</div>{{ end }}
+</div>
+{{ end }}
+{{ end }}
+<div class="row">
+	<input type="submit" value="Confirm">
+	<a href="{{ .Next }}">Cancel</a>
+</div>
+</form>
+
+{{ end }}`
+
+const bulkcontrolsSrc = `
+{{ define "bulkcontrols" }}
+<div class="row">
+	<select name="action">
+		<option value="Delete">Delete</option>
+		<option value="Spam">Delete as spam</option>
+		{{ if eq . "topic" }}
+		<option value="Close">Close</option>
+		<option value="Reopen">Reopen</option>
+		<option value="Move">Move to group</option>
+		{{ end }}
+	</select>
+	{{ if eq . "topic" }}<input type="text" name="group" placeholder="Group to move to">{{ end }}
+	<input type="submit" value="Apply to selected">
+</div>
+{{ end }}`
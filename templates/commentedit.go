@@ -17,26 +17,39 @@ const commenteditSrc = `
 	<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
 	<input type="hidden" name="id" value="{{ .CommentID }}">
 	<input type="hidden" name="tid" value="{{ .TopicID }}">
-	<textarea name="content" rows="12">{{ .Content }}</textarea>
+	{{ if .ReplyTo }}<input type="hidden" name="parent" value="{{ .ReplyTo }}">{{ end }}
+	<textarea name="content" rows="12"{{ if and .CommentID (not .CanEdit) }} readonly{{ end }}>{{ .Content }}</textarea>
 
 	{{ if .IsImageUploadEnabled }}
 	<div>Add Image (optional): <input type="file" name="img" accept="image/*"></div>
 	{{ end }}
 
-	{{ if or .IsMod .IsAdmin .IsSuperAdmin }}
+	{{ if .CanSticky }}
 	<div><input type="checkbox" name="is_sticky"{{ if .IsSticky }} checked{{ end }}> Sticky</div>
 	{{ end }}
 
+	{{ if .IsStale }}
+	<div><input type="checkbox" name="confirm_stale" id="confirm_stale" value="1"> <label for="confirm_stale">This topic has had no activity for more than {{ .StaleDays }} days. Reply anyway.</label></div>
+	{{ end }}
+
 	<span class="alert">{{ .Common.Msg }}</span>
 
 	<div>
 	{{ if .CommentID }}
 		{{ if not .IsDeleted }}
+		{{ if or .CanEdit .CanSticky }}
 		<input type="submit" name="action" value="Update">
+		{{ end }}
+		{{ if .CanDelete }}
 		<input type="submit" name="action" value="Delete">
-		{{ else }}
+		{{ end }}
+		{{ else if .CanDelete }}
 		<input type="submit" name="action" value="Undelete">
 		{{ end }}
+		{{ if .CanMarkSpam }}
+		<input type="submit" name="action" value="Spam">
+		<input type="submit" name="action" value="Not spam">
+		{{ end }}
 	{{ else }}
 	<input type="submit" name="action" class="no-double-post" value="Submit reply">
 	{{ end }}
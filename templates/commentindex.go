@@ -9,8 +9,12 @@ const commentindexSrc = `
 
 <div class="row">
 	<div class="muted">
-		comment by <a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a> in <a href="/topics?id={{ .TopicID }}">{{ .TopicName }}</a> {{ .CreatedDate }}
-		{{ if or .IsOwner $.IsAdmin $.IsMod $.IsSuperAdmin }} | <a href="/comments/edit?id={{ .ID }}">edit</a> {{end}}
+		comment by <a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a> in <a href="{{ .TopicURL }}">{{ .TopicName }}</a> {{ .CreatedDate }}
+		{{ if .EditedDate }} | {{ if .CanEdit }}<a href="/revisions?type=comment&id={{ .ID }}">edited {{ .EditedDate }}</a>{{ else }}edited {{ .EditedDate }}{{ end }}{{ end }}
+		{{ if .CanEdit }} | <a href="/comments/edit?id={{ .ID }}">edit</a> {{end}}
+		| <a href="/reports/new?type=comment&id={{ .ID }}">report</a>
+		{{ if .IsPending }} | <span class="alert">awaiting approval</span>{{ end }}
+		{{ if .IsShadowed }} | <span class="alert">shadow-banned</span>{{ end }}
 	</div>
 	{{ if .IsDeleted }}
 		<div>[DELETED]</div>
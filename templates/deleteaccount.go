@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const deleteaccountSrc = `
+{{ define "content" }}
+
+<h2>Delete account</h2>
+
+<form action="/users/delete" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th>User:</th>
+		<td><a href="/users?u={{ .UserName }}">{{ .UserName }}</a></td>
+	</tr>
+{{ if .DeleteDate }}
+	<tr>
+		<th></th>
+		<td>Your account will be deleted on {{ .DeleteDate }}.</td>
+	</tr>
+{{ else }}
+	<tr>
+		<th></th>
+		<td>
+			Your account will be deleted {{ if .GraceDays }}{{ .GraceDays }} days after you confirm. You can cancel any time before then.{{ else }}as soon as you confirm.{{ end }}
+			{{ if .IsAnonymize }}
+			Your topics and comments will stay on the forum but will no longer be linked to you.
+			{{ else }}
+			Your topics and comments will be deleted along with your account.
+			{{ end }}
+			Your private messages and subscriptions will be deleted.
+			You may want to <a href="/users/export">download your data</a> first.
+		</td>
+	</tr>
+	<tr>
+		<th><label for="passwd">Password:</label></th>
+		<td><input type="password" name="passwd" id="passwd" required></td>
+	</tr>
+{{ end }}
+{{ if .Common.Msg }}
+	<tr>
+		<th></th>
+		<td><span class="alert">{{ .Common.Msg }}</span></td>
+	</tr>
+{{ end }}
+	<tr>
+		<th></th>
+		<td>
+		{{ if .DeleteDate }}
+			<input type="submit" name="action" value="Cancel deletion">
+		{{ else }}
+			<input type="submit" name="action" value="Delete account">
+		{{ end }}
+		</td>
+	</tr>
+</table>
+</form>
+
+{{ end }}`
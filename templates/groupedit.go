@@ -11,9 +11,14 @@ const groupeditSrc = `
 <h1>New group</h1>
 {{ else }}
 <h1 id="title"><a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a></h1>
+{{ if .CanEdit }}
+<div class="row">
+	<div class="muted"><a href="/audit?gid={{ .ID }}">audit log</a> | <a href="/rules?gid={{ .ID }}">content rules</a></div>
+</div>
+{{ end }}
 {{ end }}
 
-
+{{ if .CanEdit }}
 <form action="/groups/edit" method="POST">
 <input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
 <input type="hidden" name="id" value="{{ .ID }}">
@@ -30,6 +35,7 @@ const groupeditSrc = `
 		<th><label for="header_msg">Announcement:</label></th>
 		<td><textarea name="header_msg" id="header_msg" rows="4">{{ .HeaderMsg }}</textarea></td>
 	</tr>
+{{ if .CanManageMods }}
 	<tr>
 		<th><label for="mods">Mods:</label></th>
 		<td><input type="text" name="mods" id="mods" placeholder="user1, user2" value="{{ .Mods }}"></td>
@@ -38,13 +44,45 @@ const groupeditSrc = `
 		<th><label for="admins">Admins (can edit this page):</label></th>
 		<td><input type="text" name="admins" id="admins" placeholder="user1, user2" value="{{ .Admins }}"></td>
 	</tr>
-	<!--
+{{ end }}
 	<tr>
-		<th><label for="is_private">Private:</label></th>
+		<th><label for="is_private">Private (members only):</label></th>
 		<td><input type="checkbox" name="is_private" id="is_private"{{ if .IsPrivate }} value="1" checked{{ end }}></td>
 	</tr>
-	-->
-{{ if .Common.IsSuperAdmin }}
+	<tr>
+		<th><label for="is_threaded">Threaded replies:</label></th>
+		<td><input type="checkbox" name="is_threaded" id="is_threaded"{{ if .IsThreaded }} value="1" checked{{ end }}></td>
+	</tr>
+	<tr>
+		<th><label for="post_policy">Who can post:</label></th>
+		<td><select name="post_policy" id="post_policy">
+			<option value="open"{{ if or (eq .Policy "open") (eq .Policy "") }} selected{{ end }}>Everyone</option>
+			<option value="announce"{{ if eq .Policy "announce" }} selected{{ end }}>Only mods start topics, everyone replies</option>
+			<option value="readonly"{{ if eq .Policy "readonly" }} selected{{ end }}>Nobody (read-only archive)</option>
+		</select></td>
+	</tr>
+	<tr>
+		<th><label for="premod_level">Hold posts for approval:</label></th>
+		<td><select name="premod_level" id="premod_level">
+			<option value="0"{{ if eq .Premod 0 }} selected{{ end }}>Off</option>
+			<option value="1"{{ if eq .Premod 1 }} selected{{ end }}>From new users</option>
+			<option value="2"{{ if eq .Premod 2 }} selected{{ end }}>From new and basic users</option>
+			<option value="3"{{ if eq .Premod 3 }} selected{{ end }}>From everyone except mods</option>
+		</select></td>
+	</tr>
+	<tr>
+		<th><label for="autoclose_days">Close topics after days without activity (0 = never):</label></th>
+		<td><input type="number" name="autoclose_days" id="autoclose_days" min="0" value="{{ .AutoClose.IdleDays }}"></td>
+	</tr>
+	<tr>
+		<th><label for="autoclose_comments">Close topics after comments (0 = never):</label></th>
+		<td><input type="number" name="autoclose_comments" id="autoclose_comments" min="0" value="{{ .AutoClose.MaxComments }}"></td>
+	</tr>
+	<tr>
+		<th><label for="stale_reply_days">Confirm replies to topics without activity for days (0 = never):</label></th>
+		<td><input type="number" name="stale_reply_days" id="stale_reply_days" min="0" value="{{ .AutoClose.StaleDays }}"></td>
+	</tr>
+{{ if .CanStickyGroup }}
 	<tr>
 		<th><label for="is_sticky">Sticky:</label></th>
 		<td><input type="checkbox" name="is_sticky" id="is_sticky"{{ if .IsSticky }} value="1" checked{{ end }}></td>
@@ -62,6 +100,11 @@ const groupeditSrc = `
 		{{ if .ID }}
 			{{ if not .IsDeleted }}
 			<input type="submit" name="action" value="Update">
+			{{ if .IsArchived }}
+			<input type="submit" name="action" value="Unarchive">
+			{{ else }}
+			<input type="submit" name="action" value="Archive">
+			{{ end }}
 			<input type="submit" name="action" value="Delete">
 			{{ else }}
 			<input type="submit" name="action" value="Undelete">
@@ -73,5 +116,148 @@ const groupeditSrc = `
 	</tr>
 </table>
 </form>
+{{ end }}
+
+{{ if .CanMerge }}
+<h2>Merge</h2>
+
+<form action="/groups/edit" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="id" value="{{ .ID }}">
+<table class="form">
+	<tr>
+		<th><label for="into">Merge into group:</label></th>
+		<td><input type="text" name="into" id="into"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><span class="muted">Moves all topics, subscribers, mods and admins to the other group and deletes this one.</span></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Merge"></td>
+	</tr>
+</table>
+</form>
+{{ end }}
+
+{{ if and .CanManageMembers .IsPrivate }}
+<h2>Members</h2>
+
+<form action="/groups/members" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="gid" value="{{ .ID }}">
+<table class="form">
+	<tr>
+		<th><label for="member_username">Username:</label></th>
+		<td><input type="text" name="username" id="member_username"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Invite"></td>
+	</tr>
+</table>
+</form>
+
+{{ range .JoinRequests }}
+<div class="row">
+	<a href="/users?u={{ . }}">{{ . }}</a> <span class="muted">asked to join</span>
+	<form action="/groups/members" method="POST" style="display: inline;">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="gid" value="{{ $.ID }}">
+		<input type="hidden" name="username" value="{{ . }}">
+		| <button type="submit" name="action" value="Approve" class="link-button">approve</button>
+		| <button type="submit" name="action" value="Remove" class="link-button">reject</button>
+	</form>
+</div>
+{{ end }}
+{{ range .Invitations }}
+<div class="row">
+	<a href="/users?u={{ . }}">{{ . }}</a> <span class="muted">invited</span>
+	<form action="/groups/members" method="POST" style="display: inline;">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="gid" value="{{ $.ID }}">
+		<input type="hidden" name="username" value="{{ . }}">
+		| <button type="submit" name="action" value="Remove" class="link-button">revoke</button>
+	</form>
+</div>
+{{ end }}
+{{ range .Members }}
+<div class="row">
+	<a href="/users?u={{ . }}">{{ . }}</a>
+	<form action="/groups/members" method="POST" style="display: inline;">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="gid" value="{{ $.ID }}">
+		<input type="hidden" name="username" value="{{ . }}">
+		| <button type="submit" name="action" value="Remove" class="link-button">remove</button>
+	</form>
+</div>
+{{ else }}
+<div class="row">
+	<div class="muted">No members yet.</div>
+</div>
+{{ end }}
+{{ end }}
+
+{{ if .CanBan }}
+<h2>Bans and mutes</h2>
+
+<form action="/groups/bans" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="gid" value="{{ .ID }}">
+<table class="form">
+	<tr>
+		<th><label for="ban_username">Username:</label></th>
+		<td><input type="text" name="username" id="ban_username"></td>
+	</tr>
+	<tr>
+		<th><label for="ban_reason">Reason:</label></th>
+		<td><input type="text" name="reason" id="ban_reason" placeholder="Off-topic posts"></td>
+	</tr>
+	<tr>
+		<th><label for="ban_days">Duration (days, 0 = forever):</label></th>
+		<td><input type="number" name="days" id="ban_days" min="0" value="1"></td>
+	</tr>
+{{ if and .Common.Msg (not .CanEdit) }}
+	<tr>
+		<th></th>
+		<td><span class="alert">{{ .Common.Msg }}</span></td>
+	</tr>
+{{ end }}
+	<tr>
+		<th></th>
+		<td>
+			<input type="submit" name="action" value="Mute">
+			<input type="submit" name="action" value="Ban">
+		</td>
+	</tr>
+</table>
+</form>
+
+{{ if .Bans }}
+{{ range .Bans }}
+<div class="row">
+	<div>
+		<a href="/users?u={{ .UserName }}">{{ .UserName }}</a>
+		{{ if .IsMute }}muted{{ else }}banned{{ end }}
+		{{ if .ExpiryDate }}until {{ .ExpiryDate }}{{ else }}forever{{ end }}
+	</div>
+	<div class="muted">
+		{{ if .Reason }}{{ .Reason }} | {{ end }}{{ if .AdminName }}by <a href="/users?u={{ .AdminName }}">{{ .AdminName }}</a> {{ end }}on {{ .CreatedDate }}
+		<form action="/groups/bans" method="POST" style="display: inline;">
+			<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+			<input type="hidden" name="gid" value="{{ $.ID }}">
+			<input type="hidden" name="id" value="{{ .ID }}">
+			| <button type="submit" name="action" value="Lift" class="link-button">lift</button>
+		</form>
+	</div>
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No active bans or mutes.</div>
+</div>
+{{ end }}
+{{ end }}
 
 {{ end }}`
@@ -8,10 +8,21 @@ const groupindexSrc = `
 {{ define "content" }}
 
 <div class="btn-row">
+	{{ if .CanPostTopic }}
 	<a class="link-btn" href="/topics/new?gid={{ .GroupID }}">New topic</a>
-	{{ if or .IsAdmin .IsMod .IsSuperAdmin }}
+	{{ end }}
+	{{ if .CanManage }}
 	<a class="link-btn" href="/groups/edit?id={{ .GroupID }}">Edit group</a>
 	{{ end }}
+	{{ if .CanHandleReports }}
+	<a class="link-btn" href="/reports?gid={{ .GroupID }}">Reports{{ if .NumReports }} ({{ .NumReports }}){{ end }}</a>
+	{{ end }}
+	{{ if .CanApprove }}
+	<a class="link-btn" href="/queue?gid={{ .GroupID }}">Queue{{ if .NumPending }} ({{ .NumPending }}){{ end }}</a>
+	{{ end }}
+	{{ if .CanSeeTrash }}
+	<a class="link-btn" href="/trash?gid={{ .GroupID }}">Trash</a>
+	{{ end }}
 	{{ if and .Common.UserName .Common.IsGroupSubAllowed }}
 	{{ if .SubToken }}
 	<form action="/groups/unsubscribe?token={{ .SubToken }}" method="POST">
@@ -25,26 +36,63 @@ const groupindexSrc = `
 	</form>
 	{{ end }}
 	{{ end }}
+	{{ if .CanSlowMode }}
+	<form action="/groups/slowmode" method="POST">
+		<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+		<input type="hidden" name="gid" value="{{ .GroupID }}">
+		<select name="slow_mode_secs">
+			<option value="0"{{ if eq .SlowMode 0 }} selected{{ end }}>Slow mode off</option>
+			<option value="30"{{ if eq .SlowMode 30 }} selected{{ end }}>30 seconds</option>
+			<option value="60"{{ if eq .SlowMode 60 }} selected{{ end }}>1 minute</option>
+			<option value="300"{{ if eq .SlowMode 300 }} selected{{ end }}>5 minutes</option>
+			<option value="900"{{ if eq .SlowMode 900 }} selected{{ end }}>15 minutes</option>
+			<option value="3600"{{ if eq .SlowMode 3600 }} selected{{ end }}>1 hour</option>
+		</select>
+		<input class="btn" type="submit" value="Set slow mode">
+	</form>
+	{{ end }}
+	{{ if .IsMember }}
+	<form action="/groups/members" method="POST">
+		<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+		<input type="hidden" name="gid" value="{{ .GroupID }}">
+		<input class="btn" type="submit" name="action" value="Leave group">
+	</form>
+	{{ end }}
 </div>
 
 <h1 id="title"><a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a></h1>
-<div class="muted">{{ .GroupDesc }}</div>
+<div class="muted">{{ .GroupDesc }}{{ if .IsArchived }} [archived]{{ else if eq .Policy "announce" }} [announcements]{{ else if eq .Policy "readonly" }} [read-only]{{ end }}{{ if .SlowMode }} [slow mode: one comment every {{ .SlowModeStr }}]{{ end }}</div>
 {{ if .HeaderMsg }}
 <h3>{{ .HeaderMsg }}</h3>
 {{ end }}
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
 
 {{ if .Topics }}
+{{ if .CanBulk }}
+<form action="/bulk" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="type" value="topic">
+<input type="hidden" name="next" value="/groups?name={{ .GroupName }}">
+{{ end }}
 <div style="margin-top: 30px;">
 {{ range .Topics }}
 	{{ if not .IsDeleted }}
 	<div class="topic-row">
-		<div><a href="/topics?id={{ .ID }}">{{ .Title }}{{ if .IsClosed }} [closed] {{ end }}</a></div>
+		<div>{{ if $.CanBulk }}<input type="checkbox" name="ids" value="{{ .ID }}"> {{ end }}<a href="/topics?id={{ .ID }}">{{ .Title }}{{ if .IsClosed }} [closed] {{ end }}{{ if .IsPending }} [awaiting approval]{{ end }}{{ if and .IsShadowed $.CanApprove }} [shadow-banned]{{ end }}</a></div>
 		<div class="muted"><a href="/users?u={{ .Owner }}">{{ .Owner }}</a> {{ .CreatedDate }} | <a href="/topics?id={{ .ID }}">{{ .NumComments }} comments</a></div>
 	</div>
 	<hr class="sep">
 	{{ end }}
 {{ end }}
 </div>
+{{ if .CanBulk }}
+{{ template "bulkcontrols" "topic" }}
+</form>
+{{ end }}
 {{ else }}
 <div class="row">
 	<div class="muted">No topics here.</div>
@@ -0,0 +1,34 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const groupjoinSrc = `
+{{ define "content" }}
+
+<h1 id="title"><a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a></h1>
+<div class="muted">This group is private. Only members can see its topics.</div>
+
+<div class="row">
+{{ if .Common.UserName }}
+<form action="/groups/members" method="POST">
+	<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+	<input type="hidden" name="gid" value="{{ .GroupID }}">
+	{{ if .IsInvited }}
+	<p>You have been invited to join this group.</p>
+	<input type="submit" name="action" value="Accept invitation">
+	<input type="submit" name="action" value="Decline">
+	{{ else if .IsRequested }}
+	<p>Your request to join is waiting for an admin of this group.</p>
+	<input type="submit" name="action" value="Cancel request">
+	{{ else }}
+	<input type="submit" name="action" value="Request to join">
+	{{ end }}
+</form>
+{{ else }}
+<p><a href="/login">Login</a> to ask to join.</p>
+{{ end }}
+</div>
+
+{{ end }}`
@@ -26,6 +26,18 @@ const profileSrc = `
 		<th><label for="email">Email (private):</label></th>
 		<td><input type="email" name="email" id="email" value={{ .Email }}></td>
 	</tr>
+	<tr>
+		<th><label for="mention_emails">Email me when I am @mentioned:</label></th>
+		<td><input type="checkbox" name="mention_emails" id="mention_emails" value="1"{{ if .MentionEmails }} checked{{ end }}></td>
+	</tr>
+	<tr>
+		<th><label for="thread_view">Show replies:</label></th>
+		<td><select name="thread_view" id="thread_view">
+			<option value="-1"{{ if eq .ThreadView -1 }} selected{{ end }}>As the group is set up</option>
+			<option value="0"{{ if eq .ThreadView 0 }} selected{{ end }}>Flat, oldest first</option>
+			<option value="1"{{ if eq .ThreadView 1 }} selected{{ end }}>Threaded</option>
+		</select></td>
+	</tr>
 	{{ if .Common.Msg }}
 	<tr>
 		<th></th>
@@ -54,6 +66,12 @@ const profileSrc = `
 		<th><a href="/changepass?u={{ .UserName }}">change password</a></th>
 		<td></td>
 	</tr>
+{{ if .IsRenameAllowed }}
+	<tr>
+		<th><a href="/users/rename?u={{ .UserName }}">change username</a></th>
+		<td></td>
+	</tr>
+{{ end }}
 {{ end }}
 {{ if and .IsSelf .Common.IsSuperAdmin }}
 	<tr>
@@ -65,18 +83,74 @@ const profileSrc = `
 		<td></td>
 	</tr>
 {{ end }}
+{{ if and .Common.UserName (not .IsSelf) }}
+	<tr>
+		<th><a href="/reports/new?type=user&id={{ .UserID }}">report user</a></th>
+		<td></td>
+	</tr>
+	<tr>
+		<th>{{ if .IsBlocked }}<input type="submit" name="action" value="Unblock">{{ else }}<input type="submit" name="action" value="Block">{{ end }}</th>
+		<td class="muted">Blocked users cannot @mention you.</td>
+	</tr>
+{{ end }}
 {{ if .IsSelf }}
 	<tr>
 		<th><a href="/pm">private messages{{ if .Common.IsNotification }}<span class="alert">&#x2757</span>{{ end }}</a></th>
 		<td></td>
 	</tr>
+	<tr>
+		<th><a href="/users/mentions">mentions{{ if .Common.IsMentioned }}<span class="alert">&#x2757</span>{{ end }}</a></th>
+		<td></td>
+	</tr>
+	<tr>
+		<th><a href="/users/export">download my data</a></th>
+		<td></td>
+	</tr>
+	<tr>
+		<th><a href="/users/delete">delete account</a></th>
+		<td>{{ if .IsDeleteScheduled }}<span class="alert">scheduled for deletion</span>{{ end }}</td>
+	</tr>
 	<tr>
 		<th><a href="/logout">logout</a></th>
 		<td></td>
 	</tr>
 {{ end }}
+{{ if .Common.IsSuperAdmin }}
+	<tr>
+		<th><label for="trust_level">Trust level:</label></th>
+		<td>
+			<select name="trust_level" id="trust_level">
+				<option value="-1"{{ if eq .TrustOverride -1 }} selected{{ end }}>Automatic ({{ .TrustLevel }})</option>
+				<option value="0"{{ if eq .TrustOverride 0 }} selected{{ end }}>New</option>
+				<option value="1"{{ if eq .TrustOverride 1 }} selected{{ end }}>Basic</option>
+				<option value="2"{{ if eq .TrustOverride 2 }} selected{{ end }}>Member</option>
+			</select>
+			<input type="submit" name="action" value="Set trust level">
+		</td>
+	</tr>
+{{ else if .IsSelf }}
+	<tr>
+		<th>Trust level:</th>
+		<td>{{ .TrustLevel }}</td>
+	</tr>
+{{ end }}
 {{ if .Common.IsSuperAdmin }}
 {{ if not .IsSelf }}
+	{{ if .IsBanned }}
+	<tr>
+		<th>Banned:</th>
+		<td>{{ .BanMsg }}</td>
+	</tr>
+	{{ else }}
+	<tr>
+		<th><label for="ban_reason">Ban reason:</label></th>
+		<td><input type="text" name="ban_reason" id="ban_reason" placeholder="Spam"></td>
+	</tr>
+	<tr>
+		<th><label for="ban_days">Ban for (days, 0 = forever):</label></th>
+		<td><input type="number" name="ban_days" id="ban_days" min="0" value="0"></td>
+	</tr>
+	{{ end }}
 	<tr>
 		<th></th>
 		<td>
@@ -85,11 +159,33 @@ const profileSrc = `
 			{{ else }}
 			<input type="submit" name="action" value="Unban">
 			{{ end }}
+			<input type="submit" formaction="/users/impersonate" value="View as {{ .UserName }}">
 		</td>
 	</tr>
 {{ end }}
 {{ end }}
+{{ if .CanShadowBan }}
+	<tr>
+		<th>Shadow-banned:</th>
+		<td>
+			{{ if .IsShadowBanned }}
+			yes <input type="submit" name="action" value="Lift shadow-ban">
+			{{ else }}
+			no <input type="submit" name="action" value="Shadow-ban">
+			{{ end }}
+		</td>
+	</tr>
+{{ end }}
 </table>
 </form>
+{{ if and .Common.IsSuperAdmin (not .IsSelf) }}
+<form action="/bulk" method="POST">
+	<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+	<input type="hidden" name="type" value="user">
+	<input type="hidden" name="ids" value="{{ .UserID }}">
+	<input type="hidden" name="next" value="/users?u={{ .UserName }}">
+	<input type="submit" name="action" value="Nuke user">
+</form>
+{{ end }}
 
 {{ end }}`
@@ -8,11 +8,22 @@ const profilecommentsSrc = `
 {{ define "content" }}
 
 <h2>Comments by {{ .OwnerName }}</h2>
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
 
 {{ if .Comments }}
+{{ if .CanBulk }}
+<form action="/bulk" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="type" value="comment">
+<input type="hidden" name="next" value="/users/comments?u={{ .OwnerName }}">
+{{ end }}
 {{ range .Comments }}
 <div class="row">
-	<div class="muted">{{ $.OwnerName }}</a> <a href="/comments?id={{ .ID }}">{{ .CreatedDate }}</a> on <a href="/topics?id={{ .TopicID }}">{{ .TopicName }}</a></div>
+	<div class="muted">{{ if .CanModerate }}<input type="checkbox" name="ids" value="{{ .ID }}"> {{ end }}{{ $.OwnerName }}</a> <a href="/comments?id={{ .ID }}">{{ .CreatedDate }}</a> on <a href="/topics?id={{ .TopicID }}">{{ .TopicName }}</a></div>
 	{{ if .IsDeleted }}
 		<div>[DELETED]</div>
 	{{ else }}
@@ -22,6 +33,10 @@ const profilecommentsSrc = `
 	<hr class="sep">
 </div>
 {{ end }}
+{{ if .CanBulk }}
+{{ template "bulkcontrols" "comment" }}
+</form>
+{{ end }}
 {{ else }}
 <div class="row">
 	<div class="muted">No comments to show.</div>
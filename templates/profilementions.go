@@ -0,0 +1,37 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const profilementionsSrc = `
+{{ define "content" }}
+
+<h2>Mentions</h2>
+
+{{ if .Mentions }}
+{{ range .Mentions }}
+<div class="row">
+	<div>
+		{{ if not .IsRead }}<span class="alert">&#x2757;</span>{{ end }}
+		<a href="/users?u={{ .AuthorName }}">{{ .AuthorName }}</a> mentioned you in <a href="{{ .URL }}">{{ .Where }}</a>
+	</div>
+	<div class="muted">{{ .CreatedDate }}</div>
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No mentions to show.</div>
+</div>
+{{ end }}
+
+{{ if .LastCreatedDate }}
+<div class="row">
+	<div>
+		<a href="/users/mentions?lcd={{ .LastCreatedDate }}&lid={{ .LastID }}">More</a>
+	</div>
+</div>
+{{ end }}
+
+{{ end }}
+`
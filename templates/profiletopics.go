@@ -8,16 +8,32 @@ const profiletopicsSrc = `
 {{ define "content" }}
 
 <h2>Topics by {{ .OwnerName }}</h2>
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
 
 {{ if .Topics }}
+{{ if .CanBulk }}
+<form action="/bulk" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="type" value="topic">
+<input type="hidden" name="next" value="/users/topics?u={{ .OwnerName }}">
+{{ end }}
 {{ range .Topics }}
 <div class="row">
 	<div>
+		{{ if .CanModerate }}<input type="checkbox" name="ids" value="{{ .ID }}">{{ end }}
 		<a href="{{ if not .IsDeleted }}/topics?id={{ .ID }}{{ else }}/topics/edit?id={{ .ID }}{{ end }}">{{ .Title }}{{ if .IsClosed }} [closed]{{ end }}{{ if .IsDeleted }} [deleted]{{ end }}</a>
 	</div>
 	<div class="muted">{{ .CreatedDate }}</div>
 </div>
 {{ end }}
+{{ if .CanBulk }}
+{{ template "bulkcontrols" "topic" }}
+</form>
+{{ end }}
 {{ else }}
 <div class="row">
 	<div class="muted">No topics to show.</div>
@@ -0,0 +1,40 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const queueSrc = `
+{{ define "content" }}
+
+<h1>{{ if .GroupName }}Awaiting approval in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}Awaiting approval{{ end }}</h1>
+
+{{ if .Posts }}
+{{ range .Posts }}
+<div class="comment-row">
+	<div class="comment-title muted">
+		{{ if eq .Type "topic" }}topic{{ else }}comment in{{ end }} <a href="/topics?id={{ .TopicID }}">{{ .TopicTitle }}</a>
+		by <a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a>
+		{{ if not $.GroupID }}in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ end }}
+		{{ .CreatedDate }}
+		{{ if .SpamScore }} | <span class="alert">spam score {{ .SpamScore }}%</span>{{ end }}
+	</div>
+	<div class="comment">{{ .Content }}</div>
+	<form action="/queue{{ if $.GroupID }}?gid={{ $.GroupID }}{{ end }}" method="POST">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="type" value="{{ .Type }}">
+		<input type="hidden" name="id" value="{{ .ID }}">
+		<input type="submit" name="action" value="Approve">
+		<input type="submit" name="action" value="Reject">
+		<input type="submit" name="action" value="Spam">
+	</form>
+</div>
+<hr class="sep">
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">Nothing awaiting approval.</div>
+</div>
+{{ end }}
+
+{{ end }}`
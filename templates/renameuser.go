@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const renameuserSrc = `
+{{ define "content" }}
+
+<form action="/users/rename" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="u" value="{{ .UserName }}">
+<table class="form">
+	<tr>
+		<th>Current username:</th>
+		<td><a href="/users?u={{ .UserName }}">{{ .UserName }}</a></td>
+	</tr>
+{{ if .NextChangeDate }}
+	<tr>
+		<th></th>
+		<td>Username can be changed again on {{ .NextChangeDate }}.</td>
+	</tr>
+{{ else }}
+	<tr>
+		<th><label for="newname">New username:</label></th>
+		<td><input type="text" name="newname" id="newname" required></td>
+	</tr>
+{{ end }}
+{{ if .Common.Msg }}
+	<tr>
+		<th></th>
+		<td><span class="alert">{{ .Common.Msg }}</span></td>
+	</tr>
+{{ end }}
+{{ if not .NextChangeDate }}
+	<tr>
+		<th></th>
+		<td><input type="submit" value="Change username"></td>
+	</tr>
+{{ end }}
+{{ if .OldNames }}
+	<tr>
+		<th>Previous usernames:</th>
+		<td>
+		{{ range .OldNames }}
+			<div>{{ .Name }} <span class="muted">(changed {{ .CreatedDate }})</span></div>
+		{{ end }}
+		</td>
+	</tr>
+{{ end }}
+</table>
+</form>
+
+{{ end }}`
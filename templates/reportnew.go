@@ -0,0 +1,45 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const reportnewSrc = `
+{{ define "content" }}
+
+<h1>Report {{ .TargetType }}</h1>
+<div class="row">
+	<div class="muted">{{ if .TargetURL }}<a href="{{ .TargetURL }}">{{ .TargetLabel }}</a>{{ else }}{{ .TargetLabel }}{{ end }}</div>
+</div>
+
+<form action="/reports/new" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="type" value="{{ .TargetType }}">
+<input type="hidden" name="id" value="{{ .TargetID }}">
+<table class="form">
+	<tr>
+		<th><label for="category">Reason:</label></th>
+		<td><select name="category" id="category">
+			{{ range .Categories }}
+			<option value="{{ . }}">{{ . }}</option>
+			{{ end }}
+		</select></td>
+	</tr>
+	<tr>
+		<th><label for="reason">Details:</label></th>
+		<td><textarea name="reason" id="reason" rows="6"></textarea></td>
+	</tr>
+{{ if .Common.Msg }}
+	<tr>
+		<th></th>
+		<td><span class="alert">{{ .Common.Msg }}</span></td>
+	</tr>
+{{ end }}
+	<tr>
+		<th></th>
+		<td><input type="submit" value="Report"></td>
+	</tr>
+</table>
+</form>
+
+{{ end }}`
@@ -0,0 +1,58 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const reportsSrc = `
+{{ define "content" }}
+
+<h1>{{ if .GroupName }}Reports in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}Reports{{ end }}</h1>
+<div class="row">
+	<div class="muted">
+		{{ if .ShowAll }}
+		<a href="/reports{{ if .GroupID }}?gid={{ .GroupID }}{{ end }}">show open reports only</a>
+		{{ else }}
+		<a href="/reports?all=1{{ if .GroupID }}&gid={{ .GroupID }}{{ end }}">show all reports</a>
+		{{ end }}
+	</div>
+</div>
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
+
+{{ if .Reports }}
+{{ range .Reports }}
+<div class="row">
+	<div>
+		[{{ .Category }}] {{ .TargetType }}:
+		{{ if .TargetURL }}<a href="{{ .TargetURL }}">{{ .TargetLabel }}</a>{{ else }}{{ .TargetLabel }}{{ end }}
+		{{ if and .GroupName (not $.GroupID) }}<span class="muted">in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a></span>{{ end }}
+	</div>
+	{{ if .Reason }}<div>{{ .Reason }}</div>{{ end }}
+	<div class="muted">
+		{{ if .ReporterName }}by <a href="/users?u={{ .ReporterName }}">{{ .ReporterName }}</a> {{ end }}{{ .CreatedDate }}
+		| {{ .Status }}{{ if .ClaimerName }} by <a href="/users?u={{ .ClaimerName }}">{{ .ClaimerName }}</a>{{ end }}
+		{{ if .Resolution }}| {{ .Resolution }}{{ end }}
+	</div>
+	{{ if .IsActive }}
+	<form action="/reports{{ if $.GroupID }}?gid={{ $.GroupID }}{{ end }}" method="POST">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="id" value="{{ .ID }}">
+		<input type="text" name="resolution" placeholder="Resolution note">
+		<input type="submit" name="action" value="Claim">
+		<input type="submit" name="action" value="Resolve">
+		<input type="submit" name="action" value="Dismiss">
+	</form>
+	{{ end }}
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No reports.</div>
+</div>
+{{ end }}
+
+{{ end }}`
@@ -0,0 +1,53 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const revisionsSrc = `
+{{ define "content" }}
+
+<h1>Edit history of <a href="{{ .PostURL }}">{{ .TargetType }} {{ .TargetID }}</a></h1>
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
+
+{{ if .Revisions }}
+{{ range .Revisions }}
+<div class="comment-row">
+	<div class="comment-title muted">
+		{{ if .UserName }}<a href="/users?u={{ .UserName }}">{{ .UserName }}</a>{{ else }}[deleted user]{{ end }}
+		{{ .CreatedDate }}
+		{{ if .IsCurrent }} | current version{{ end }}
+		{{ if .IsRedacted }} | <span class="alert">redacted</span>{{ end }}
+		{{ if and $.CanEditOthers (not .IsCurrent) }}
+		<form action="/revisions?type={{ $.TargetType }}&id={{ $.TargetID }}" method="POST" style="display: inline;">
+			<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+			<input type="hidden" name="rev" value="{{ .ID }}">
+			{{ if not .IsRedacted }}
+			| <input class="link-button" type="submit" name="action" value="Revert">
+			| <input class="link-button" type="submit" name="action" value="Redact">
+			{{ end }}
+		</form>
+		{{ end }}
+	</div>
+	<table class="diff">
+	{{ range .Rows }}
+		<tr>
+			<td{{ if or (eq .Kind "del") (eq .Kind "change") }} class="del"{{ end }}>{{ .Left }}</td>
+			<td{{ if or (eq .Kind "add") (eq .Kind "change") }} class="add"{{ end }}>{{ .Right }}</td>
+		</tr>
+	{{ end }}
+	</table>
+</div>
+<hr class="sep">
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">This post has not been edited.</div>
+</div>
+{{ end }}
+
+{{ end }}`
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const rulesSrc = `
+{{ define "content" }}
+
+<h1>{{ if .GroupName }}Content rules of <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}Site-wide content rules{{ end }}</h1>
+{{ if .Common.Msg }}
+<div class="row">
+	<span class="alert">{{ .Common.Msg }}</span>
+</div>
+{{ end }}
+
+{{ if .Rules }}
+{{ range .Rules }}
+<div class="row">
+	<form action="/rules{{ if $.GroupID }}?gid={{ $.GroupID }}{{ end }}" method="POST">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="id" value="{{ .ID }}">
+		<code>{{ .Pattern }}</code> ({{ .PatternType }})
+		{{ .Action }}{{ if eq .Action "replace" }} with <code>{{ .Replacement }}</code>{{ end }}
+		{{ if .Exceptions }}<span class="muted">except {{ .Exceptions }}</span>{{ end }}
+		<input type="submit" name="action" value="Delete">
+	</form>
+</div>
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">No rules.</div>
+</div>
+{{ end }}
+
+<h2>New rule</h2>
+<form action="/rules{{ if .GroupID }}?gid={{ .GroupID }}{{ end }}" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th><label for="pattern_type">Type:</label></th>
+		<td>
+			<select name="pattern_type" id="pattern_type">
+				<option value="word">word</option>
+				<option value="wildcard">wildcard (* and ?)</option>
+				<option value="regex">regular expression</option>
+			</select>
+		</td>
+	</tr>
+	<tr>
+		<th><label for="pattern">Pattern:</label></th>
+		<td><input type="text" name="pattern" id="pattern"></td>
+	</tr>
+	<tr>
+		<th><label for="rule_action">Action:</label></th>
+		<td>
+			<select name="rule_action" id="rule_action">
+				<option value="mask">mask</option>
+				<option value="replace">replace with text</option>
+				<option value="reject">reject post</option>
+				<option value="hold">hold for review</option>
+			</select>
+		</td>
+	</tr>
+	<tr>
+		<th><label for="replacement">Replacement:</label></th>
+		<td><input type="text" name="replacement" id="replacement"></td>
+	</tr>
+	<tr>
+		<th><label for="exceptions">Exceptions:</label></th>
+		<td><input type="text" name="exceptions" id="exceptions" placeholder="Scunthorpe, cocktail"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Add"></td>
+	</tr>
+</table>
+</form>
+
+<h2>Test a post</h2>
+<form action="/rules{{ if .GroupID }}?gid={{ .GroupID }}{{ end }}" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<table class="form">
+	<tr>
+		<th><label for="content"><div class="col-label">Post:</label></th>
+		<td><textarea name="content" id="content" rows="6">{{ .TestContent }}</textarea></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Test"></td>
+	</tr>
+</table>
+</form>
+{{ with .TestResult }}
+<div class="row">
+	{{ if .Rejection }}<div class="alert">Rejected: contains "{{ .Rejection }}".</div>
+	{{ else if .Hold }}<div class="alert">Held for review: contains "{{ .Hold }}".</div>
+	{{ else }}<div class="muted">Accepted.</div>{{ end }}
+</div>
+{{ range .Matches }}
+<div class="row">
+	<div class="muted">{{ .Action }}: "{{ .Text }}"</div>
+</div>
+{{ end }}
+<div class="comment">{{ .Content }}</div>
+{{ end }}
+
+{{ end }}`
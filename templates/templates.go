@@ -16,6 +16,27 @@ func init() {
 	tmpls["adminindex.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["adminindex.html"].New("adminindex").Parse(adminindexSrc))
 
+	tmpls["adminbans.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["adminbans.html"].New("adminbans").Parse(adminbansSrc))
+
+	tmpls["adminroles.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["adminroles.html"].New("adminroles").Parse(adminrolesSrc))
+
+	tmpls["audit.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["audit.html"].New("audit").Parse(auditSrc))
+
+	tmpls["bulk.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["bulk.html"].New("bulk").Parse(bulkSrc))
+
+	tmpls["rules.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["rules.html"].New("rules").Parse(rulesSrc))
+
+	tmpls["revisions.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["revisions.html"].New("revisions").Parse(revisionsSrc))
+
+	tmpls["trash.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["trash.html"].New("trash").Parse(trashSrc))
+
 	tmpls["changepass.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["changepass.html"].New("changepass").Parse(changepassSrc))
 
@@ -25,6 +46,9 @@ func init() {
 	tmpls["commentindex.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["commentindex.html"].New("commentindex").Parse(commentindexSrc))
 
+	tmpls["deleteaccount.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["deleteaccount.html"].New("deleteaccount").Parse(deleteaccountSrc))
+
 	tmpls["extranote.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["extranote.html"].New("extranote").Parse(extranoteSrc))
 
@@ -33,12 +57,17 @@ func init() {
 
 	tmpls["groupindex.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["groupindex.html"].New("groupindex").Parse(groupindexSrc))
+	template.Must(tmpls["groupindex.html"].New("bulkcontrols").Parse(bulkcontrolsSrc))
 
 	tmpls["groupedit.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["groupedit.html"].New("groupedit").Parse(groupeditSrc))
 
+	tmpls["groupjoin.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["groupjoin.html"].New("groupjoin").Parse(groupjoinSrc))
+
 	tmpls["groups.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["groups.html"].New("groups").Parse(groupindexSrc))
+	template.Must(tmpls["groups.html"].New("bulkcontrols").Parse(bulkcontrolsSrc))
 
 	tmpls["index.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["index.html"].New("index").Parse(indexSrc))
@@ -51,13 +80,30 @@ func init() {
 
 	tmpls["profilecomments.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["profilecomments.html"].New("profilecomments").Parse(profilecommentsSrc))
+	template.Must(tmpls["profilecomments.html"].New("bulkcontrols").Parse(bulkcontrolsSrc))
 
 	tmpls["profiletopics.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["profiletopics.html"].New("profiletopics").Parse(profiletopicsSrc))
+	template.Must(tmpls["profiletopics.html"].New("bulkcontrols").Parse(bulkcontrolsSrc))
+
+	tmpls["profilementions.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["profilementions.html"].New("profilementions").Parse(profilementionsSrc))
 
 	tmpls["profilegroups.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["profilegroups.html"].New("profilegroups").Parse(profilegroupsSrc))
 
+	tmpls["renameuser.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["renameuser.html"].New("renameuser").Parse(renameuserSrc))
+
+	tmpls["reportnew.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["reportnew.html"].New("reportnew").Parse(reportnewSrc))
+
+	tmpls["queue.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["queue.html"].New("queue").Parse(queueSrc))
+
+	tmpls["reports.html"] = template.Must(template.New("base").Parse(baseSrc))
+	template.Must(tmpls["reports.html"].New("reports").Parse(reportsSrc))
+
 	tmpls["resetpass.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["resetpass.html"].New("resetpass").Parse(resetpassSrc))
 
@@ -69,6 +115,7 @@ func init() {
 
 	tmpls["topicindex.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["topicindex.html"].New("topicindex").Parse(topicindexSrc))
+	template.Must(tmpls["topicindex.html"].New("topiccomment").Parse(topiccommentSrc))
 
 	tmpls["pm.html"] = template.Must(template.New("base").Parse(baseSrc))
 	template.Must(tmpls["pm.html"].New("pm").Parse(pmSrc))
@@ -21,13 +21,13 @@ const topiceditSrc = `
 <table class="form">
 	<tr>
 		<th>Title:</th>
-		<td><input type="text" name="title" placeholder="How does X work?" value="{{ .Title }}"></td>
+		<td><input type="text" name="title" placeholder="How does X work?" value="{{ .Title }}"{{ if and .TopicID (not .CanEdit) }} readonly{{ end }}></td>
 	</tr>
 	<tr>
 		<th>Content:</th>
-		<td><textarea name="content" rows="12">{{ .Content }}</textarea></td>
+		<td><textarea name="content" rows="12"{{ if and .TopicID (not .CanEdit) }} readonly{{ end }}>{{ .Content }}</textarea></td>
 	</tr>
-{{ if or .IsMod .IsAdmin .IsSuperAdmin }}
+{{ if .CanSticky }}
 	<tr>
 		<th>Sticky:</th>
 		<td><input type="checkbox" name="is_sticky"{{ if .IsSticky }} checked{{ end }}></td>
@@ -45,19 +45,27 @@ const topiceditSrc = `
 		{{ if .TopicID }}
 			{{ if not .IsClosed }}
 				{{ if not .IsDeleted }}
+					{{ if or .CanEdit .CanSticky }}
 					<input type="submit" name="action" value="Update">
-					{{ if or .IsMod .IsAdmin .IsSuperAdmin }}
+					{{ end }}
+					{{ if .CanClose }}
 					<input type="submit" name="action" value="Close">
 					{{ end }}
+					{{ if .CanDelete }}
 					<input type="submit" name="action" value="Delete">
-				{{ else }}
+					{{ end }}
+				{{ else if .CanDelete }}
 					<input type="submit" name="action" value="Undelete">
 				{{ end }}
 			{{ else }}
-				{{ if or .IsMod .IsAdmin .IsSuperAdmin }}
+				{{ if .CanClose }}
 				<input type="submit" name="action" value="Reopen">
 				{{ end }}
 			{{ end }}
+			{{ if .CanMarkSpam }}
+				<input type="submit" name="action" value="Spam">
+				<input type="submit" name="action" value="Not spam">
+			{{ end }}
 		{{ else }}
 			<input type="submit" name="action" class="no-double-post" value="Create">
 		{{ end }}
@@ -66,4 +74,56 @@ const topiceditSrc = `
 </table>
 </form>
 
+{{ if and .TopicID .CanMove }}
+<h2>Move, split or merge</h2>
+<form action="/topics/edit" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="id" value="{{ .TopicID }}">
+<table class="form">
+	<tr>
+		<th>Move to group:</th>
+		<td><input type="text" name="group" placeholder="{{ .GroupName }}"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Move"></td>
+	</tr>
+</table>
+</form>
+
+<form action="/topics/edit" method="POST" id="split">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="id" value="{{ .TopicID }}">
+<table class="form">
+	<tr>
+		<th>Split from comment:</th>
+		<td><input type="text" name="comment" placeholder="Comment ID" value="{{ .SplitComment }}"></td>
+	</tr>
+	<tr>
+		<th>New title:</th>
+		<td><input type="text" name="split_title"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Split"></td>
+	</tr>
+</table>
+</form>
+
+<form action="/topics/edit" method="POST">
+<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
+<input type="hidden" name="id" value="{{ .TopicID }}">
+<table class="form">
+	<tr>
+		<th>Merge into topic:</th>
+		<td><input type="text" name="into" placeholder="Topic ID"></td>
+	</tr>
+	<tr>
+		<th></th>
+		<td><input type="submit" name="action" value="Merge"></td>
+	</tr>
+</table>
+</form>
+{{ end }}
+
 {{ end }}`
@@ -8,10 +8,10 @@ const topicindexSrc = `
 {{ define "content" }}
 
 <div class="btn-row">
-	{{ if not .IsClosed }}
+	{{ if .CanReply }}
 	<a class="link-btn" href="/comments/new?tid={{ .TopicID }}">Reply</a>
 	{{ end }}
-	{{ if or .IsAdmin .IsMod .IsSuperAdmin (and .IsOwner (not .IsClosed)) }}
+	{{ if and (not .IsReadOnly) (or .CanEditOthers (and .IsOwner (not .IsClosed))) }}
 	<a class="link-btn" href="/topics/edit?id={{ .TopicID }}">Edit topic</a>
 	{{ end }}
 	{{ if and .Common.UserName .Common.IsTopicSubAllowed }}
@@ -29,33 +29,26 @@ const topicindexSrc = `
 	{{ end }}
 </div>
 
-<h2 id="title"><a href="/topics?id={{ .TopicID }}">{{ .TopicName }}{{ if .IsClosed }} [closed]{{ end }}</a></h2>
-<div class="comment-title muted"><a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a> in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a> {{ .CreatedDate }}</div>
+<h2 id="title"><a href="/topics?id={{ .TopicID }}">{{ .TopicName }}{{ if .IsClosed }} [closed]{{ end }}{{ if .IsPending }} [awaiting approval]{{ end }}{{ if and .IsShadowed .CanApprove }} [shadow-banned]{{ end }}</a></h2>
+<div class="comment-title muted">
+	<a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a> in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a> {{ .CreatedDate }}
+	{{ if .EditedDate }} | {{ if or .IsOwner .CanEditOthers }}<a href="/revisions?type=topic&id={{ .TopicID }}">edited {{ .EditedDate }}</a>{{ else }}edited {{ .EditedDate }}{{ end }}{{ end }}
+	| <a href="/reports/new?type=topic&id={{ .TopicID }}">report</a>
+	{{ if .NumReports }} | <a class="alert" href="/reports?gid={{ .GroupID }}">{{ .NumReports }} reports</a>{{ end }}
+	{{ if and .IsClosed .CloseReason }} | {{ .CloseReason }}{{ end }}
+</div>
 <div class="comment-row">
 	<div class="comment">
-		<p>{{ .Content }}</p>
+		{{ .Content }}
 	</div>
 </div>
 <hr class="sep">
 
 {{ if .Comments }}
-{{ range .Comments }}
-<div class="comment-row" id="comment-{{ .ID }}">
-	<div class="comment-title muted">
-		<a href="/users?u={{ .UserName }}">{{ .UserName }}</a>
-		<a href="/comments?id={{ .ID }}">{{ .CreatedDate }}</a>
-		{{ if or .IsOwner $.IsAdmin $.IsMod $.IsSuperAdmin }} | <a href="/comments/edit?id={{ .ID }}">edit</a>{{end}}
-		{{ if not .IsDeleted }} | <a href="/comments/new?tid={{ $.TopicID }}&quote={{ .ID }}">quote</a>{{ end }}
-		| <a href="/pm?flag={{ .ID }}#end">flag</a>
-	</div>
-	{{ if .IsDeleted }}
-		<div class="comment">[DELETED]</div>
-	{{ else }}
-		<div class="comment">{{ .Content }}</div>
-		{{ if .ImgSrc }}<div><img src="/img?name={{ .ImgSrc }}"></div>{{ end }}
-	{{ end }}
-</div>
-<hr class="sep">
+{{ if .IsThreaded }}
+{{ range .Threads }}{{ template "topiccomment" . }}<hr class="sep">{{ end }}
+{{ else }}
+{{ range .Comments }}{{ template "topiccomment" . }}{{ end }}
 {{ end }}
 {{ else }}
 <div class="row">
@@ -83,7 +76,7 @@ const topicindexSrc = `
 </div>
 {{ end }}
 
-{{ if .Common.UserName }}
+{{ if and .Common.UserName .CanReply }}
 <div style="margin-top: 40px;">
 <form action="/comments/new" method="POST" enctype="multipart/form-data">
 	<input type="hidden" name="csrf" value="{{ .Common.CSRF }}">
@@ -99,3 +92,33 @@ const topicindexSrc = `
 {{ end }}
 
 {{ end }}`
+
+const topiccommentSrc = `
+<div class="comment-row" id="comment-{{ .ID }}">
+	<div class="comment-title muted">
+		<a href="/users?u={{ .UserName }}">{{ .UserName }}</a>
+		<a href="/comments?id={{ .ID }}">{{ .CreatedDate }}</a>
+		{{ if .EditedDate }} | {{ if or .IsOwner .Topic.CanEditOthers }}<a href="/revisions?type=comment&id={{ .ID }}">edited {{ .EditedDate }}</a>{{ else }}edited {{ .EditedDate }}{{ end }}{{ end }}
+		{{ if and (not .Topic.IsReadOnly) (or .IsOwner .Topic.CanEditOthers) }} | <a href="/comments/edit?id={{ .ID }}">edit</a>{{end}}
+		{{ if and .Topic.CanReply .Topic.IsThreaded (not .IsDeleted) }} | <a href="/comments/new?tid={{ .Topic.TopicID }}&parent={{ .ID }}">reply</a>{{ end }}
+		{{ if and .Topic.CanReply (not .IsDeleted) }} | <a href="/comments/new?tid={{ .Topic.TopicID }}&quote={{ .ID }}">quote</a>{{ end }}
+		| <a href="/reports/new?type=comment&id={{ .ID }}">report</a>
+		{{ if .NumReports }} | <a class="alert" href="/reports?gid={{ .Topic.GroupID }}">{{ .NumReports }} reports</a>{{ end }}
+		{{ if .Topic.CanMove }} | <a href="/topics/edit?id={{ .Topic.TopicID }}&split={{ .ID }}#split">split here</a>{{ end }}
+		{{ if .IsPending }} | <span class="alert">awaiting approval</span>{{ if .Topic.CanApprove }} <a href="/queue?gid={{ .Topic.GroupID }}">queue</a>{{ end }}{{ end }}
+		{{ if and .IsShadowed .Topic.CanApprove }} | <span class="alert">shadow-banned</span>{{ end }}
+	</div>
+	{{ if .IsDeleted }}
+		<div class="comment">[DELETED]</div>
+	{{ else }}
+		<div class="comment">{{ .Content }}</div>
+		{{ if .ImgSrc }}<div><img src="/img?name={{ .ImgSrc }}"></div>{{ end }}
+	{{ end }}
+</div>
+{{ if .Replies }}
+<details class="replies" open>
+	<summary class="muted">{{ len .Replies }} {{ if eq (len .Replies) 1 }}reply{{ else }}replies{{ end }}</summary>
+	{{ range .Replies }}{{ template "topiccomment" . }}{{ end }}
+</details>
+{{ end }}
+{{ if not .Topic.IsThreaded }}<hr class="sep">{{ end }}`
@@ -0,0 +1,43 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package templates
+
+const trashSrc = `
+{{ define "content" }}
+
+<h1>{{ if .GroupName }}Trash of <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ else }}Trash{{ end }}</h1>
+<div class="row">
+	<div class="muted">{{ if .RetentionDays }}Deleted items are purged for good after {{ .RetentionDays }} days.{{ else }}Deleted items are kept until they are restored.{{ end }}</div>
+</div>
+
+{{ if .Items }}
+{{ range .Items }}
+<div class="comment-row">
+	<div class="comment-title muted">
+		{{ if eq .Type "group" }}group <a href="/groups/edit?id={{ .ID }}">{{ .GroupName }}</a>
+		{{ else }}
+		{{ if eq .Type "topic" }}topic{{ else }}comment in{{ end }} <a href="/topics?id={{ .TopicID }}">{{ .Title }}</a>
+		by <a href="/users?u={{ .OwnerName }}">{{ .OwnerName }}</a>
+		{{ if not $.GroupID }}in <a href="/groups?name={{ .GroupName }}">{{ .GroupName }}</a>{{ end }}
+		{{ end }}
+		| deleted {{ .DeletedDate }}
+	</div>
+	<div class="comment">{{ .Content }}</div>
+	<form action="/trash{{ if $.GroupID }}?gid={{ $.GroupID }}{{ end }}" method="POST">
+		<input type="hidden" name="csrf" value="{{ $.Common.CSRF }}">
+		<input type="hidden" name="type" value="{{ .Type }}">
+		<input type="hidden" name="id" value="{{ .ID }}">
+		<input type="submit" name="action" value="Restore">
+	</form>
+</div>
+<hr class="sep">
+{{ end }}
+{{ else }}
+<div class="row">
+	<div class="muted">The trash is empty.</div>
+</div>
+{{ end }}
+
+{{ end }}`
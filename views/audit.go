@@ -0,0 +1,100 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"encoding/csv"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var auditEntriesPerPage = 100
+
+// csvSafe stops spreadsheet programs from treating user-supplied text as a formula.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsAny(s[:1], "=+-@") {
+		return "'" + s
+	}
+	return s
+}
+
+// AuditLogHandler shows the moderation audit log to superadmins, and to group admins for their group.
+var AuditLogHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	if (groupID == "" && !sess.IsUserSuperAdmin()) || (groupID != "" && !can(sess, models.CapEditGroup, groupID)) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+
+	if r.FormValue("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"auditlog.csv\"")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"date", "actor", "action", "target_type", "target_id", "group", "before", "after"})
+		for _, e := range models.ReadAuditLog(groupID, 0, "", 0) {
+			cw.Write([]string{e.CreatedDate.UTC().Format(time.RFC3339), csvSafe(e.ActorName), e.Action, e.TargetType, e.TargetID,
+				csvSafe(e.GroupName), csvSafe(e.Before), csvSafe(e.After)})
+		}
+		cw.Flush()
+		return
+	}
+
+	lastDate, err := strconv.ParseInt(r.FormValue("lcd"), 10, 64)
+	if err != nil {
+		lastDate = 0
+	}
+	lastID := r.FormValue("lid")
+	if _, err := strconv.ParseInt(lastID, 10, 64); err != nil {
+		lastDate = 0
+	}
+
+	type AuditEntry struct {
+		ActorName   string
+		Action      string
+		TargetType  string
+		TargetID    string
+		GroupName   string
+		Before      string
+		After       string
+		CreatedDate string
+	}
+	var entries []AuditEntry
+	var nextDate int64
+	var nextID string
+	rows := models.ReadAuditLog(groupID, lastDate, lastID, auditEntriesPerPage+1)
+	for i, e := range rows {
+		if i == auditEntriesPerPage {
+			nextDate, nextID = rows[i-1].CreatedDate.Unix(), rows[i-1].ID
+			break
+		}
+		entries = append(entries, AuditEntry{
+			ActorName:   e.ActorName,
+			Action:      e.Action,
+			TargetType:  e.TargetType,
+			TargetID:    e.TargetID,
+			GroupName:   e.GroupName,
+			Before:      e.Before,
+			After:       e.After,
+			CreatedDate: e.CreatedDate.Format("2006-01-02 15:04"),
+		})
+	}
+
+	var groupName string
+	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
+
+	templates.Render(w, "audit.html", map[string]interface{}{
+		"Common":          readCommonData(r, sess),
+		"GroupID":         groupID,
+		"GroupName":       groupName,
+		"Entries":         entries,
+		"LastCreatedDate": nextDate,
+		"LastID":          nextID,
+	})
+})
@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	groupID := createGroupForTest("auditgroup")
+	_, modSess := createUserForTest(t, "auditmod")
+	_, adminSess := createUserForTest(t, "auditadmin")
+	posterID, posterSess := createUserForTest(t, "auditposter")
+	models.CreateGroupMod("auditmod", groupID)
+	models.CreateGroupAdmin("auditadmin", groupID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A topic to be deleted", "", posterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, modSess, url.Values{"id": {topicID}, "title": {"A topic to be deleted"}, "action": {"Delete"}})
+	entries := models.ReadAuditLog(groupID, 0, "", 0)
+	if len(entries) != 1 || entries[0].Action != models.AuditTopicDelete || entries[0].ActorName != "auditmod" || entries[0].TargetID != topicID {
+		t.Fatalf("Topic delete not logged: got %v\n", entries)
+	}
+
+	if rr := getForTest(AuditLogHandler, "/audit?gid="+groupID, posterSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-admin able to see the audit log: got %v\n", rr.Code)
+	}
+	if rr := getForTest(AuditLogHandler, "/audit", adminSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Group admin able to see the global audit log: got %v\n", rr.Code)
+	}
+	if body := getForTest(AuditLogHandler, "/audit?gid="+groupID, adminSess).Body.String(); !strings.Contains(body, models.AuditTopicDelete) {
+		t.Errorf("Audit log entry not shown to group admin.\n")
+	}
+	rr := getForTest(AuditLogHandler, "/audit?format=csv&gid="+groupID, adminSess)
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") || !strings.Contains(rr.Body.String(), "auditmod,"+models.AuditTopicDelete) {
+		t.Errorf("Audit log CSV export missing entry: got %v\n", rr.Body.String())
+	}
+}
+
+func TestAuditLogPaging(t *testing.T) {
+	groupID := createGroupForTest("auditpaging")
+	adminID, adminSess := createUserForTest(t, "auditpager")
+	models.CreateGroupAdmin("auditpager", groupID)
+	for _, target := range []string{"1", "2", "3"} {
+		models.LogAction(int64(adminID), models.AuditTopicDelete, "topic", target, groupID, "", "")
+	}
+	db.Exec(`UPDATE auditlog SET created_date=? WHERE groupid=?;`, time.Now().Unix(), groupID)
+
+	oldPerPage := auditEntriesPerPage
+	auditEntriesPerPage = 2
+	defer func() { auditEntriesPerPage = oldPerPage }()
+
+	body := getForTest(AuditLogHandler, "/audit?gid="+groupID, adminSess).Body.String()
+	if !strings.Contains(body, "topic 3") || !strings.Contains(body, "topic 2") || strings.Contains(body, "topic 1") {
+		t.Fatalf("Wrong first page of the audit log: %s\n", body)
+	}
+	entries := models.ReadAuditLog(groupID, 0, "", 0)
+	next := "/audit?lcd=" + strconv.FormatInt(entries[1].CreatedDate.Unix(), 10) + "&lid=" + entries[1].ID
+	if !strings.Contains(body, next) {
+		t.Fatalf("Link to the next page not keyed on the last entry shown.\n")
+	}
+	body = getForTest(AuditLogHandler, next+"&gid="+groupID, adminSess).Body.String()
+	if !strings.Contains(body, "topic 1") || strings.Contains(body, "topic 2") {
+		t.Errorf("Audit log entries sharing a date skipped or repeated across pages: %s\n", body)
+	}
+}
@@ -5,6 +5,7 @@
 package views
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/s-gv/orangeforum/models"
 	"github.com/s-gv/orangeforum/models/db"
@@ -36,6 +37,14 @@ var LoginHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session)
 			return
 		}
 		if err = sess.Authenticate(userName, passwd); err == nil {
+			// Superadmins can log in from a banned network so that they can lift the ban.
+			if ban, err := models.ReadActiveNetworkBan(clientAddrs(r)); err == nil && !sess.IsUserSuperAdmin() {
+				sess.UserID = sql.NullInt64{}
+				db.Exec(`UPDATE sessions SET userid=? WHERE sessionid=?;`, sess.UserID, sess.SessionID)
+				sess.SetFlashMsg(banMsg(ban))
+				http.Redirect(w, r, "/login?next="+redirectURL, http.StatusSeeOther)
+				return
+			}
 			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 			return
 		} else {
@@ -53,6 +62,11 @@ var LoginHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session)
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	defer ErrServerHandler(w, r)
+	if sess := OpenSession(w, r); sess.IsImpersonating() {
+		sess.EndImpersonation()
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
 	ClearSession(w, r)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -74,29 +88,18 @@ var SignupHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session
 		passwd := r.PostFormValue("passwd")
 		passwdConfirm := r.PostFormValue("confirm")
 		email := strings.TrimSpace(r.PostFormValue("email"))
-		if len(userName) < 2 || len(userName) > 32 {
-			sess.SetFlashMsg("Username should have 2-32 characters.")
-			http.Redirect(w, r, "/signup", http.StatusSeeOther)
-			return
-		}
-		if censored := censor(userName); censored != userName {
-			sess.SetFlashMsg("Fix username: " + censored)
+		if err := validateUserName(userName); err != nil {
+			sess.SetFlashMsg(err.Error())
 			http.Redirect(w, r, "/signup", http.StatusSeeOther)
 			return
 		}
-		hasSpecial := false
-		for _, ch := range userName {
-			if (ch < 'A' || ch > 'Z') && (ch < 'a' || ch > 'z') && ch != '_' && (ch < '0' || ch > '9') {
-				hasSpecial = true
-			}
-		}
-		if hasSpecial {
-			sess.SetFlashMsg("Username can contain only alphabets, numbers, and underscore.")
+		if models.ProbeUser(userName) {
+			sess.SetFlashMsg("Username already registered.")
 			http.Redirect(w, r, "/signup", http.StatusSeeOther)
 			return
 		}
-		if models.ProbeUser(userName) {
-			sess.SetFlashMsg("Username already registered.")
+		if models.IsUserNameOnCooldown(userName, 0) {
+			sess.SetFlashMsg("Username was recently released and is not available yet.")
 			http.Redirect(w, r, "/signup", http.StatusSeeOther)
 			return
 		}
@@ -114,6 +117,11 @@ var SignupHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session
 			ErrForbiddenHandler(w, r)
 			return
 		}
+		if ban, err := models.ReadActiveNetworkBan(clientAddrs(r)); err == nil && !sess.IsUserSuperAdmin() {
+			sess.SetFlashMsg(banMsg(ban))
+			http.Redirect(w, r, "/signup", http.StatusSeeOther)
+			return
+		}
 		models.CreateUser(userName, passwd, email)
 		if sess.IsUserSuperAdmin() {
 			sess.SetFlashMsg("User " + userName + " created")
@@ -0,0 +1,115 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanMatchesAddr(t *testing.T) {
+	cases := []struct {
+		network string
+		addr    string
+		match   bool
+	}{
+		{"203.0.113.0/24", "203.0.113.7", true},
+		{"203.0.113.0/24", "203.0.114.7", false},
+		{"203.0.113.7", "203.0.113.7", true},
+		{"2001:db8::/32", "2001:db8::1", true},
+		{"abcdefgh.b32.i2p", "ABCDEFGH", true},
+		{"abcdefgh", "abcdefgi.b32.i2p", false},
+		{"203.0.113.0/24", "", false},
+	}
+	for _, c := range cases {
+		if got := models.BanMatchesAddr(c.network, c.addr); got != c.match {
+			t.Errorf("BanMatchesAddr(%q, %q) = %v, want %v\n", c.network, c.addr, got, c.match)
+		}
+	}
+}
+
+func TestLoginBannedUser(t *testing.T) {
+	models.CreateUser("banneduser", "banneduser123", "")
+	userID, _ := models.ReadUserIDByName("banneduser")
+	adminID, _ := models.ReadUserIDByName("admin")
+
+	models.CreateBan(sql.NullInt64{Int64: int64(userID), Valid: true}, int64(adminID), "Spamming", "", time.Now().Add(time.Hour))
+	if _, err := loginForTest("banneduser", "banneduser123"); err == nil {
+		t.Fatalf("Banned user was able to login.\n")
+	}
+	ban, err := models.ReadActiveUserBan(int64(userID))
+	if err != nil || ban.Reason != "Spamming" {
+		t.Errorf("Active ban not found.\n")
+	}
+
+	models.LiftUserBans(int64(userID))
+	models.CreateBan(sql.NullInt64{Int64: int64(userID), Valid: true}, int64(adminID), "Expired", "", time.Now().Add(-time.Hour))
+	if _, err := loginForTest("banneduser", "banneduser123"); err != nil {
+		t.Errorf("User unable to login after ban expired: %s\n", err)
+	}
+}
+
+func TestSignupNetworkBan(t *testing.T) {
+	adminID, _ := models.ReadUserIDByName("admin")
+	models.CreateBan(sql.NullInt64{}, int64(adminID), "Ban evasion", "192.0.2.0/24", time.Time{})
+
+	req, _ := http.NewRequest("GET", "/signup", nil)
+	req.RemoteAddr = "192.0.2.10:4321"
+	if _, err := models.ReadActiveNetworkBan(clientAddrs(req)); err != nil {
+		t.Errorf("Network ban does not match the client address.\n")
+	}
+	req.RemoteAddr = "198.51.100.10:4321"
+	req.Header.Set("X-I2P-DestB32", "someclient.b32.i2p")
+	if _, err := models.ReadActiveNetworkBan(clientAddrs(req)); err == nil {
+		t.Errorf("Network ban matches an unrelated client address.\n")
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SignupHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Signup page returned wrong status code: got %v\n", rr.Code)
+	}
+
+	sessionid, err := grabSessionID(rr)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	var csrfToken string
+	db.QueryRow(`SELECT csrf FROM sessions WHERE sessionid=?;`, sessionid).Scan(&csrfToken)
+	postFromBannedNetwork := func(handler http.HandlerFunc, target string, form url.Values) {
+		form.Set("csrf", csrfToken)
+		req, _ := http.NewRequest("POST", target, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "sessionid", Path: "/", Value: sessionid, HttpOnly: true})
+		req.RemoteAddr = "192.0.2.10:4321"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	loggedInUserID := func() int64 {
+		var userID sql.NullInt64
+		db.QueryRow(`SELECT userid FROM sessions WHERE sessionid=?;`, sessionid).Scan(&userID)
+		return userID.Int64
+	}
+
+	postFromBannedNetwork(SignupHandler, "/signup", url.Values{"username": {"netbanned"}, "passwd": {"netbanned123"}, "confirm": {"netbanned123"}})
+	if models.ProbeUser("netbanned") {
+		t.Errorf("User signed up from a banned network.\n")
+	}
+
+	models.CreateUser("netbanned2", "netbanned123", "")
+	postFromBannedNetwork(LoginHandler, "/login", url.Values{"username": {"netbanned2"}, "passwd": {"netbanned123"}})
+	if loggedInUserID() != 0 {
+		t.Errorf("User logged in from a banned network.\n")
+	}
+	postFromBannedNetwork(LoginHandler, "/login", url.Values{"username": {"admin"}, "passwd": {"admin12345"}})
+	if loggedInUserID() != int64(adminID) {
+		t.Errorf("Superadmin unable to log in from a banned network.\n")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var AdminBansHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	if !sess.IsUserSuperAdmin() {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+		if action == "Ban" {
+			userName := strings.TrimSpace(r.PostFormValue("username"))
+			network := strings.TrimSpace(r.PostFormValue("network"))
+			reason := strings.TrimSpace(r.PostFormValue("reason"))
+			days, err := strconv.Atoi(r.PostFormValue("days"))
+			if err != nil || days < 0 {
+				sess.SetFlashMsg("Ban duration should be a number of days.")
+				http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+				return
+			}
+			if userName == "" && network == "" {
+				sess.SetFlashMsg("Enter a username, a network, or both.")
+				http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+				return
+			}
+			if len(reason) > 250 {
+				sess.SetFlashMsg("Ban reason should have fewer than 250 characters.")
+				http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+				return
+			}
+			var userID sql.NullInt64
+			if userName != "" {
+				uid, err := models.ReadUserIDByName(userName)
+				if err != nil {
+					sess.SetFlashMsg("Username not found: " + userName)
+					http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+					return
+				}
+				userID = sql.NullInt64{Int64: int64(uid), Valid: true}
+			}
+			if network != "" && !models.IsValidBanNetwork(network) {
+				sess.SetFlashMsg("Network should be an IP address, a CIDR block like 10.0.0.0/8, or an i2p destination.")
+				http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+				return
+			}
+			var expiry time.Time
+			if days > 0 {
+				expiry = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+			}
+			models.CreateBan(userID, sess.UserID.Int64, reason, network, expiry)
+			logAction(sess, models.AuditBan, "user", strconv.FormatInt(userID.Int64, 10), "", network, banDesc(reason, days))
+			sess.SetFlashMsg("Ban created.")
+		} else if action == "Lift" {
+			models.LiftBan(r.PostFormValue("id"))
+			logAction(sess, models.AuditBanLift, "ban", r.PostFormValue("id"), "", "", "")
+			sess.SetFlashMsg("Ban lifted.")
+		}
+		http.Redirect(w, r, "/admin/bans", http.StatusSeeOther)
+		return
+	}
+
+	type Ban struct {
+		ID          string
+		UserName    string
+		AdminName   string
+		Reason      string
+		Network     string
+		IsActive    bool
+		IsLifted    bool
+		CreatedDate string
+		ExpiryDate  string
+	}
+	var bans []Ban
+	for _, b := range models.ReadBans(200) {
+		ban := Ban{
+			ID:          b.ID,
+			UserName:    b.UserName,
+			AdminName:   b.AdminName,
+			Reason:      b.Reason,
+			Network:     b.Network,
+			IsActive:    b.IsActive(),
+			IsLifted:    b.IsLifted,
+			CreatedDate: b.CreatedDate.Format("2006-01-02 15:04"),
+		}
+		if !b.IsPermanent() {
+			ban.ExpiryDate = b.ExpiryDate.Format("2006-01-02 15:04")
+		}
+		bans = append(bans, ban)
+	}
+
+	templates.Render(w, "adminbans.html", map[string]interface{}{
+		"Common":       readCommonData(r, sess),
+		"Bans":         bans,
+		"ShadowBanned": models.ReadShadowBannedUsers(),
+	})
+})
@@ -0,0 +1,217 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bulk moderation actions. Topics can be moved, closed, and reopened as well.
+const (
+	bulkDelete = "Delete"
+	bulkSpam   = "Spam"
+	bulkClose  = "Close"
+	bulkReopen = "Reopen"
+	bulkMove   = "Move"
+	bulkNuke   = "Nuke user"
+)
+
+// canBulkModerate reports whether the user may apply any bulk action to posts of the group.
+func canBulkModerate(sess Session, groupID string) bool {
+	return can(sess, models.CapDeleteOthers, groupID) || can(sess, models.CapClose, groupID) || can(sess, models.CapMoveTopics, groupID)
+}
+
+// bulkCap returns the capability needed for the action, or "" if the action does not apply to the type.
+func bulkCap(itemType string, action string) string {
+	if action == bulkDelete || action == bulkSpam {
+		return models.CapDeleteOthers
+	}
+	if itemType == "topic" && (action == bulkClose || action == bulkReopen) {
+		return models.CapClose
+	}
+	if itemType == "topic" && action == bulkMove {
+		return models.CapMoveTopics
+	}
+	return ""
+}
+
+// safeNextURL keeps redirects after a bulk action on this site.
+func safeNextURL(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		return "/"
+	}
+	return next
+}
+
+// BulkModerateHandler applies a moderation action to the topics or comments selected on a listing,
+// after the moderator confirms it. Items the moderator cannot act on are skipped. Superadmins can
+// also nuke a user: ban them and delete everything they posted.
+var BulkModerateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	if r.Method != "POST" {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	itemType := r.PostFormValue("type")
+	action := r.PostFormValue("action")
+	next := safeNextURL(r.PostFormValue("next"))
+	isConfirmed := r.PostFormValue("confirm") != ""
+	ids := r.PostForm["ids"]
+
+	if itemType == "user" && action == bulkNuke {
+		nukeUser(w, r, sess, ids, next, isConfirmed)
+		return
+	}
+	if (itemType != "topic" && itemType != "comment") || bulkCap(itemType, action) == "" {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	if len(ids) == 0 {
+		sess.SetFlashMsg("Nothing selected.")
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	targetName := strings.TrimSpace(r.PostFormValue("group"))
+	var targetGroupID string
+	if action == bulkMove {
+		targetGroupID = models.ReadGroupIDByName(targetName)
+		if targetGroupID == "" || !canMoveInto(sess, targetGroupID) {
+			sess.SetFlashMsg("You cannot move topics to that group.")
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+	}
+
+	type Item struct {
+		ID      string
+		TopicID string
+		Title   string
+		GroupID string
+		Content string
+	}
+	var items []Item
+	numSkipped := 0
+	for _, id := range ids {
+		var item Item
+		var err error
+		isGroupClosed := true
+		if itemType == "topic" {
+			err = db.QueryRow(`SELECT topics.id, topics.id, topics.title, topics.groupid, groups.is_closed FROM topics INNER JOIN groups ON topics.groupid=groups.id WHERE topics.id=?;`, id).Scan(
+				&item.ID, &item.TopicID, &item.Title, &item.GroupID, &isGroupClosed)
+		} else {
+			err = db.QueryRow(`SELECT comments.id, topics.id, topics.title, topics.groupid, groups.is_closed, comments.content FROM comments INNER JOIN topics ON comments.topicid=topics.id INNER JOIN groups ON topics.groupid=groups.id WHERE comments.id=?;`, id).Scan(
+				&item.ID, &item.TopicID, &item.Title, &item.GroupID, &isGroupClosed, &item.Content)
+		}
+		if err != nil || isGroupClosed || !can(sess, bulkCap(itemType, action), item.GroupID) || (action == bulkMove && item.GroupID == targetGroupID) {
+			numSkipped++
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if !isConfirmed {
+		templates.Render(w, "bulk.html", map[string]interface{}{
+			"Common":     readCommonData(r, sess),
+			"Type":       itemType,
+			"Action":     action,
+			"Group":      targetName,
+			"Next":       next,
+			"Items":      items,
+			"NumSkipped": numSkipped,
+		})
+		return
+	}
+
+	for _, item := range items {
+		if itemType == "topic" && action == bulkDelete {
+			models.DeleteTopic(item.ID)
+			logAction(sess, models.AuditTopicDelete, "topic", item.ID, item.GroupID, "", "")
+		} else if itemType == "topic" && action == bulkSpam {
+			models.DeleteTopic(item.ID)
+			models.TrainSpam("topic", item.ID, true)
+			logAction(sess, models.AuditTopicSpam, "topic", item.ID, item.GroupID, "", "")
+		} else if itemType == "topic" && action == bulkClose {
+			models.CloseTopic(item.ID, "")
+			logAction(sess, models.AuditTopicClose, "topic", item.ID, item.GroupID, "", "")
+		} else if itemType == "topic" && action == bulkReopen {
+			models.ReopenTopic(item.ID)
+			logAction(sess, models.AuditTopicReopen, "topic", item.ID, item.GroupID, "", "")
+		} else if itemType == "topic" && action == bulkMove {
+			var groupName string
+			db.QueryRow(`SELECT name FROM groups WHERE id=?;`, item.GroupID).Scan(&groupName)
+			models.MoveTopic(item.ID, targetGroupID)
+			logAction(sess, models.AuditTopicMove, "topic", item.ID, item.GroupID, groupName, targetName)
+		} else if itemType == "comment" && action == bulkDelete {
+			models.DeleteComment(item.ID)
+			logAction(sess, models.AuditCommentDelete, "comment", item.ID, item.GroupID, "", "")
+		} else if itemType == "comment" && action == bulkSpam {
+			models.DeleteComment(item.ID)
+			models.TrainSpam("comment", item.ID, true)
+			logAction(sess, models.AuditCommentSpam, "comment", item.ID, item.GroupID, "", "")
+		}
+	}
+	msg := strconv.Itoa(len(items)) + " " + itemType + "s updated."
+	if numSkipped > 0 {
+		msg = msg + " " + strconv.Itoa(numSkipped) + " skipped."
+	}
+	sess.SetFlashMsg(msg)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+})
+
+func nukeUser(w http.ResponseWriter, r *http.Request, sess Session, ids []string, next string, isConfirmed bool) {
+	if !sess.IsUserSuperAdmin() || len(ids) != 1 {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	var userID int64
+	var userName string
+	var isSuperAdmin bool
+	if db.QueryRow(`SELECT id, username, is_superadmin FROM users WHERE id=?;`, ids[0]).Scan(&userID, &userName, &isSuperAdmin) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if isSuperAdmin || userID == sess.UserID.Int64 {
+		sess.SetFlashMsg("Superadmins cannot be nuked.")
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	if !isConfirmed {
+		var numTopics, numComments int
+		db.QueryRow(`SELECT COUNT(*) FROM topics WHERE userid=? AND is_deleted=0;`, userID).Scan(&numTopics)
+		db.QueryRow(`SELECT COUNT(*) FROM comments WHERE userid=? AND is_deleted=0;`, userID).Scan(&numComments)
+		templates.Render(w, "bulk.html", map[string]interface{}{
+			"Common":      readCommonData(r, sess),
+			"Type":        "user",
+			"Action":      bulkNuke,
+			"Next":        next,
+			"UserID":      userID,
+			"UserName":    userName,
+			"NumTopics":   numTopics,
+			"NumComments": numComments,
+		})
+		return
+	}
+
+	reason := strings.TrimSpace(r.PostFormValue("ban_reason"))
+	if len(reason) > 250 {
+		reason = reason[:250]
+	}
+	if _, err := models.ReadActiveUserBan(userID); err != nil {
+		models.CreateBan(sql.NullInt64{Int64: userID, Valid: true}, sess.UserID.Int64, reason, "", time.Time{})
+		logAction(sess, models.AuditUserBan, "user", strconv.FormatInt(userID, 10), "", "", banDesc(reason, 0))
+	}
+	models.DeleteUserContent(userID)
+	logAction(sess, models.AuditUserNuke, "user", strconv.FormatInt(userID, 10), "", "", "")
+	sess.SetFlashMsg(userName + " has been banned and their posts deleted.")
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
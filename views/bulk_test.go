@@ -0,0 +1,64 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBulkModeration(t *testing.T) {
+	groupID := createGroupForTest("bulkgroup")
+	otherID := createGroupForTest("bulkother")
+	_, modSess := createUserForTest(t, "bulkmod")
+	spammerID, _ := createUserForTest(t, "bulkspammer")
+	models.CreateGroupMod("bulkmod", groupID)
+	var ids []string
+	for i, gid := range []string{groupID, groupID, otherID} {
+		db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+			"Buy cheap stuff "+strconv.Itoa(i), "", spammerID, gid, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+		var id string
+		db.QueryRow(`SELECT id FROM topics WHERE title=?;`, "Buy cheap stuff "+strconv.Itoa(i)).Scan(&id)
+		ids = append(ids, id)
+	}
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		"cheap stuff here", ids[2], spammerID, 1, time.Now().Unix(), time.Now().Unix())
+
+	adminSess := mustLoginForTest(t, "admin", "admin12345")
+
+	numDeleted := func() int {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM topics WHERE userid=? AND is_deleted=1;`, spammerID).Scan(&n)
+		return n
+	}
+	form := url.Values{"type": {"topic"}, "action": {"Delete"}, "ids": ids, "next": {"/groups?name=bulkgroup"}}
+	if rr := postForTest(BulkModerateHandler, "/bulk", modSess, form); rr.Code != http.StatusOK || numDeleted() != 0 {
+		t.Errorf("Bulk action not confirmed first: got %v, %v deleted\n", rr.Code, numDeleted())
+	}
+	form.Set("confirm", "1")
+	postForTest(BulkModerateHandler, "/bulk", modSess, form)
+	if n := numDeleted(); n != 2 {
+		t.Errorf("Expected 2 topics deleted, got %v\n", n)
+	}
+
+	nukeForm := url.Values{"type": {"user"}, "action": {"Nuke user"}, "ids": {strconv.Itoa(spammerID)}, "confirm": {"1"}}
+	if rr := postForTest(BulkModerateHandler, "/bulk", modSess, nukeForm); rr.Code != http.StatusForbidden {
+		t.Errorf("Moderator able to nuke a user: got %v\n", rr.Code)
+	}
+	postForTest(BulkModerateHandler, "/bulk", adminSess, nukeForm)
+	if _, err := models.ReadActiveUserBan(int64(spammerID)); err != nil {
+		t.Errorf("Nuked user not banned.\n")
+	}
+	var numComments int
+	db.QueryRow(`SELECT COUNT(*) FROM comments WHERE userid=? AND is_deleted=0;`, spammerID).Scan(&numComments)
+	if n := numDeleted(); n != 3 || numComments != 0 {
+		t.Errorf("Content of nuked user not deleted: %v topics deleted, %v comments left\n", n, numComments)
+	}
+}
@@ -19,82 +19,117 @@ import (
 var CommentIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	commentID := r.FormValue("id")
 	var groupID, topicID, topicName, groupName, ownerID, ownerName, content, imgSrc string
-	var cDate int64
-	var isDeleted bool
+	var cDate, eDate int64
+	var isDeleted, isPending, isShadowed bool
 
-	if db.QueryRow(`SELECT userid, topicid, content, image, is_deleted, created_date FROM comments WHERE id=?;`, commentID).Scan(
-		&ownerID, &topicID, &content, &imgSrc, &isDeleted, &cDate) != nil {
+	if db.QueryRow(`SELECT userid, topicid, content, image, is_deleted, is_pending, is_shadowed, created_date, edited_date FROM comments WHERE id=?;`, commentID).Scan(
+		&ownerID, &topicID, &content, &imgSrc, &isDeleted, &isPending, &isShadowed, &cDate, &eDate) != nil {
 		ErrNotFoundHandler(w, r)
 		return
 	}
 	db.QueryRow(`SELECT groupid, title FROM topics WHERE id=?;`, topicID).Scan(&groupID, &topicName)
+	isOwner := sess.UserID.Valid && ownerID == strconv.FormatInt(sess.UserID.Int64, 10)
+	canApprove := can(sess, models.CapApprovePosts, groupID)
+	if !models.CanUserViewGroup(groupID, sess.UserID) || ((isPending || isShadowed) && !isOwner && !canApprove) {
+		ErrNotFoundHandler(w, r)
+		return
+	}
 	db.QueryRow(`SELECT username FROM users WHERE id=?;`, ownerID).Scan(&ownerName)
 	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
 
-	var tmp string
-	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
-	isMod := sess.UserID.Valid && db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := sess.UserID.Valid && db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	if sess.UserID.Valid {
-		db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?`, sess.UserID).Scan(&isSuperAdmin)
-	}
-	isOwner := sess.UserID.Valid && db.QueryRow(`SELECT userid FROM comments WHERE id=?;`, commentID).Scan(&tmp) == nil
-
 	templates.Render(w, "commentindex.html", map[string]interface{}{
-		"Common":       readCommonData(r, sess),
-		"ID":           commentID,
-		"TopicID":      topicID,
-		"TopicName":    topicName,
-		"GroupName":    groupName,
-		"OwnerName":    ownerName,
-		"Content":      formatComment(content),
-		"ImgSrc":       imgSrc,
-		"IsMod":        isMod,
-		"IsAdmin":      isAdmin,
-		"IsSuperAdmin": isSuperAdmin,
-		"IsOwner":      isOwner,
-		"IsDeleted":    isDeleted,
-		"CreatedDate":  timeAgoFromNow(time.Unix(cDate, 0)),
+		"Common":      readCommonData(r, sess),
+		"ID":          commentID,
+		"TopicID":     topicID,
+		"TopicName":   topicName,
+		"TopicURL":    commentURL(sess, topicID, groupID, commentID),
+		"GroupName":   groupName,
+		"OwnerName":   ownerName,
+		"Content":     formatPost("comment", commentID, eDate, censor(groupID, content)),
+		"ImgSrc":      imgSrc,
+		"CanEdit":     isOwner || can(sess, models.CapEditOthers, groupID),
+		"IsOwner":     isOwner,
+		"IsDeleted":   isDeleted,
+		"IsPending":   isPending,
+		"IsShadowed":  isShadowed && canApprove,
+		"CreatedDate": timeAgoFromNow(time.Unix(cDate, 0)),
+		"EditedDate":  editedDateStr(eDate),
 	})
 })
 
 var CommentCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	topicID := r.FormValue("tid")
 	quoteID := r.FormValue("quote")
+	replyTo := r.FormValue("parent")
 	content := strings.TrimSpace(r.PostFormValue("content"))
 	isSticky := r.PostFormValue("is_sticky") != ""
 	isImageUploadEnabled := models.Config(models.ImageUploadEnabled) != "0"
 	var groupID, groupName, topicName, parentComment, topicOwnerID, topicOwnerName string
-	var topicCreatedDate int64
+	var topicCreatedDate, topicActivityDate int64
+	var isTopicPending, isTopicShadowed, isTopicClosed bool
 
-	if db.QueryRow(`SELECT userid, groupid, title, content, created_date FROM topics WHERE id=?;`, topicID).Scan(
-		&topicOwnerID, &groupID, &topicName, &parentComment, &topicCreatedDate) != nil {
+	if db.QueryRow(`SELECT userid, groupid, title, content, is_pending, is_shadowed, is_closed, created_date, activity_date FROM topics WHERE id=?;`, topicID).Scan(
+		&topicOwnerID, &groupID, &topicName, &parentComment, &isTopicPending, &isTopicShadowed, &isTopicClosed, &topicCreatedDate, &topicActivityDate) != nil {
 		ErrNotFoundHandler(w, r)
 		return
 	}
 	isClosed := true
 	db.QueryRow(`SELECT is_closed FROM groups WHERE id=?;`, groupID).Scan(&isClosed)
 
-	if isClosed {
+	if isTopicShadowed && topicOwnerID != strconv.FormatInt(sess.UserID.Int64, 10) && !can(sess, models.CapApprovePosts, groupID) {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if isClosed || isTopicPending || !models.CanUserViewGroup(groupID, sess.UserID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	if isTopicClosed && !can(sess, models.CapClose, groupID) {
 		ErrForbiddenHandler(w, r)
 		return
 	}
 	db.QueryRow(`SELECT username FROM users WHERE id=?;`, topicOwnerID).Scan(&topicOwnerName)
 
-	var tmp string
 	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
-	isMod := db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?;`, sess.UserID).Scan(&isSuperAdmin)
+	if !canPostComment(sess, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	canSticky := can(sess, models.CapSticky, groupID)
+	trust := trustLevel(sess)
+	isImageUploadEnabled = isImageUploadEnabled && can(sess, models.CapUploadImages, groupID) && trust > models.TrustLevelNew
+
+	if ban, err := models.ReadActiveGroupBan(groupID, sess.UserID.Int64); err == nil && !can(sess, models.CapBanUsers, groupID) {
+		http.Error(w, groupBanMsg(ban, groupName), http.StatusForbidden)
+		return
+	}
+
+	// Replies to topics that have been quiet for a long time have to be confirmed.
+	staleDays := models.ReadAutoClose(groupID).StaleDays
+	isStale := staleDays > 0 && time.Unix(topicActivityDate, 0).Before(time.Now().Add(-time.Duration(staleDays)*24*time.Hour))
+
+	// Replies go under the comment in the threaded view. Comments the user cannot see cannot be replied to.
+	formURL := "/comments/new?tid=" + topicID
+	if replyTo != "" {
+		var replyTopicID, replyOwnerID, replyContent string
+		var isDeleted, isPending, isShadowed bool
+		db.QueryRow(`SELECT topicid, userid, content, is_deleted, is_pending, is_shadowed FROM comments WHERE id=?;`, replyTo).Scan(
+			&replyTopicID, &replyOwnerID, &replyContent, &isDeleted, &isPending, &isShadowed)
+		if replyTopicID == topicID && !isDeleted && !isPending && (!isShadowed || replyOwnerID == strconv.FormatInt(sess.UserID.Int64, 10)) {
+			parentComment = replyContent
+			formURL = formURL + "&parent=" + replyTo
+		} else {
+			replyTo = ""
+		}
+	}
 
 	quoteContent := ""
 	if quoteID != "" {
 		var quotedUser string
-		var isDeleted bool
-		db.QueryRow(`SELECT comments.content, comments.is_deleted, users.username FROM comments INNER JOIN users ON comments.userid=users.id WHERE comments.id=?;`, quoteID).Scan(&quoteContent, &isDeleted, &quotedUser)
-		if !isDeleted {
+		var isDeleted, isPending, isShadowed bool
+		err := db.QueryRow(`SELECT comments.content, comments.is_deleted, comments.is_pending, comments.is_shadowed, users.username FROM comments INNER JOIN users ON comments.userid=users.id WHERE comments.id=? AND comments.topicid=?;`, quoteID, topicID).Scan(
+			&quoteContent, &isDeleted, &isPending, &isShadowed, &quotedUser)
+		if err == nil && !isDeleted && !isPending && !isShadowed {
 			quoteContent = formatReply(quotedUser, quoteContent)
 		} else {
 			quoteContent = ""
@@ -102,7 +137,7 @@ var CommentCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 	}
 
 	if r.Method == "POST" {
-		if !isMod && !isAdmin && !isSuperAdmin {
+		if !canSticky {
 			isSticky = false
 		}
 		imageName := ""
@@ -112,7 +147,38 @@ var CommentCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 
 		if (len(content) < 2 && imageName == "") || len(content) > 5000 {
 			sess.SetFlashMsg("Comment should have 2-5000 characters.")
-			http.Redirect(w, r, "/comments/new?tid="+topicID, http.StatusSeeOther)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		if msg := linkLimitMsg(trust, content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		if msg := mentionLimitMsg(sess, content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		if msg := rateLimitMsg(sess, "comment", 1); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		if msg := slowModeMsg(sess, groupID); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		if isStale && r.PostFormValue("confirm_stale") == "" {
+			sess.SetFlashMsg("This topic has had no activity for more than " + strconv.Itoa(staleDays) + " days. Confirm that you want to reply to it.")
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
+			return
+		}
+		ruleMsg, isHeld := contentRulesMsg(sess, groupID, content, false)
+		if ruleMsg != "" {
+			sess.SetFlashMsg(ruleMsg)
+			http.Redirect(w, r, formURL, http.StatusSeeOther)
 			return
 		}
 
@@ -123,29 +189,26 @@ var CommentCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 			newPos = -newPos
 		}
 
-		db.Exec(`INSERT INTO comments(content, image, topicid, userid, parentid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`,
-			content, imageName, topicID, sess.UserID, sql.NullInt64{Valid: false}, newPos, int64(time.Now().Unix()), int64(time.Now().Unix()))
-		db.Exec(`UPDATE topics SET num_comments=num_comments+1, activity_date=? WHERE id=?;`, int(time.Now().Unix()), topicID)
-		if models.Config(models.AllowTopicSubscription) != "0" {
+		parentID := sql.NullInt64{Valid: false}
+		if replyTo != "" {
+			parentID = models.ReplyParent(replyTo)
+		}
+		spamScore := models.SpamScore(content)
+		isShadowed := models.IsShadowBanned(sess.UserID.Int64)
+		isPending := !isShadowed && (needsApproval(sess, groupID, trust) || isHeldAsSpam(sess, groupID, spamScore) || isHeld)
+		db.Exec(`INSERT INTO comments(content, image, topicid, userid, parentid, pos, is_pending, is_shadowed, spam_score, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+			content, imageName, topicID, sess.UserID, parentID, newPos, isPending, isShadowed, spamScore, int64(time.Now().Unix()), int64(time.Now().Unix()))
+		var commentID string
+		db.QueryRow(`SELECT id FROM comments WHERE topicid=? AND userid=? ORDER BY id DESC LIMIT 1;`, topicID, sess.UserID).Scan(&commentID)
+		models.InvalidateThreadPages()
+		if !isPending && !isShadowed {
+			db.Exec(`UPDATE topics SET num_comments=num_comments+1, activity_date=? WHERE id=?;`, int(time.Now().Unix()), topicID)
 			var userName string
 			db.QueryRow(`SELECT username FROM users WHERE id=?;`, sess.UserID).Scan(&userName)
-			topicURL := "http://" + r.Host + "/topics?id=" + topicID
-			rows := db.Query(`SELECT users.email, topicsubscriptions.token FROM users INNER JOIN topicsubscriptions ON users.id=topicsubscriptions.userid AND topicsubscriptions.topicid=?;`, topicID)
-			for rows.Next() {
-				var email, token string
-				rows.Scan(&email, &token)
-				if email != "" {
-					unSubURL := "http://" + r.Host + "/topics/unsubscribe?token=" + token
-					utils.SendMail(email, `New comment in "`+topicName+`"`,
-						"A new comment has been posted by "+userName+" in \""+topicName+"\".\r\nSee the comment at "+topicURL+"\r\n\r\nIf you do not want these emails, unsubscribe by following this link: "+unSubURL)
-				}
-			}
+			notifyTopicSubscribers(r, topicID, groupID, topicName, userName)
+			notifyMentions(r, sess.UserID.Int64, "comment", commentID)
 		}
-		page := newPos / numCommentsPerPage
-		if page < 0 {
-			page = 0
-		}
-		http.Redirect(w, r, "/topics?id="+topicID+"&p="+strconv.Itoa(page)+"#comment-last", http.StatusSeeOther)
+		http.Redirect(w, r, commentURL(sess, topicID, groupID, commentID), http.StatusSeeOther)
 		return
 	}
 
@@ -158,12 +221,14 @@ var CommentCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 		"TopicName":            topicName,
 		"GroupName":            groupName,
 		"ParentComment":        parentComment,
+		"ReplyTo":              replyTo,
 		"Content":              quoteContent,
 		"IsSticky":             false,
-		"IsMod":                isMod,
-		"IsAdmin":              isAdmin,
-		"IsSuperAdmin":         isSuperAdmin,
+		"CanSticky":            canSticky,
+		"CanDelete":            false,
 		"IsImageUploadEnabled": isImageUploadEnabled,
+		"IsStale":              isStale,
+		"StaleDays":            staleDays,
 	})
 })
 
@@ -190,7 +255,7 @@ var CommentUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 		db.QueryRow(`SELECT is_closed FROM topics WHERE id=?;`, topicID).Scan(&isClosed)
 	}
 
-	if isClosed {
+	if isClosed || !models.CanUserViewGroup(groupID, sess.UserID) {
 		ErrForbiddenHandler(w, r)
 		return
 	}
@@ -199,13 +264,15 @@ var CommentUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 
 	var tmp string
 	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
-	isMod := db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?`, sess.UserID).Scan(&isSuperAdmin)
-	isOwner := db.QueryRow(`SELECT userid FROM comments WHERE id=?;`, commentID).Scan(&tmp) == nil
+	// In read-only groups, owners can no longer change their comments but moderators can.
+	isOwner := db.QueryRow(`SELECT id FROM comments WHERE id=? AND userid=?;`, commentID, sess.UserID).Scan(&tmp) == nil
+	isReadOnly := models.ReadGroupPolicy(groupID) == models.GroupPolicyReadOnly
+	canEdit := (isOwner && !isReadOnly) || can(sess, models.CapEditOthers, groupID)
+	canSticky := can(sess, models.CapSticky, groupID)
+	canDelete := (isOwner && !isReadOnly) || can(sess, models.CapDeleteOthers, groupID)
+	canMarkSpam := can(sess, models.CapDeleteOthers, groupID)
 
-	if !isOwner && !isMod && !isAdmin && !isSuperAdmin {
+	if !canEdit && !canSticky && !canDelete && !canMarkSpam {
 		ErrForbiddenHandler(w, r)
 		return
 	}
@@ -213,16 +280,37 @@ var CommentUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 	if r.Method == "POST" {
 		action := r.PostFormValue("action")
 		if action == "Update" {
+			var oldContent string
+			var oldPos int
+			db.QueryRow(`SELECT content, pos FROM comments WHERE id=?;`, commentID).Scan(&oldContent, &oldPos)
+			if !canEdit {
+				content = oldContent
+			}
 			if len(content) < 2 || len(content) > 5000 {
 				sess.SetFlashMsg("Comment should have 2-5000 characters.")
 				http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
 				return
 			}
+			if msg := linkLimitMsg(trustLevel(sess), content); msg != "" {
+				sess.SetFlashMsg(msg)
+				http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
+				return
+			}
+			if msg := mentionLimitMsg(sess, content); msg != "" {
+				sess.SetFlashMsg(msg)
+				http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
+				return
+			}
+			if msg, _ := contentRulesMsg(sess, groupID, content, true); msg != "" {
+				sess.SetFlashMsg(msg)
+				http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
+				return
+			}
 			if content == "" {
 				http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
 				return
 			}
-			if !isMod && !isAdmin && !isSuperAdmin {
+			if !canSticky {
 				isSticky = (pos < 0)
 			}
 			if isSticky {
@@ -234,19 +322,43 @@ var CommentUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 					pos = -pos
 				}
 			}
-			db.Exec(`UPDATE comments SET content=?, pos=?, updated_date=? WHERE id=?;`, content, pos, int64(time.Now().Unix()), commentID)
-			page := pos / numCommentsPerPage
-			if page < 0 {
-				page = 0
+			db.Exec(`UPDATE comments SET pos=?, updated_date=? WHERE id=?;`, pos, int64(time.Now().Unix()), commentID)
+			if pos != oldPos {
+				models.InvalidateThreadPages()
+			}
+			if content != oldContent {
+				models.EditPost("comment", commentID, sess.UserID.Int64, "", content)
 			}
-			http.Redirect(w, r, "/topics?id="+topicID+"&p="+strconv.Itoa(page)+"#comment-"+commentID, http.StatusSeeOther)
+			if isOwner && content != oldContent {
+				notifyMentions(r, sess.UserID.Int64, "comment", commentID)
+			}
+			if !isOwner && content != oldContent {
+				logAction(sess, models.AuditCommentEdit, "comment", commentID, groupID, oldContent, content)
+			}
+			if (oldPos < 0) != (pos < 0) {
+				logAction(sess, models.AuditCommentSticky, "comment", commentID, groupID, boolStr(oldPos < 0), boolStr(pos < 0))
+			}
+			http.Redirect(w, r, commentURL(sess, topicID, groupID, commentID), http.StatusSeeOther)
+		}
+		if action == "Delete" && canDelete {
+			models.DeleteComment(commentID)
+			logAction(sess, models.AuditCommentDelete, "comment", commentID, groupID, "", "")
+			http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
+		}
+		if action == "Undelete" && canDelete {
+			models.UndeleteComment(commentID)
+			logAction(sess, models.AuditCommentUndelete, "comment", commentID, groupID, "", "")
+			http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
 		}
-		if action == "Delete" {
-			db.Exec(`UPDATE comments SET is_deleted=1 WHERE id=?;`, commentID)
+		if action == "Spam" && canMarkSpam {
+			models.DeleteComment(commentID)
+			models.TrainSpam("comment", commentID, true)
+			logAction(sess, models.AuditCommentSpam, "comment", commentID, groupID, "", "")
 			http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
 		}
-		if action == "Undelete" {
-			db.Exec(`UPDATE comments SET is_deleted=0 WHERE id=?;`, commentID)
+		if action == "Not spam" && canMarkSpam {
+			models.TrainSpam("comment", commentID, false)
+			logAction(sess, models.AuditCommentNotSpam, "comment", commentID, groupID, "", "")
 			http.Redirect(w, r, "/comments/edit?id="+commentID, http.StatusSeeOther)
 		}
 		return
@@ -266,10 +378,29 @@ var CommentUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess S
 		"ParentComment":        parentComment,
 		"Content":              content,
 		"IsSticky":             isSticky,
-		"IsMod":                isMod,
-		"IsAdmin":              isAdmin,
-		"IsSuperAdmin":         isSuperAdmin,
+		"CanEdit":              canEdit,
+		"CanSticky":            canSticky,
+		"CanDelete":            canDelete,
+		"CanMarkSpam":          canMarkSpam,
 		"IsDeleted":            isDeleted,
 		"IsImageUploadEnabled": false,
 	})
 })
+
+// notifyTopicSubscribers emails the subscribers of the topic about a new comment by userName.
+func notifyTopicSubscribers(r *http.Request, topicID string, groupID string, topicName string, userName string) {
+	if models.Config(models.AllowTopicSubscription) == "0" {
+		return
+	}
+	topicURL := "http://" + r.Host + "/topics?id=" + topicID
+	rows := db.Query(`SELECT users.email, topicsubscriptions.token FROM users INNER JOIN topicsubscriptions ON users.id=topicsubscriptions.userid AND topicsubscriptions.topicid=? INNER JOIN groups ON groups.id=? WHERE `+models.GroupViewersCond+`;`, topicID, groupID)
+	for rows.Next() {
+		var email, token string
+		rows.Scan(&email, &token)
+		if email != "" {
+			unSubURL := "http://" + r.Host + "/topics/unsubscribe?token=" + token
+			utils.SendMail(email, `New comment in "`+topicName+`"`,
+				"A new comment has been posted by "+userName+" in \""+topicName+"\".\r\nSee the comment at "+topicURL+"\r\n\r\nIf you do not want these emails, unsubscribe by following this link: "+unSubURL)
+		}
+	}
+}
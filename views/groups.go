@@ -5,6 +5,7 @@
 package views
 
 import (
+	"database/sql"
 	"github.com/s-gv/orangeforum/models"
 	"github.com/s-gv/orangeforum/models/db"
 	"github.com/s-gv/orangeforum/templates"
@@ -16,12 +17,37 @@ import (
 
 var GroupIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	name := r.FormValue("name")
-	var groupID, groupDesc, headerMsg string
-	if db.QueryRow(`SELECT id, description, header_msg FROM groups WHERE name=?;`, name).Scan(&groupID, &groupDesc, &headerMsg) != nil {
+	var groupID, groupDesc, headerMsg, policy string
+	var isArchived bool
+	var slowModeSecs int64
+	if db.QueryRow(`SELECT id, description, header_msg, post_policy, is_archived, slow_mode_secs FROM groups WHERE name=?;`, name).Scan(&groupID, &groupDesc, &headerMsg, &policy, &isArchived, &slowModeSecs) != nil {
+		if newName, err := models.ReadGroupNameByOldName(name); err == nil {
+			q := r.URL.Query()
+			q.Set("name", newName)
+			http.Redirect(w, r, r.URL.Path+"?"+q.Encode(), http.StatusMovedPermanently)
+			return
+		}
 		ErrNotFoundHandler(w, r)
 		return
 	}
 
+	if !models.CanUserViewGroup(groupID, sess.UserID) {
+		commonData := readCommonData(r, sess)
+		commonData.PageTitle = name
+		memberStatus := ""
+		if sess.UserID.Valid {
+			memberStatus = models.ReadGroupMemberStatus(groupID, sess.UserID.Int64)
+		}
+		templates.Render(w, "groupjoin.html", map[string]interface{}{
+			"Common":      commonData,
+			"GroupName":   name,
+			"GroupID":     groupID,
+			"IsRequested": memberStatus == models.GroupMemberStatusRequested,
+			"IsInvited":   memberStatus == models.GroupMemberStatusInvited,
+		})
+		return
+	}
+
 	subToken := ""
 	if sess.UserID.Valid {
 		db.QueryRow(`SELECT token FROM groupsubscriptions WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&subToken)
@@ -38,34 +64,32 @@ var GroupIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 		Title       string
 		IsDeleted   bool
 		IsClosed    bool
+		IsPending   bool
+		IsShadowed  bool
 		Owner       string
 		NumComments int
 		CreatedDate string
 		cDateUnix   int64
 	}
+	canApprove := can(sess, models.CapApprovePosts, groupID)
 	var topics []Topic
 	var rows *db.Rows
 	if lastTopicDate == 0 {
-		rows = db.Query(`SELECT topics.id, topics.title, topics.is_deleted, topics.is_closed, topics.num_comments, topics.created_date, users.username FROM topics INNER JOIN users ON topics.userid = users.id AND topics.groupid=? ORDER BY topics.is_sticky DESC, topics.activity_date DESC LIMIT ?;`, groupID, numTopicsPerPage)
+		rows = db.Query(`SELECT topics.id, topics.title, topics.is_deleted, topics.is_closed, topics.is_pending, topics.is_shadowed, topics.num_comments, topics.created_date, users.username FROM topics INNER JOIN users ON topics.userid = users.id AND topics.groupid=? WHERE (topics.is_pending=0 OR topics.userid=? OR ?=1) AND (topics.is_shadowed=0 OR topics.userid=? OR ?=1) ORDER BY topics.is_sticky DESC, topics.activity_date DESC LIMIT ?;`, groupID, sess.UserID, canApprove, sess.UserID, canApprove, numTopicsPerPage)
 	} else {
-		rows = db.Query(`SELECT topics.id, topics.title, topics.is_deleted, topics.is_closed, topics.num_comments, topics.created_date, users.username FROM topics INNER JOIN users ON topics.userid = users.id AND topics.groupid=? AND topics.is_sticky=0 AND topics.created_date < ? ORDER BY topics.activity_date DESC LIMIT ?;`, groupID, lastTopicDate, numTopicsPerPage)
+		rows = db.Query(`SELECT topics.id, topics.title, topics.is_deleted, topics.is_closed, topics.is_pending, topics.is_shadowed, topics.num_comments, topics.created_date, users.username FROM topics INNER JOIN users ON topics.userid = users.id AND topics.groupid=? AND topics.is_sticky=0 AND topics.created_date < ? WHERE (topics.is_pending=0 OR topics.userid=? OR ?=1) AND (topics.is_shadowed=0 OR topics.userid=? OR ?=1) ORDER BY topics.activity_date DESC LIMIT ?;`, groupID, lastTopicDate, sess.UserID, canApprove, sess.UserID, canApprove, numTopicsPerPage)
 	}
 	for rows.Next() {
 		t := Topic{}
-		rows.Scan(&t.ID, &t.Title, &t.IsDeleted, &t.IsClosed, &t.NumComments, &t.cDateUnix, &t.Owner)
+		rows.Scan(&t.ID, &t.Title, &t.IsDeleted, &t.IsClosed, &t.IsPending, &t.IsShadowed, &t.NumComments, &t.cDateUnix, &t.Owner)
 		t.CreatedDate = timeAgoFromNow(time.Unix(t.cDateUnix, 0))
-		t.Title = censor(t.Title)
+		t.Title = censor(groupID, t.Title)
 		topics = append(topics, t)
 	}
 
-	isSuperAdmin := false
-	isAdmin := false
-	isMod := false
+	isMember := false
 	if sess.IsUserValid() {
-		db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?;`, sess.UserID).Scan(&isSuperAdmin)
-		var tmp string
-		isAdmin = db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-		isMod = db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
+		isMember = models.ReadGroupMemberStatus(groupID, sess.UserID.Int64) == models.GroupMemberStatusMember
 	}
 
 	if len(topics) >= numTopicsPerPage {
@@ -74,21 +98,43 @@ var GroupIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 		lastTopicDate = 0
 	}
 
+	canHandleReports := can(sess, models.CapHandleReports, groupID)
+	numReports := 0
+	if canHandleReports {
+		numReports = models.NumActiveReports(groupID)
+	}
+
+	numPending := 0
+	if canApprove {
+		numPending = models.NumPendingPosts(groupID)
+	}
+
 	commonData := readCommonData(r, sess)
 	commonData.PageTitle = name
 
 	templates.Render(w, "groupindex.html", map[string]interface{}{
-		"Common":        commonData,
-		"GroupName":     name,
-		"GroupDesc":     censor(groupDesc),
-		"GroupID":       groupID,
-		"HeaderMsg":     censor(headerMsg),
-		"SubToken":      subToken,
-		"Topics":        topics,
-		"IsMod":         isMod,
-		"IsAdmin":       isAdmin,
-		"IsSuperAdmin":  isSuperAdmin,
-		"LastTopicDate": lastTopicDate,
+		"Common":           commonData,
+		"GroupName":        name,
+		"GroupDesc":        censor(groupID, groupDesc),
+		"GroupID":          groupID,
+		"HeaderMsg":        censor(groupID, headerMsg),
+		"SubToken":         subToken,
+		"Topics":           topics,
+		"CanManage":        canManageGroup(sess, groupID),
+		"CanPostTopic":     canPostTopic(sess, groupID) || (!sess.UserID.Valid && !isArchived && policy == models.GroupPolicyOpen),
+		"Policy":           policy,
+		"IsArchived":       isArchived,
+		"NumReports":       numReports,
+		"CanHandleReports": canHandleReports,
+		"CanApprove":       canApprove,
+		"NumPending":       numPending,
+		"CanSeeTrash":      canSeeTrash(sess, groupID),
+		"CanBulk":          canBulkModerate(sess, groupID),
+		"SlowMode":         slowModeSecs,
+		"SlowModeStr":      slowModeStr(slowModeSecs),
+		"CanSlowMode":      can(sess, models.CapClose, groupID),
+		"IsMember":         isMember,
+		"LastTopicDate":    lastTopicDate,
 	})
 })
 
@@ -107,7 +153,30 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 	headerMsg := strings.TrimSpace(r.FormValue("header_msg"))
 	isSticky := r.FormValue("is_sticky") != ""
 	isPrivate := r.FormValue("is_private") != ""
+	isThreaded := r.FormValue("is_threaded") != ""
+	policy := r.FormValue("post_policy")
+	premodLevel, err := strconv.Atoi(r.FormValue("premod_level"))
+	if err != nil {
+		premodLevel = models.PremodOff
+	}
+	// Settings left blank are off; anything else that is not a number is rejected below.
+	isAutoCloseNumeric := true
+	autoCloseSetting := func(key string) int {
+		if r.FormValue(key) == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(r.FormValue(key))
+		if err != nil {
+			isAutoCloseNumeric = false
+		}
+		return n
+	}
+	var autoClose models.AutoClose
+	autoClose.IdleDays = autoCloseSetting("autoclose_days")
+	autoClose.MaxComments = autoCloseSetting("autoclose_comments")
+	autoClose.StaleDays = autoCloseSetting("stale_reply_days")
 	isDeleted := false
+	isArchived := false
 	mods := strings.Split(r.FormValue("mods"), ",")
 	for i, mod := range mods {
 		mods[i] = strings.TrimSpace(mod)
@@ -121,21 +190,33 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 	}
 	action := r.FormValue("action")
 
+	canEdit := true
+	canManageMods := true
 	if groupID != "" {
-		if !models.IsUserGroupAdmin(strconv.Itoa(int(sess.UserID.Int64)), groupID) && !commonData.IsSuperAdmin {
+		canEdit = can(sess, models.CapEditGroup, groupID)
+		canManageMods = can(sess, models.CapManageMods, groupID)
+		if !canManageGroup(sess, groupID) {
 			ErrForbiddenHandler(w, r)
 			return
 		}
 	}
+	canStickyGroup := can(sess, models.CapStickyGroup, "")
+	if !canStickyGroup {
+		isSticky = false
+	}
 
 	if r.Method == "POST" {
+		if !canEdit {
+			ErrForbiddenHandler(w, r)
+			return
+		}
 		if action == "Create" {
 			if len(name) < 3 || len(name) > 40 {
 				sess.SetFlashMsg("Group name should have 3-40 characters.")
 				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
 				return
 			}
-			if censored := censor(name); censored != name {
+			if censored := censor("", name); censored != name {
 				sess.SetFlashMsg("Fix group name: " + censored)
 				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
 				return
@@ -160,8 +241,29 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
 				return
 			}
-			db.Exec(`INSERT INTO groups(name, description, header_msg, is_sticky, is_private, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?);`, name, desc, headerMsg, isSticky, isPrivate, time.Now().Unix(), time.Now().Unix())
+			if !models.IsValidGroupPolicy(policy) {
+				sess.SetFlashMsg("Invalid posting policy.")
+				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
+				return
+			}
+			if !models.IsValidPremodLevel(premodLevel) {
+				sess.SetFlashMsg("Invalid pre-moderation level.")
+				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
+				return
+			}
+			if !isAutoCloseNumeric || !autoClose.IsValid() {
+				sess.SetFlashMsg("Auto-close settings should be numbers.")
+				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
+				return
+			}
+			if models.ReadGroupIDByName(name) != "" {
+				sess.SetFlashMsg("Group name already taken.")
+				http.Redirect(w, r, "/groups/edit", http.StatusSeeOther)
+				return
+			}
+			db.Exec(`INSERT INTO groups(name, description, header_msg, is_sticky, is_private, is_threaded, post_policy, premod_level, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`, name, desc, headerMsg, isSticky, isPrivate, isThreaded, policy, premodLevel, time.Now().Unix(), time.Now().Unix())
 			groupID := models.ReadGroupIDByName(name)
+			models.UpdateAutoClose(groupID, autoClose)
 			for _, mod := range mods {
 				if mod != "" {
 					models.CreateGroupMod(mod, groupID)
@@ -172,6 +274,7 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 					models.CreateGroupAdmin(admin, groupID)
 				}
 			}
+			logAction(sess, models.AuditGroupCreate, "group", groupID, groupID, "", name)
 			http.Redirect(w, r, "/groups?name="+name, http.StatusSeeOther)
 		} else if action == "Update" {
 			if len(name) < 3 || len(name) > 40 {
@@ -179,7 +282,7 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
 				return
 			}
-			if censored := censor(name); censored != name {
+			if censored := censor("", name); censored != name {
 				sess.SetFlashMsg("Fix group name: " + censored)
 				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
 				return
@@ -204,58 +307,309 @@ var GroupEditHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sessi
 				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
 				return
 			}
-			isUserSuperAdmin := false
-			db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?;`, sess.UserID).Scan(&isUserSuperAdmin)
-			if !isUserSuperAdmin {
+			if !canStickyGroup {
 				db.QueryRow(`SELECT is_sticky FROM groups WHERE id=?;`, groupID).Scan(&isSticky)
 			}
-			db.Exec(`UPDATE groups SET name=?, description=?, header_msg=?, is_sticky=?, is_private=?, updated_date=? WHERE id=?;`, name, desc, headerMsg, isSticky, isPrivate, time.Now().Unix(), groupID)
-			db.Exec(`DELETE FROM mods WHERE groupid=?;`, groupID)
-			db.Exec(`DELETE FROM admins WHERE groupid=?;`, groupID)
-			for _, mod := range mods {
-				if mod != "" {
-					models.CreateGroupMod(mod, groupID)
-				}
+			if !models.IsValidGroupPolicy(policy) {
+				sess.SetFlashMsg("Invalid posting policy.")
+				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+				return
 			}
-			for _, admin := range admins {
-				if admin != "" {
-					models.CreateGroupAdmin(admin, groupID)
+			if !models.IsValidPremodLevel(premodLevel) {
+				sess.SetFlashMsg("Invalid pre-moderation level.")
+				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+				return
+			}
+			if !isAutoCloseNumeric || !autoClose.IsValid() {
+				sess.SetFlashMsg("Auto-close settings should be numbers.")
+				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+				return
+			}
+			if otherID := models.ReadGroupIDByName(name); otherID != "" && otherID != groupID {
+				sess.SetFlashMsg("Group name already taken.")
+				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+				return
+			}
+			var oldName, oldDesc, oldHeaderMsg, oldPolicy string
+			var oldSticky, oldPrivate, oldThreaded bool
+			var oldPremodLevel int
+			db.QueryRow(`SELECT name, description, header_msg, is_sticky, is_private, is_threaded, post_policy, premod_level FROM groups WHERE id=?;`, groupID).Scan(
+				&oldName, &oldDesc, &oldHeaderMsg, &oldSticky, &oldPrivate, &oldThreaded, &oldPolicy, &oldPremodLevel)
+			oldAutoClose := models.ReadAutoClose(groupID)
+			oldMods := strings.Join(models.ReadMods(groupID), ", ")
+			oldAdmins := strings.Join(models.ReadAdmins(groupID), ", ")
+
+			models.RenameGroup(groupID, name)
+			db.Exec(`UPDATE groups SET description=?, header_msg=?, is_sticky=?, is_private=?, is_threaded=?, post_policy=?, premod_level=?, updated_date=? WHERE id=?;`, desc, headerMsg, isSticky, isPrivate, isThreaded, policy, premodLevel, time.Now().Unix(), groupID)
+			if name != oldName {
+				logAction(sess, models.AuditGroupRename, "group", groupID, groupID, oldName, name)
+			}
+			if desc != oldDesc || headerMsg != oldHeaderMsg || isSticky != oldSticky || isPrivate != oldPrivate || isThreaded != oldThreaded || policy != oldPolicy || premodLevel != oldPremodLevel {
+				logAction(sess, models.AuditGroupEdit, "group", groupID, groupID,
+					groupSettingsDesc(oldDesc, oldHeaderMsg, oldSticky, oldPrivate, oldThreaded, oldPolicy, oldPremodLevel), groupSettingsDesc(desc, headerMsg, isSticky, isPrivate, isThreaded, policy, premodLevel))
+			}
+			models.UpdateAutoClose(groupID, autoClose)
+			if autoClose != oldAutoClose {
+				logAction(sess, models.AuditGroupAutoClose, "group", groupID, groupID, oldAutoClose.String(), autoClose.String())
+			}
+			if canManageMods {
+				db.Exec(`DELETE FROM mods WHERE groupid=?;`, groupID)
+				db.Exec(`DELETE FROM admins WHERE groupid=?;`, groupID)
+				for _, mod := range mods {
+					if mod != "" {
+						models.CreateGroupMod(mod, groupID)
+					}
+				}
+				for _, admin := range admins {
+					if admin != "" {
+						models.CreateGroupAdmin(admin, groupID)
+					}
+				}
+				if newMods := strings.Join(models.ReadMods(groupID), ", "); newMods != oldMods {
+					logAction(sess, models.AuditGroupMods, "group", groupID, groupID, oldMods, newMods)
+				}
+				if newAdmins := strings.Join(models.ReadAdmins(groupID), ", "); newAdmins != oldAdmins {
+					logAction(sess, models.AuditGroupAdmins, "group", groupID, groupID, oldAdmins, newAdmins)
 				}
 			}
 			http.Redirect(w, r, "/groups?name="+name, http.StatusSeeOther)
 		} else if action == "Delete" {
-			db.Exec(`UPDATE groups SET is_closed=1 WHERE id=?;`, groupID)
+			models.DeleteGroup(groupID)
+			logAction(sess, models.AuditGroupDelete, "group", groupID, groupID, "", "")
 			http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
 		} else if action == "Undelete" {
-			db.Exec(`UPDATE groups SET is_closed=0 WHERE id=?;`, groupID)
+			models.UndeleteGroup(groupID)
+			logAction(sess, models.AuditGroupUndelete, "group", groupID, groupID, "", "")
+			http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+		} else if action == "Archive" {
+			db.Exec(`UPDATE groups SET is_archived=1, updated_date=? WHERE id=?;`, time.Now().Unix(), groupID)
+			logAction(sess, models.AuditGroupArchive, "group", groupID, groupID, "", "")
 			http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+		} else if action == "Unarchive" {
+			db.Exec(`UPDATE groups SET is_archived=0, updated_date=? WHERE id=?;`, time.Now().Unix(), groupID)
+			logAction(sess, models.AuditGroupUnarchive, "group", groupID, groupID, "", "")
+			http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+		} else if action == "Merge" {
+			if !commonData.IsSuperAdmin {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			var targetID string
+			isTargetClosed := true
+			into := strings.TrimSpace(r.FormValue("into"))
+			if db.QueryRow(`SELECT id, is_closed FROM groups WHERE name=?;`, into).Scan(&targetID, &isTargetClosed) != nil || isTargetClosed || targetID == groupID {
+				sess.SetFlashMsg("Cannot merge into group: " + into)
+				http.Redirect(w, r, "/groups/edit?id="+groupID, http.StatusSeeOther)
+				return
+			}
+			var oldName string
+			db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&oldName)
+			models.MergeGroups(groupID, targetID)
+			logAction(sess, models.AuditGroupMerge, "group", groupID, targetID, oldName, into)
+			http.Redirect(w, r, "/groups?name="+into, http.StatusSeeOther)
 		}
 		return
 	}
 
 	if groupID != "" {
 		// Open to edit
-		db.QueryRow(`SELECT name, description, header_msg, is_sticky, is_private, is_closed FROM groups WHERE id=?;`, groupID).Scan(
-			&name, &desc, &headerMsg, &isSticky, &isPrivate, &isDeleted,
+		db.QueryRow(`SELECT name, description, header_msg, is_sticky, is_private, is_threaded, post_policy, premod_level, is_closed, is_archived FROM groups WHERE id=?;`, groupID).Scan(
+			&name, &desc, &headerMsg, &isSticky, &isPrivate, &isThreaded, &policy, &premodLevel, &isDeleted, &isArchived,
 		)
 		mods = models.ReadMods(groupID)
 		admins = models.ReadAdmins(groupID)
+		autoClose = models.ReadAutoClose(groupID)
+	}
+
+	canManageMembers := groupID != "" && can(sess, models.CapManageMembers, groupID)
+	canBan := groupID != "" && can(sess, models.CapBanUsers, groupID)
+	var members, joinRequests, invitations []string
+	if canManageMembers {
+		members = models.ReadGroupMembers(groupID, models.GroupMemberStatusMember)
+		joinRequests = models.ReadGroupMembers(groupID, models.GroupMemberStatusRequested)
+		invitations = models.ReadGroupMembers(groupID, models.GroupMemberStatusInvited)
+	}
+
+	type GroupBan struct {
+		ID          string
+		UserName    string
+		AdminName   string
+		Reason      string
+		IsMute      bool
+		CreatedDate string
+		ExpiryDate  string
+	}
+	var bans []GroupBan
+	if canBan {
+		for _, b := range models.ReadActiveGroupBans(groupID) {
+			ban := GroupBan{
+				ID:          b.ID,
+				UserName:    b.UserName,
+				AdminName:   b.AdminName,
+				Reason:      b.Reason,
+				IsMute:      b.IsMute(),
+				CreatedDate: b.CreatedDate.Format("2006-01-02 15:04"),
+			}
+			if !b.IsPermanent() {
+				ban.ExpiryDate = b.ExpiryDate.Format("2006-01-02 15:04")
+			}
+			bans = append(bans, ban)
+		}
 	}
 
 	templates.Render(w, "groupedit.html", map[string]interface{}{
-		"Common":    readCommonData(r, sess),
-		"ID":        groupID,
-		"GroupName": name,
-		"Desc":      desc,
-		"HeaderMsg": headerMsg,
-		"IsSticky":  isSticky,
-		"IsPrivate": isPrivate,
-		"IsDeleted": isDeleted,
-		"Mods":      strings.Join(mods, ", "),
-		"Admins":    strings.Join(admins, ", "),
+		"Common":     readCommonData(r, sess),
+		"ID":         groupID,
+		"GroupName":  name,
+		"Desc":       desc,
+		"HeaderMsg":  headerMsg,
+		"IsSticky":   isSticky,
+		"IsPrivate":  isPrivate,
+		"IsThreaded": isThreaded,
+		"Policy":     policy,
+		"Premod":     premodLevel,
+		"AutoClose":  autoClose,
+		"IsDeleted":  isDeleted,
+		"Mods":       strings.Join(mods, ", "),
+		"Admins":     strings.Join(admins, ", "),
+		"CanEdit":    canEdit,
+		"Bans":       bans,
+
+		"CanManageMods":    canManageMods,
+		"CanStickyGroup":   canStickyGroup,
+		"CanManageMembers": canManageMembers,
+		"CanBan":           canBan,
+		"CanMerge":         groupID != "" && commonData.IsSuperAdmin,
+		"IsArchived":       isArchived,
+
+		"Members":      members,
+		"JoinRequests": joinRequests,
+		"Invitations":  invitations,
 	})
 })
 
+var GroupMembersHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.PostFormValue("gid")
+	var groupName string
+	var isPrivate bool
+	if r.Method != "POST" || db.QueryRow(`SELECT name, is_private FROM groups WHERE id=?;`, groupID).Scan(&groupName, &isPrivate) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	action := r.PostFormValue("action")
+	status := models.ReadGroupMemberStatus(groupID, sess.UserID.Int64)
+
+	if action == "Request to join" || action == "Accept invitation" || action == "Decline" || action == "Cancel request" || action == "Leave group" {
+		if action == "Request to join" && status == "" && isPrivate {
+			models.SetGroupMemberStatus(groupID, sess.UserID.Int64, models.GroupMemberStatusRequested)
+		} else if action == "Accept invitation" && status == models.GroupMemberStatusInvited {
+			models.SetGroupMemberStatus(groupID, sess.UserID.Int64, models.GroupMemberStatusMember)
+		} else if action == "Decline" || action == "Cancel request" || action == "Leave group" {
+			models.DeleteGroupMember(groupID, sess.UserID.Int64)
+		}
+		http.Redirect(w, r, "/groups?name="+groupName, http.StatusSeeOther)
+		return
+	}
+
+	if !can(sess, models.CapManageMembers, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/groups/edit?id=" + groupID
+	userName := strings.TrimSpace(r.PostFormValue("username"))
+	userID, err := models.ReadUserIDByName(userName)
+	if err != nil {
+		sess.SetFlashMsg("Username not found: " + userName)
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+	status = models.ReadGroupMemberStatus(groupID, int64(userID))
+	if action == "Invite" {
+		if status == models.GroupMemberStatusRequested {
+			models.SetGroupMemberStatus(groupID, int64(userID), models.GroupMemberStatusMember)
+			sess.SetFlashMsg(userName + " had asked to join and is now a member.")
+		} else if status == "" {
+			models.SetGroupMemberStatus(groupID, int64(userID), models.GroupMemberStatusInvited)
+			sess.SetFlashMsg(userName + " is invited.")
+		}
+	} else if action == "Approve" && status == models.GroupMemberStatusRequested {
+		models.SetGroupMemberStatus(groupID, int64(userID), models.GroupMemberStatusMember)
+	} else if action == "Remove" {
+		models.DeleteGroupMember(groupID, int64(userID))
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+})
+
+var GroupBansHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.PostFormValue("gid")
+	var tmp string
+	if r.Method != "POST" || db.QueryRow(`SELECT id FROM groups WHERE id=?;`, groupID).Scan(&tmp) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if !can(sess, models.CapBanUsers, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/groups/edit?id=" + groupID
+
+	action := r.PostFormValue("action")
+	if action == "Ban" || action == "Mute" {
+		userName := strings.TrimSpace(r.PostFormValue("username"))
+		reason := strings.TrimSpace(r.PostFormValue("reason"))
+		days, err := strconv.Atoi(r.PostFormValue("days"))
+		if err != nil || days < 0 {
+			sess.SetFlashMsg("Duration should be a number of days.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if action == "Mute" && days == 0 {
+			sess.SetFlashMsg("Mutes should last at least a day.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if len(reason) > 250 {
+			sess.SetFlashMsg("Reason should have fewer than 250 characters.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		targetID, err := models.ReadUserIDByName(userName)
+		if err != nil {
+			sess.SetFlashMsg("Username not found: " + userName)
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if models.Can(sql.NullInt64{Int64: int64(targetID), Valid: true}, models.CapBanUsers, groupID) {
+			sess.SetFlashMsg("Moderators of this group cannot be banned or muted in it.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		var expiry time.Time
+		if days > 0 {
+			expiry = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+		}
+		if action == "Mute" {
+			models.CreateGroupBan(groupID, int64(targetID), sess.UserID.Int64, models.GroupBanKindMute, reason, expiry)
+			logAction(sess, models.AuditGroupMute, "user", strconv.Itoa(targetID), groupID, "", banDesc(reason, days))
+			sess.SetFlashMsg(userName + " is muted in this group.")
+		} else {
+			models.CreateGroupBan(groupID, int64(targetID), sess.UserID.Int64, models.GroupBanKindBan, reason, expiry)
+			logAction(sess, models.AuditGroupBan, "user", strconv.Itoa(targetID), groupID, "", banDesc(reason, days))
+			sess.SetFlashMsg(userName + " is banned from this group.")
+		}
+	} else if action == "Lift" {
+		models.LiftGroupBan(groupID, r.PostFormValue("id"))
+		logAction(sess, models.AuditGroupLift, "groupban", r.PostFormValue("id"), groupID, "", "")
+		sess.SetFlashMsg("Ban lifted.")
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+})
+
+// canManageGroup reports whether the user may open the group's edit page.
+func canManageGroup(sess Session, groupID string) bool {
+	return can(sess, models.CapEditGroup, groupID) || can(sess, models.CapBanUsers, groupID) || can(sess, models.CapManageMembers, groupID)
+}
+
 var GroupSubscribeHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	groupID := r.FormValue("id")
 	if models.Config(models.AllowGroupSubscription) == "0" {
@@ -263,7 +617,7 @@ var GroupSubscribeHandler = A(func(w http.ResponseWriter, r *http.Request, sess
 		return
 	}
 	var groupName string
-	if db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName) != nil {
+	if db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName) != nil || !models.CanUserViewGroup(groupID, sess.UserID) {
 		ErrNotFoundHandler(w, r)
 		return
 	}
@@ -305,3 +659,46 @@ var GroupUnsubscribeHandler = UA(func(w http.ResponseWriter, r *http.Request, se
 	<input type="submit" value="Unsubscribe">
 	</form></body></html>`))
 })
+
+// Slow mode intervals longer than a day are refused.
+var maxSlowModeSecs int64 = 24 * 3600
+
+// GroupSlowModeHandler sets the minimum interval between the comments of a user in the group. Mods
+// turn it on during heated threads. An interval of 0 turns it off.
+var GroupSlowModeHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	var groupName string
+	var oldSecs int64
+	if db.QueryRow(`SELECT name, slow_mode_secs FROM groups WHERE id=?;`, groupID).Scan(&groupName, &oldSecs) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if r.Method != "POST" || !can(sess, models.CapClose, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	secs, err := strconv.ParseInt(r.PostFormValue("slow_mode_secs"), 10, 64)
+	if err != nil || secs < 0 || secs > maxSlowModeSecs {
+		sess.SetFlashMsg("Slow mode interval should be between 0 seconds and a day.")
+		http.Redirect(w, r, "/groups?name="+groupName, http.StatusSeeOther)
+		return
+	}
+	db.Exec(`UPDATE groups SET slow_mode_secs=? WHERE id=?;`, secs, groupID)
+	if secs != oldSecs {
+		logAction(sess, models.AuditGroupSlowMode, "group", groupID, groupID, slowModeDesc(oldSecs), slowModeDesc(secs))
+	}
+	sess.SetFlashMsg("Slow mode: " + slowModeDesc(secs) + ".")
+	http.Redirect(w, r, "/groups?name="+groupName, http.StatusSeeOther)
+})
+
+func slowModeDesc(secs int64) string {
+	if secs <= 0 {
+		return "off"
+	}
+	return "one comment every " + slowModeStr(secs)
+}
+
+func groupSettingsDesc(desc string, headerMsg string, isSticky bool, isPrivate bool, isThreaded bool, policy string, premodLevel int) string {
+	return "description: " + desc + "\nannouncement: " + headerMsg + "\nsticky: " + boolStr(isSticky) + "\nprivate: " + boolStr(isPrivate) + "\nthreaded: " + boolStr(isThreaded) + "\npost policy: " + policy +
+		"\npre-moderation: " + strconv.Itoa(premodLevel)
+}
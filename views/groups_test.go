@@ -0,0 +1,287 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroupBansHandler(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "bangroup", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("bangroup")
+	models.CreateUser("groupmod", "groupmod123", "")
+	models.CreateUser("mutee", "mutee12345", "")
+	models.CreateGroupMod("groupmod", groupID)
+	muteeID, _ := models.ReadUserIDByName("mutee")
+
+	modSess, err := loginForTest("groupmod", "groupmod123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	muteeSess, err := loginForTest("mutee", "mutee12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+
+	rr := postForTest(GroupBansHandler, "/groups/bans", muteeSess, url.Values{"gid": {groupID}, "action": {"Ban"}, "username": {"groupmod"}, "days": {"0"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Non-moderator was able to issue a group ban: got %v\n", rr.Code)
+	}
+
+	rr = postForTest(GroupBansHandler, "/groups/bans", modSess, url.Values{"gid": {groupID}, "action": {"Mute"}, "username": {"mutee"}, "days": {"0"}})
+	if _, err := models.ReadActiveGroupBan(groupID, int64(muteeID)); err == nil {
+		t.Errorf("Mute without a duration was accepted.\n")
+	}
+	rr = postForTest(GroupBansHandler, "/groups/bans", modSess, url.Values{"gid": {groupID}, "action": {"Mute"}, "username": {"mutee"}, "days": {"1"}, "reason": {"Flaming"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Mute returned wrong status code: got %v\n", rr.Code)
+	}
+	ban, err := models.ReadActiveGroupBan(groupID, int64(muteeID))
+	if err != nil || !ban.IsMute() || ban.IsPermanent() {
+		t.Fatalf("Active group mute not found.\n")
+	}
+
+	newTopic := url.Values{"title": {"A topic from a muted user"}, "content": {"Hello"}}
+	rr = postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, muteeSess, newTopic)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Muted user was able to create a topic: got %v\n", rr.Code)
+	}
+
+	postForTest(GroupBansHandler, "/groups/bans", modSess, url.Values{"gid": {groupID}, "action": {"Lift"}, "id": {ban.ID}})
+	if _, err := models.ReadActiveGroupBan(groupID, int64(muteeID)); err == nil {
+		t.Errorf("Group mute still active after being lifted.\n")
+	}
+	rr = postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, muteeSess, newTopic)
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("User unable to create a topic after mute was lifted: got %v\n", rr.Code)
+	}
+}
+
+func getForTest(handler http.HandlerFunc, target string, sessionid string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", target, nil)
+	if sessionid != "" {
+		req.AddCookie(&http.Cookie{Name: "sessionid", Path: "/", Value: sessionid, HttpOnly: true})
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPrivateGroup(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, is_private, created_date, updated_date) VALUES(?, ?, ?, ?, ?);`, "privgroup", "", true, time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("privgroup")
+	models.CreateUser("privadmin", "privadmin123", "")
+	models.CreateUser("outsider", "outsider123", "")
+	models.CreateGroupAdmin("privadmin", groupID)
+	adminID, _ := models.ReadUserIDByName("privadmin")
+	outsiderID, _ := models.ReadUserIDByName("outsider")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A secret members-only topic", "", adminID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	adminSess, err := loginForTest("privadmin", "privadmin123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	outsiderSess, err := loginForTest("outsider", "outsider123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+
+	if rr := getForTest(TopicIndexHandler, "/topics?id="+topicID, outsiderSess); rr.Code != http.StatusNotFound {
+		t.Errorf("Private topic visible to a non-member: got %v\n", rr.Code)
+	}
+	if rr := getForTest(IndexHandler, "/", ""); strings.Contains(rr.Body.String(), "A secret members-only topic") {
+		t.Errorf("Private topic listed on the index page for an anonymous user.\n")
+	}
+	if rr := getForTest(UserTopicsHandler, "/users/topics?u=privadmin", outsiderSess); strings.Contains(rr.Body.String(), "A secret members-only topic") {
+		t.Errorf("Private topic listed on the profile page for a non-member.\n")
+	}
+
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		"the secret handshake", topicID, adminID, 1, time.Now().Unix(), time.Now().Unix())
+	var secretCommentID string
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&secretCommentID)
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "pubgroup", "", time.Now().Unix(), time.Now().Unix())
+	publicGroupID := models.ReadGroupIDByName("pubgroup")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A public topic", "", adminID, publicGroupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var publicTopicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, publicGroupID).Scan(&publicTopicID)
+	if body := getForTest(CommentCreateHandler, "/comments/new?tid="+publicTopicID+"&quote="+secretCommentID, outsiderSess).Body.String(); strings.Contains(body, "the secret handshake") {
+		t.Errorf("Comment in a private group quoted into a public topic.\n")
+	}
+	postForTest(GroupMembersHandler, "/groups/members", outsiderSess, url.Values{"gid": {publicGroupID}, "action": {"Request to join"}})
+	if status := models.ReadGroupMemberStatus(publicGroupID, int64(outsiderID)); status != "" {
+		t.Errorf("Join request to a public group recorded: got %q\n", status)
+	}
+
+	postForTest(GroupMembersHandler, "/groups/members", outsiderSess, url.Values{"gid": {groupID}, "action": {"Request to join"}})
+	if status := models.ReadGroupMemberStatus(groupID, int64(outsiderID)); status != models.GroupMemberStatusRequested {
+		t.Fatalf("Join request not recorded: got %q\n", status)
+	}
+	if rr := postForTest(GroupMembersHandler, "/groups/members", outsiderSess, url.Values{"gid": {groupID}, "action": {"Approve"}, "username": {"outsider"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-admin was able to approve a join request: got %v\n", rr.Code)
+	}
+	postForTest(GroupMembersHandler, "/groups/members", adminSess, url.Values{"gid": {groupID}, "action": {"Approve"}, "username": {"outsider"}})
+	if rr := getForTest(TopicIndexHandler, "/topics?id="+topicID, outsiderSess); rr.Code != http.StatusOK {
+		t.Errorf("Private topic not visible to a member: got %v\n", rr.Code)
+	}
+}
+
+func TestGroupPostPolicy(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, post_policy, created_date, updated_date) VALUES(?, ?, ?, ?, ?);`, "newsgroup", "", models.GroupPolicyAnnouncement, time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("newsgroup")
+	models.CreateUser("newsreader", "newsreader123", "")
+	adminID, _ := models.ReadUserIDByName("admin")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Release announcement", "", adminID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	sessionid, err := loginForTest("newsreader", "newsreader123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	rr := postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, sessionid, url.Values{"title": {"Not an announcement"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("User started a topic in an announcement group: got %v\n", rr.Code)
+	}
+	rr = postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, sessionid, url.Values{"content": {"Congrats!"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("User unable to reply in an announcement group: got %v\n", rr.Code)
+	}
+
+	db.Exec(`UPDATE groups SET post_policy=? WHERE id=?;`, models.GroupPolicyReadOnly, groupID)
+	rr = postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, sessionid, url.Values{"content": {"Another reply"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("User replied in a read-only group: got %v\n", rr.Code)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, sessionid).Body.String(); strings.Contains(body, `action="/comments/new"`) {
+		t.Errorf("Compose form shown in a read-only group.\n")
+	}
+
+	var commentID string
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=? AND content=?;`, topicID, "Congrats!").Scan(&commentID)
+	rr = postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, sessionid, url.Values{"action": {"Update"}, "content": {"Edited"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("User edited a comment in a read-only group: got %v\n", rr.Code)
+	}
+	adminSess, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, adminSess, url.Values{"action": {"Delete"}})
+	var isDeleted, isClosed bool
+	db.QueryRow(`SELECT is_deleted FROM comments WHERE id=?;`, commentID).Scan(&isDeleted)
+	if !isDeleted {
+		t.Errorf("Moderator unable to delete a comment in a read-only group.\n")
+	}
+	postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, adminSess, url.Values{"action": {"Close"}, "title": {"Release announcement"}})
+	db.QueryRow(`SELECT is_closed FROM topics WHERE id=?;`, topicID).Scan(&isClosed)
+	if !isClosed {
+		t.Errorf("Moderator unable to close a topic in a read-only group.\n")
+	}
+}
+
+func TestGroupRenameArchiveMerge(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "renamefrom", "", time.Now().Unix(), time.Now().Unix())
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "mergeinto", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("renamefrom")
+	targetID := models.ReadGroupIDByName("mergeinto")
+	adminID, _ := models.ReadUserIDByName("admin")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A topic in a renamed group", "", adminID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+
+	sessionid, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	postForTest(GroupEditHandler, "/groups/edit", sessionid, url.Values{"id": {groupID}, "action": {"Update"}, "name": {"renamedto"}, "post_policy": {models.GroupPolicyOpen}})
+	rr := getForTest(GroupIndexHandler, "/groups?name=renamefrom", "")
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/groups?name=renamedto" {
+		t.Errorf("Old group name does not redirect: got %v %v\n", rr.Code, rr.Header().Get("Location"))
+	}
+
+	postForTest(GroupEditHandler, "/groups/edit", sessionid, url.Values{"id": {groupID}, "action": {"Archive"}})
+	rr = postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, sessionid, url.Values{"title": {"A topic in an archived group"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Topic created in an archived group: got %v\n", rr.Code)
+	}
+	if rr = getForTest(GroupIndexHandler, "/groups?name=renamedto", ""); rr.Code != http.StatusOK {
+		t.Errorf("Archived group not visible: got %v\n", rr.Code)
+	}
+
+	db.Exec(`INSERT INTO reports(reporterid, targettype, targetid, groupid, category, status, created_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		adminID, models.ReportTargetTopic, 1, groupID, "spam", models.ReportStatusOpen, time.Now().Unix())
+	postForTest(GroupEditHandler, "/groups/edit", sessionid, url.Values{"id": {groupID}, "action": {"Merge"}, "into": {"mergeinto"}})
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM topics WHERE groupid=?;`, targetID).Scan(&count)
+	if count != 1 || models.ReadGroupIDByName("renamedto") != "" {
+		t.Errorf("Group not merged.\n")
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM reports WHERE groupid=?;`, targetID).Scan(&count)
+	if count != 1 {
+		t.Errorf("Reports lost in a group merge: got %v\n", count)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM auditlog WHERE groupid=? AND action=?;`, targetID, models.AuditGroupRename).Scan(&count)
+	if count != 1 {
+		t.Errorf("Audit log entries lost in a group merge: got %v\n", count)
+	}
+	rr = getForTest(GroupIndexHandler, "/groups?name=renamefrom", "")
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/groups?name=mergeinto" {
+		t.Errorf("Merged group name does not redirect: got %v %v\n", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestSlowModeAndRateLimits(t *testing.T) {
+	groupID := createGroupForTest("slowgroup")
+	_, modSess := createUserForTest(t, "slowmod")
+	posterID, posterSess := createUserForTest(t, "slowposter")
+	models.CreateGroupMod("slowmod", groupID)
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, posterID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A heated thread", "", posterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	numComments := func() int {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM comments WHERE topicid=? AND userid=?;`, topicID, posterID).Scan(&n)
+		return n
+	}
+
+	if rr := postForTest(GroupSlowModeHandler, "/groups/slowmode?gid="+groupID, posterSess, url.Values{"slow_mode_secs": {"300"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("User able to turn on slow mode: got %v\n", rr.Code)
+	}
+	postForTest(GroupSlowModeHandler, "/groups/slowmode?gid="+groupID, modSess, url.Values{"slow_mode_secs": {"300"}})
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, posterSess, url.Values{"content": {"first reply"}})
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, posterSess, url.Values{"content": {"second reply"}})
+	if n := numComments(); n != 1 {
+		t.Errorf("Slow mode not enforced: got %v comments\n", n)
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, modSess, url.Values{"content": {"mod reply one"}})
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, modSess, url.Values{"content": {"mod reply two"}})
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, posterSess).Body.String(); !strings.Contains(body, "mod reply two") {
+		t.Errorf("Slow mode applied to mods.\n")
+	}
+
+	postForTest(GroupSlowModeHandler, "/groups/slowmode?gid="+groupID, modSess, url.Values{"slow_mode_secs": {"0"}})
+	oldLimit := models.Config(models.CommentsPerHour)
+	models.WriteConfig(models.CommentsPerHour, "1")
+	defer models.WriteConfig(models.CommentsPerHour, oldLimit)
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, posterSess, url.Values{"content": {"third reply"}})
+	if n := numComments(); n != 1 {
+		t.Errorf("Comment rate limit not enforced: got %v comments\n", n)
+	}
+}
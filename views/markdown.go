@@ -0,0 +1,183 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// markdown renders posts as CommonMark with the GFM tables, strikethrough and autolinks, and links
+// @username to the profile of the user. Line breaks are kept as they were with the old formatter.
+// Raw HTML in posts is shown as text.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithParserOptions(parser.WithInlineParsers(util.Prioritized(mentionParser{}, 500))),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		renderer.WithNodeRenderers(util.Prioritized(escapedHTML{}, 100)),
+	),
+)
+
+// sanitizer is the allowlist the rendered HTML is checked against. Links get rel="nofollow" and
+// only http, https and mailto URLs are allowed.
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	return p
+}
+
+// escapedHTML renders HTML tags typed in a post as text, the way the old formatter showed them.
+type escapedHTML struct{}
+
+func (r escapedHTML) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+}
+
+func (r escapedHTML) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.HTMLBlock)
+	var lines []string
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		lines = append(lines, strings.TrimRight(string(seg.Value(source)), "\n"))
+	}
+	if n.HasClosure() {
+		lines = append(lines, strings.TrimRight(string(n.ClosureLine.Value(source)), "\n"))
+	}
+	for i, line := range lines {
+		lines[i] = template.HTMLEscapeString(line)
+	}
+	w.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	return ast.WalkContinue, nil
+}
+
+func (r escapedHTML) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*ast.RawHTML)
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		w.WriteString(template.HTMLEscapeString(string(seg.Value(source))))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+var mentionNameRe = regexp.MustCompile(`^@(\w+)`)
+
+// mentionParser turns @username into a link to the profile. An @ inside a word, such as in an
+// email address, is left alone.
+type mentionParser struct{}
+
+func (p mentionParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+func (p mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if prev := block.PrecendingCharacter(); unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' || prev == '@' || prev == '/' {
+		return nil
+	}
+	line, seg := block.PeekLine()
+	m := mentionNameRe.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	block.Advance(len(m[0]))
+	link := ast.NewLink()
+	link.Destination = []byte("/users?u=" + string(m[1]))
+	link.AppendChild(link, ast.NewTextSegment(text.NewSegment(seg.Start, seg.Start+len(m[0]))))
+	return link
+}
+
+var listItemRe = regexp.MustCompile(`^\s*([-+*]|[0-9]+[.)])\s`)
+
+// legacyCode keeps posts written for the old formatter rendering as they did. A line indented by
+// four spaces used to be a code block even right after a line of text, where CommonMark reads it
+// as the rest of the paragraph. Posts with fenced code blocks never had that behavior.
+func legacyCode(content string) string {
+	if strings.Contains(content, "```") {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i > 0 && strings.HasPrefix(line, "    ") {
+			prev := lines[i-1]
+			if strings.TrimSpace(prev) != "" && !strings.HasPrefix(prev, "    ") && !listItemRe.MatchString(prev) {
+				out = append(out, "")
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func formatComment(comment string) template.HTML {
+	comment = strings.Replace(comment, "\r", "", -1)
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(legacyCode(comment)), &buf); err != nil {
+		return template.HTML("<p>" + template.HTMLEscapeString(comment) + "</p>")
+	}
+	return template.HTML(sanitizer.SanitizeBytes(buf.Bytes()))
+}
+
+type renderedPost struct {
+	content string
+	html    template.HTML
+}
+
+// renderCache holds the HTML of recently viewed topics and comments, keyed by the post and the
+// date it was last edited, so that each revision of a post is rendered once.
+var renderCache = struct {
+	sync.Mutex
+	posts map[string]renderedPost
+}{posts: map[string]renderedPost{}}
+
+var maxRenderCachePosts = 5000
+
+// formatPost is formatComment for a topic or comment, cached per revision. The cached HTML is
+// only used if the text is the same, as censoring can change what is shown without an edit.
+func formatPost(targetType string, targetID string, editedDate int64, content string) template.HTML {
+	key := targetType + ":" + targetID + ":" + strconv.FormatInt(editedDate, 10)
+	renderCache.Lock()
+	post, ok := renderCache.posts[key]
+	renderCache.Unlock()
+	if ok && post.content == content {
+		return post.html
+	}
+
+	formatted := formatComment(content)
+	renderCache.Lock()
+	if len(renderCache.posts) >= maxRenderCachePosts {
+		renderCache.posts = map[string]renderedPost{}
+	}
+	renderCache.posts[key] = renderedPost{content: content, html: formatted}
+	renderCache.Unlock()
+	return formatted
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatComment(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+		deny []string
+	}{
+		{"**bold** and *em*", []string{"<strong>bold</strong>", "<em>em</em>"}, nil},
+		{"- one\n- two", []string{"<ul>", "<li>one</li>"}, nil},
+		{"| a | b |\n|:--|--:|\n| 1 | 2 |", []string{"<table>", `<td align="right">2</td>`}, nil},
+		{"~~gone~~", []string{"<del>gone</del>"}, nil},
+		{"`see http://example.com`", []string{"<code>see http://example.com</code>"}, []string{"<a"}},
+		{"<script>alert(1)</script>hi", []string{"&lt;script&gt;", "hi"}, []string{"<script"}},
+		{"a <b>tag</b> here", []string{"&lt;b&gt;tag&lt;/b&gt;"}, []string{"<b>"}},
+		{"[x](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"Look at this:\n    x := 1\nok", []string{"<pre><code>x := 1"}, nil},
+		{"go to http://example.com now", []string{`href="http://example.com"`, `rel="nofollow"`}, nil},
+		{"line one\nline two", []string{"<br>"}, nil},
+		{"hi @alice, mail bob@example.com", []string{`<a href="/users?u=alice" rel="nofollow">@alice</a>`}, []string{"/users?u=example"}},
+	}
+	for _, c := range cases {
+		got := string(formatComment(c.in))
+		for _, s := range c.want {
+			if !strings.Contains(got, s) {
+				t.Errorf("formatComment(%q) = %q, want %q in it\n", c.in, got, s)
+			}
+		}
+		for _, s := range c.deny {
+			if strings.Contains(got, s) {
+				t.Errorf("formatComment(%q) = %q, want no %q in it\n", c.in, got, s)
+			}
+		}
+	}
+
+	formatPost("comment", "md1", 0, "first")
+	if got := string(formatPost("comment", "md1", 0, "second")); !strings.Contains(got, "second") {
+		t.Errorf("Cached HTML shown for changed text: %q\n", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"github.com/s-gv/orangeforum/utils"
+	"net/http"
+	"strconv"
+)
+
+var mentionsPerPage = 50
+
+// mentionLimitMsg returns a message if the content mentions more users than a post may, or if
+// the mentions would take the user over the hourly limit. Superadmins are not limited.
+func mentionLimitMsg(sess Session, content string) string {
+	if sess.IsUserSuperAdmin() {
+		return ""
+	}
+	numMentions := len(models.ParseMentions(content))
+	if numMentions == 0 {
+		return ""
+	}
+	if limit := models.ConfigInt(models.MentionsPerPost); limit > 0 && numMentions > limit {
+		return "A post can mention at most " + strconv.Itoa(limit) + " users."
+	}
+	if limit := models.ConfigInt(models.MentionsPerHour); limit > 0 && models.CountRecentMentions(sess.UserID.Int64)+numMentions > limit {
+		return "You can mention at most " + strconv.Itoa(limit) + " users an hour. Please try again later."
+	}
+	return ""
+}
+
+// notifyMentions records the users mentioned in a topic, comment, or message (targetType) and
+// emails the ones mentioned in it for the first time, unless they turned that off. Mentions in
+// posts awaiting approval or by shadow-banned users are ignored until the post is visible.
+func notifyMentions(r *http.Request, authorID int64, targetType string, targetID string) {
+	if models.IsShadowBanned(authorID) {
+		return
+	}
+	var content, title, where, postURL string
+	var isPending, isShadowed, isTopicShadowed bool
+	if targetType == "topic" {
+		db.QueryRow(`SELECT content, title, is_pending, is_shadowed FROM topics WHERE id=?;`, targetID).Scan(&content, &title, &isPending, &isShadowed)
+		where, postURL = `"`+title+`"`, "/topics?id="+targetID
+	} else if targetType == "comment" {
+		db.QueryRow(`SELECT comments.content, topics.title, comments.is_pending, comments.is_shadowed, topics.is_shadowed FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.id=?;`, targetID).Scan(
+			&content, &title, &isPending, &isShadowed, &isTopicShadowed)
+		where, postURL = `"`+title+`"`, "/comments?id="+targetID
+	} else {
+		db.QueryRow(`SELECT content FROM messages WHERE id=?;`, targetID).Scan(&content)
+		where, postURL = "a private message", "/pm"
+	}
+	if isPending || isShadowed || isTopicShadowed {
+		return
+	}
+	userIDs := models.CreateMentions(authorID, targetType, targetID, models.ParseMentions(content))
+	if len(userIDs) == 0 {
+		return
+	}
+	var authorName string
+	db.QueryRow(`SELECT username FROM users WHERE id=?;`, authorID).Scan(&authorName)
+	for _, userID := range userIDs {
+		var userName, email string
+		var isAllowed bool
+		db.QueryRow(`SELECT username, email, mention_emails FROM users WHERE id=?;`, userID).Scan(&userName, &email, &isAllowed)
+		if email != "" && isAllowed {
+			utils.SendMail(email, authorName+` mentioned you in `+where,
+				authorName+" mentioned you in "+where+".\r\nSee the post at http://"+r.Host+postURL+"\r\n\r\nIf you do not want these emails, turn them off in your profile: http://"+r.Host+"/users?u="+userName)
+		}
+	}
+}
+
+// UserMentionsHandler lists the posts in which the user was mentioned and marks them as read.
+var UserMentionsHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	lastDate, err := strconv.ParseInt(r.FormValue("lcd"), 10, 64)
+	if err != nil {
+		lastDate = 0
+	}
+	lastID := r.FormValue("lid")
+	if _, err := strconv.ParseInt(lastID, 10, 64); err != nil {
+		lastDate = 0
+	}
+
+	type Mention struct {
+		AuthorName  string
+		URL         string
+		Where       string
+		IsRead      bool
+		CreatedDate string
+	}
+	var mentions []Mention
+	var nextDate int64
+	var nextID string
+	rows := models.ReadMentions(sess.UserID.Int64, lastDate, lastID, mentionsPerPage+1)
+	for i, m := range rows {
+		if i == mentionsPerPage {
+			nextDate, nextID = rows[i-1].CreatedDate.Unix(), rows[i-1].ID
+			break
+		}
+		mention := Mention{
+			AuthorName:  m.AuthorName,
+			URL:         "/pm",
+			Where:       "a private message",
+			IsRead:      m.IsRead,
+			CreatedDate: timeAgoFromNow(m.CreatedDate),
+		}
+		if m.TargetType == "topic" {
+			mention.URL, mention.Where = "/topics?id="+m.TopicID, censor(m.GroupID, m.TopicTitle)
+		} else if m.TargetType == "comment" {
+			mention.URL, mention.Where = "/comments?id="+m.TargetID, censor(m.GroupID, m.TopicTitle)
+		}
+		mentions = append(mentions, mention)
+	}
+
+	if !sess.IsImpersonating() {
+		models.MarkMentionsRead(sess.UserID.Int64)
+	}
+
+	templates.Render(w, "profilementions.html", map[string]interface{}{
+		"Common":          readCommonData(r, sess),
+		"Mentions":        mentions,
+		"LastCreatedDate": nextDate,
+		"LastID":          nextID,
+	})
+})
@@ -0,0 +1,111 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMentions(t *testing.T) {
+	groupID := createGroupForTest("mentiongroup")
+	_, mentionerSess := createUserForTest(t, "mentioner")
+	mentioneeID, mentioneeSess := createUserForTest(t, "mentionee")
+	shadowID, shadowSess := createUserForTest(t, "mentionshadow")
+	models.ShadowBanUser(int64(shadowID))
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A topic with mentions", "", mentioneeID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	numMentions := func() int {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM mentions WHERE userid=?;`, mentioneeID).Scan(&n)
+		return n
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, shadowSess, url.Values{"content": {"hey @mentionee"}})
+	if n := numMentions(); n != 0 {
+		t.Errorf("Mention by a shadow-banned user recorded.\n")
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, mentionerSess, url.Values{"content": {"hey @mentionee, mail me at me@mentionee.com `@mentionee`"}})
+	if n := numMentions(); n != 1 {
+		t.Fatalf("Expected 1 mention, got %v\n", n)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, mentioneeSess).Body.String(); !strings.Contains(body, `rel="nofollow">@mentionee</a>`) {
+		t.Errorf("Mention not linked to the profile.\n")
+	}
+
+	if !models.HasUnreadMentions(int64(mentioneeID)) {
+		t.Errorf("Mention not marked unread.\n")
+	}
+	if body := getForTest(UserMentionsHandler, "/users/mentions", mentioneeSess).Body.String(); !strings.Contains(body, "A topic with mentions") {
+		t.Errorf("Mention not listed.\n")
+	}
+	if models.HasUnreadMentions(int64(mentioneeID)) {
+		t.Errorf("Listed mentions not marked read.\n")
+	}
+
+	oldLimit := models.Config(models.MentionsPerPost)
+	models.WriteConfig(models.MentionsPerPost, "1")
+	defer models.WriteConfig(models.MentionsPerPost, oldLimit)
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, mentionerSess, url.Values{"content": {"@mentionee and @mentionshadow"}})
+	var numComments int
+	db.QueryRow(`SELECT COUNT(*) FROM comments WHERE topicid=? AND content=?;`, topicID, "@mentionee and @mentionshadow").Scan(&numComments)
+	if numComments != 0 {
+		t.Errorf("Post over the mention limit accepted.\n")
+	}
+	models.WriteConfig(models.MentionsPerPost, oldLimit)
+
+	postForTest(UserProfileUpdateHandler, "/users/update?u=mentioner", mentioneeSess, url.Values{"action": {"Block"}})
+	if body := getForTest(UserProfileHandler, "/users?u=mentioner", mentioneeSess).Body.String(); !strings.Contains(body, `value="Unblock"`) {
+		t.Errorf("Blocked user not shown as blocked.\n")
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, mentionerSess, url.Values{"content": {"are you there @mentionee"}})
+	if n := numMentions(); n != 1 {
+		t.Errorf("Mention by a blocked user recorded.\n")
+	}
+	postForTest(UserProfileUpdateHandler, "/users/update?u=mentioner", mentioneeSess, url.Values{"action": {"Unblock"}})
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, mentionerSess, url.Values{"content": {"still there @mentionee"}})
+	if n := numMentions(); n != 2 {
+		t.Errorf("Mention after unblocking not recorded: got %v\n", n)
+	}
+
+	oldPerPage := mentionsPerPage
+	mentionsPerPage = 2
+	defer func() { mentionsPerPage = oldPerPage }()
+	privateID := createGroupForTest("mentionprivate")
+	db.Exec(`UPDATE groups SET is_private=1 WHERE id=?;`, privateID)
+	mentionerID, _ := models.ReadUserIDByName("mentioner")
+	for i, gid := range []string{groupID, groupID, groupID, privateID, privateID, privateID} {
+		title := "Paged topic " + strconv.Itoa(i)
+		db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+			title, "", mentionerID, gid, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+		var id string
+		db.QueryRow(`SELECT id FROM topics WHERE title=?;`, title).Scan(&id)
+		db.Exec(`INSERT INTO mentions(userid, authorid, targettype, targetid, created_date) VALUES(?, ?, ?, ?, ?);`,
+			mentioneeID, mentionerID, "topic", id, time.Now().Add(time.Hour).Unix())
+	}
+	var body string
+	nextRe := regexp.MustCompile(`/users/mentions\?lcd=\d+&lid=\d+`)
+	for page, link := 0, "/users/mentions"; link != "" && page < 10; page++ {
+		b := getForTest(UserMentionsHandler, link, mentioneeSess).Body.String()
+		body, link = body+b, nextRe.FindString(b)
+	}
+	for i := 0; i < 6; i++ {
+		want := 0
+		if i < 3 {
+			want = 1
+		}
+		if n := strings.Count(body, "Paged topic "+strconv.Itoa(i)+"<"); n != want {
+			t.Errorf("Paged topic %v listed %v times, want %v\n", i, n, want)
+		}
+	}
+}
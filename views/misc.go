@@ -13,6 +13,7 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -28,13 +29,15 @@ var IndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session)
 		Desc     string
 		IsSticky int
 	}
+	visibleCond, visibleArgs := models.VisibleGroupsCond(sess.UserID)
 	groups := []Group{}
-	rows := db.Query(`SELECT name, description, is_sticky FROM groups WHERE is_closed=0 ORDER BY is_sticky DESC, RANDOM() LIMIT 25;`)
+	rows := db.Query(`SELECT id, name, description, is_sticky FROM groups WHERE is_closed=0 AND `+visibleCond+` ORDER BY is_sticky DESC, RANDOM() LIMIT 25;`, visibleArgs...)
 	for rows.Next() {
 		groups = append(groups, Group{})
 		g := &groups[len(groups)-1]
-		rows.Scan(&g.Name, &g.Desc, &g.IsSticky)
-		g.Desc = censor(g.Desc)
+		var groupID string
+		rows.Scan(&groupID, &g.Name, &g.Desc, &g.IsSticky)
+		g.Desc = censor(groupID, g.Desc)
 	}
 	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
 	sort.Slice(groups, func(i, j int) bool { return groups[i].IsSticky > groups[j].IsSticky })
@@ -48,14 +51,15 @@ var IndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session)
 		NumComments int
 	}
 	topics := []Topic{}
-	trows := db.Query(`SELECT topics.id, topics.title, topics.num_comments, topics.created_date, topics.is_deleted, topics.is_closed, groups.name, groups.is_closed, users.username FROM topics INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON topics.userid=users.id ORDER BY topics.created_date DESC LIMIT 20;`)
+	trows := db.Query(`SELECT topics.id, topics.groupid, topics.title, topics.num_comments, topics.created_date, topics.is_deleted, topics.is_closed, groups.name, groups.is_closed, users.username FROM topics INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON topics.userid=users.id WHERE topics.is_pending=0 AND (topics.is_shadowed=0 OR topics.userid=?) AND `+visibleCond+` ORDER BY topics.created_date DESC LIMIT 20;`, append([]interface{}{sess.UserID}, visibleArgs...)...)
 	for trows.Next() {
 		t := Topic{}
 		var cDate int64
+		var groupID string
 		var isTopicDeleted, isTopicClosed, isGroupClosed bool
-		trows.Scan(&t.ID, &t.Title, &t.NumComments, &cDate, &isTopicDeleted, &isTopicClosed, &t.GroupName, &isGroupClosed, &t.OwnerName)
+		trows.Scan(&t.ID, &groupID, &t.Title, &t.NumComments, &cDate, &isTopicDeleted, &isTopicClosed, &t.GroupName, &isGroupClosed, &t.OwnerName)
 		t.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
-		t.Title = censor(t.Title)
+		t.Title = censor(groupID, t.Title)
 		if !isTopicDeleted && !isTopicClosed && !isGroupClosed {
 			topics = append(topics, t)
 		}
@@ -80,7 +84,6 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 	if r.Method == "POST" && linkID == "" {
 		forumName := strings.TrimSpace(r.PostFormValue("forum_name"))
 		headerMsg := strings.TrimSpace(r.PostFormValue("header_msg"))
-		censoredWords := r.PostFormValue("censored_words")
 		loginMsg := strings.TrimSpace(r.PostFormValue("login_msg"))
 		signupMsg := strings.TrimSpace(r.PostFormValue("signup_msg"))
 		signupDisabled := "0"
@@ -96,6 +99,26 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 		smtpPort := r.PostFormValue("smtp_port")
 		smtpUser := r.PostFormValue("smtp_user")
 		smtpPass := r.PostFormValue("smtp_pass")
+		accountDeletionPolicy := r.PostFormValue("account_deletion_policy")
+		accountDeletionGraceDays := strings.TrimSpace(r.PostFormValue("account_deletion_grace_days"))
+		allowUserNameChange := "0"
+		allowImpersonationWrites := "0"
+		userNameChangeInterval := strings.TrimSpace(r.PostFormValue("username_change_interval"))
+		userNameCooldown := strings.TrimSpace(r.PostFormValue("username_cooldown"))
+		trustBasicDays := strings.TrimSpace(r.PostFormValue("trust_basic_days"))
+		trustBasicPosts := strings.TrimSpace(r.PostFormValue("trust_basic_posts"))
+		trustMemberDays := strings.TrimSpace(r.PostFormValue("trust_member_days"))
+		trustMemberPosts := strings.TrimSpace(r.PostFormValue("trust_member_posts"))
+		newUserMaxLinks := strings.TrimSpace(r.PostFormValue("new_user_max_links"))
+		newUserTopicsPerDay := strings.TrimSpace(r.PostFormValue("new_user_topics_per_day"))
+		premodLevel := r.PostFormValue("premod_level")
+		spamThreshold := strings.TrimSpace(r.PostFormValue("spam_threshold"))
+		trashRetentionDays := strings.TrimSpace(r.PostFormValue("trash_retention_days"))
+		topicsPerHour := strings.TrimSpace(r.PostFormValue("topics_per_hour"))
+		commentsPerHour := strings.TrimSpace(r.PostFormValue("comments_per_hour"))
+		messagesPerHour := strings.TrimSpace(r.PostFormValue("messages_per_hour"))
+		mentionsPerPost := strings.TrimSpace(r.PostFormValue("mentions_per_post"))
+		mentionsPerHour := strings.TrimSpace(r.PostFormValue("mentions_per_hour"))
 		if r.PostFormValue("signup_disabled") != "" {
 			signupDisabled = "1"
 		}
@@ -114,16 +137,54 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 		if r.PostFormValue(models.ReadOnlyMode) != "" {
 			readOnlyMode = "1"
 		}
+		if r.PostFormValue("allow_username_change") != "" {
+			allowUserNameChange = "1"
+		}
+		if r.PostFormValue("allow_impersonation_writes") != "" {
+			allowImpersonationWrites = "1"
+		}
 		if dataDir != "" {
 			if dataDir[len(dataDir)-1] != '/' {
 				dataDir = dataDir + "/"
 			}
 		}
 
+		if accountDeletionPolicy != models.AccountDeletionHardDelete {
+			accountDeletionPolicy = models.AccountDeletionAnonymize
+		}
+
 		errMsg := ""
 		if forumName == "" {
 			errMsg = "Forum name is empty."
 		}
+		if n, err := strconv.Atoi(accountDeletionGraceDays); err != nil || n < 0 {
+			errMsg = "Account deletion grace period should be a number of days."
+		}
+		if n, err := strconv.Atoi(userNameChangeInterval); err != nil || n < 0 {
+			errMsg = "Username change interval should be a number of days."
+		}
+		if n, err := strconv.Atoi(userNameCooldown); err != nil || n < 0 {
+			errMsg = "Username cooldown should be a number of days."
+		}
+		for _, n := range []string{trustBasicDays, trustBasicPosts, trustMemberDays, trustMemberPosts, newUserMaxLinks, newUserTopicsPerDay} {
+			if n, err := strconv.Atoi(n); err != nil || n < 0 {
+				errMsg = "Trust level thresholds and limits should be numbers."
+			}
+		}
+		if n, err := strconv.Atoi(premodLevel); err != nil || !models.IsValidPremodLevel(n) {
+			errMsg = "Invalid pre-moderation level."
+		}
+		if n, err := strconv.Atoi(spamThreshold); err != nil || n < 0 || n > 100 {
+			errMsg = "Spam threshold should be a percentage."
+		}
+		if n, err := strconv.Atoi(trashRetentionDays); err != nil || n < 0 {
+			errMsg = "Trash retention period should be a number of days."
+		}
+		for _, n := range []string{topicsPerHour, commentsPerHour, messagesPerHour, mentionsPerPost, mentionsPerHour} {
+			if n, err := strconv.Atoi(n); err != nil || n < 0 {
+				errMsg = "Posting rate limits should be numbers."
+			}
+		}
 
 		if errMsg == "" {
 			models.WriteConfig(models.ForumName, forumName)
@@ -131,7 +192,6 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 			models.WriteConfig(models.LoginMsg, loginMsg)
 			models.WriteConfig(models.SignupMsg, signupMsg)
 			models.WriteConfig(models.SignupDisabled, signupDisabled)
-			models.WriteConfig(models.CensoredWords, censoredWords)
 			models.WriteConfig(models.GroupCreationDisabled, groupCreationDisabled)
 			models.WriteConfig(models.ImageUploadEnabled, imageUploadEnabled)
 			models.WriteConfig(models.AllowGroupSubscription, allowGroupSubscription)
@@ -144,6 +204,26 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 			models.WriteConfig(models.SMTPPort, smtpPort)
 			models.WriteConfig(models.SMTPUser, smtpUser)
 			models.WriteConfig(models.SMTPPass, smtpPass)
+			models.WriteConfig(models.AccountDeletionPolicy, accountDeletionPolicy)
+			models.WriteConfig(models.AccountDeletionGraceDays, accountDeletionGraceDays)
+			models.WriteConfig(models.AllowUserNameChange, allowUserNameChange)
+			models.WriteConfig(models.UserNameChangeInterval, userNameChangeInterval)
+			models.WriteConfig(models.UserNameCooldown, userNameCooldown)
+			models.WriteConfig(models.AllowImpersonationWrites, allowImpersonationWrites)
+			models.WriteConfig(models.TrustBasicDays, trustBasicDays)
+			models.WriteConfig(models.TrustBasicPosts, trustBasicPosts)
+			models.WriteConfig(models.TrustMemberDays, trustMemberDays)
+			models.WriteConfig(models.TrustMemberPosts, trustMemberPosts)
+			models.WriteConfig(models.NewUserMaxLinks, newUserMaxLinks)
+			models.WriteConfig(models.NewUserTopicsPerDay, newUserTopicsPerDay)
+			models.WriteConfig(models.PremodLevel, premodLevel)
+			models.WriteConfig(models.SpamThreshold, spamThreshold)
+			models.WriteConfig(models.TrashRetentionDays, trashRetentionDays)
+			models.WriteConfig(models.TopicsPerHour, topicsPerHour)
+			models.WriteConfig(models.CommentsPerHour, commentsPerHour)
+			models.WriteConfig(models.MessagesPerHour, messagesPerHour)
+			models.WriteConfig(models.MentionsPerPost, mentionsPerPost)
+			models.WriteConfig(models.MentionsPerHour, mentionsPerHour)
 			sess.SetFlashMsg("Update successful.")
 		} else {
 			sess.SetFlashMsg(errMsg)
@@ -182,14 +262,35 @@ var AdminIndexHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 		extraNotes = append(extraNotes, extraNote)
 	}
 
+	type Impersonation struct {
+		AdminName   string
+		UserName    string
+		Action      string
+		CreatedDate string
+	}
+	var impersonations []Impersonation
+	rows = db.Query(`SELECT admins.username, users.username, impersonations.action, impersonations.created_date
+		FROM impersonations INNER JOIN users admins ON admins.id=impersonations.adminid INNER JOIN users ON users.id=impersonations.userid
+		ORDER BY impersonations.created_date DESC LIMIT 50;`)
+	for rows.Next() {
+		var i Impersonation
+		var cDate int64
+		rows.Scan(&i.AdminName, &i.UserName, &i.Action, &cDate)
+		i.CreatedDate = time.Unix(cDate, 0).Format("2006-01-02 15:04")
+		impersonations = append(impersonations, i)
+	}
+
 	templates.Render(w, "adminindex.html", map[string]interface{}{
-		"Common":      readCommonData(r, sess),
-		"Config":      models.ConfigAllVals(),
-		"ExtraNotes":  extraNotes,
-		"NumUsers":    models.NumUsers(),
-		"NumGroups":   models.NumGroups(),
-		"NumTopics":   models.NumTopics(),
-		"NumComments": models.NumComments(),
+		"Common":         readCommonData(r, sess),
+		"Config":         models.ConfigAllVals(),
+		"ExtraNotes":     extraNotes,
+		"Impersonations": impersonations,
+		"NumUsers":       models.NumUsers(),
+		"NumGroups":      models.NumGroups(),
+		"NumTopics":      models.NumTopics(),
+		"NumComments":    models.NumComments(),
+		"NumReports":     models.NumActiveReports(""),
+		"NumPending":     models.NumPendingPosts(""),
 	})
 })
 
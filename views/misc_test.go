@@ -17,7 +17,7 @@ import (
 
 func TestMain(m *testing.M) {
 	// Setup
-	db.Init("sqlite3", ":memory:")
+	db.Init("sqlite3", "file::memory:?cache=shared")
 	models.Migrate()
 
 	models.CreateSuperUser("admin", "admin12345")
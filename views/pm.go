@@ -5,6 +5,7 @@
 package views
 
 import (
+	"github.com/s-gv/orangeforum/models"
 	"github.com/s-gv/orangeforum/models/db"
 	"github.com/s-gv/orangeforum/templates"
 	"html/template"
@@ -17,6 +18,11 @@ import (
 var messagesPerPage = 50
 
 var PrivateMessageHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	if flag := r.FormValue("flag"); flag != "" {
+		// Old flag links now go to the report form.
+		http.Redirect(w, r, "/reports/new?type=comment&id="+flag, http.StatusSeeOther)
+		return
+	}
 	startDate := time.Now().Unix()
 	lmd := r.FormValue("lmd")
 	if lmd != "" {
@@ -46,7 +52,7 @@ var PrivateMessageHandler = A(func(w http.ResponseWriter, r *http.Request, sess
 		msg := Message{}
 		rows.Scan(&msg.ID, &msg.From, &msg.To, &content, &msg.IsRead, &cDate)
 		msg.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
-		msg.Content = formatComment(content)
+		msg.Content = formatComment(censor("", content))
 		if len(msgs) < messagesPerPage {
 			msgs = append(msgs, msg)
 		} else {
@@ -63,29 +69,14 @@ var PrivateMessageHandler = A(func(w http.ResponseWriter, r *http.Request, sess
 		cont = formatReply(to, cont)
 	}
 
-	if flag := r.FormValue("flag"); flag != "" {
-		rows := db.Query(`SELECT users.username FROM mods
-			INNER JOIN users ON users.id=mods.userid
-			INNER JOIN topics ON topics.groupid=mods.groupid
-			INNER JOIN comments ON comments.topicid=topics.id
-			WHERE comments.id=?;`, flag)
-		for rows.Next() {
-			var mod string
-			rows.Scan(&mod)
-			if to != "" {
-				to = to + ", "
-			}
-			to = to + mod
-		}
-		cont = "Flagging " + "http://" + r.Host + "/comments?id=" + flag
-	}
-
 	if lmd != "" && len(msgs) == 0 {
 		http.Redirect(w, r, "/pm", http.StatusSeeOther)
 		return
 	}
 
-	db.Exec(`UPDATE messages SET is_read=? WHERE toid=?;`, true, sess.UserID)
+	if !sess.IsImpersonating() {
+		db.Exec(`UPDATE messages SET is_read=? WHERE toid=?;`, true, sess.UserID)
+	}
 
 	templates.Render(w, "pm.html", map[string]interface{}{
 		"Common":           readCommonData(r, sess),
@@ -102,6 +93,11 @@ var PrivateMessageCreateHandler = A(func(w http.ResponseWriter, r *http.Request,
 		tousers := strings.TrimSpace(r.PostFormValue("to"))
 		content := strings.TrimSpace(r.PostFormValue("content"))
 
+		if trustLevel(sess) == models.TrustLevelNew {
+			sess.SetFlashMsg("New users cannot send private messages yet.")
+			http.Redirect(w, r, "/pm", http.StatusSeeOther)
+			return
+		}
 		if tousers == "" {
 			sess.SetFlashMsg("No users to send the message to.")
 			http.Redirect(w, r, "/pm", http.StatusSeeOther)
@@ -127,8 +123,26 @@ var PrivateMessageCreateHandler = A(func(w http.ResponseWriter, r *http.Request,
 			}
 		}
 
+		if msg := rateLimitMsg(sess, "message", len(touserids)); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/pm#end", http.StatusSeeOther)
+			return
+		}
+		if msg := mentionLimitMsg(sess, content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/pm#end", http.StatusSeeOther)
+			return
+		}
+
+		// Messages from shadow-banned users are dropped without telling the sender.
+		if models.IsShadowBanned(sess.UserID.Int64) {
+			touserids = nil
+		}
 		for _, userid := range touserids {
 			db.Exec(`INSERT INTO messages(fromid, toid, content, created_date) VALUES(?, ?, ?, ?);`, sess.UserID, userid, content, int(time.Now().Unix()))
+			var messageID string
+			db.QueryRow(`SELECT id FROM messages WHERE fromid=? AND toid=? ORDER BY id DESC LIMIT 1;`, sess.UserID, userid).Scan(&messageID)
+			notifyMentions(r, sess.UserID.Int64, "message", messageID)
 		}
 
 		sess.SetFlashMsg("Message sent.")
@@ -141,6 +155,7 @@ var PrivateMessageDeleteHandler = A(func(w http.ResponseWriter, r *http.Request,
 	if r.Method == "POST" {
 		id := r.PostFormValue("id")
 		db.Exec(`DELETE FROM messages WHERE id=? AND toid=?;`, id, sess.UserID)
+		db.Exec(`DELETE FROM mentions WHERE targettype='message' AND targetid=? AND userid=?;`, id, sess.UserID)
 		http.Redirect(w, r, "/pm?lmd="+r.PostFormValue("lmd"), http.StatusSeeOther)
 		return
 	}
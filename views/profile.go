@@ -5,41 +5,80 @@
 package views
 
 import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"github.com/s-gv/orangeforum/models"
 	"github.com/s-gv/orangeforum/models/db"
 	"github.com/s-gv/orangeforum/templates"
 	"html/template"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// redirectRenamedUser sends requests for a username that has since been changed to the same page of the renamed user.
+func redirectRenamedUser(w http.ResponseWriter, r *http.Request, oldName string) bool {
+	newName, err := models.ReadUserNameByOldName(oldName)
+	if err != nil {
+		return false
+	}
+	q := r.URL.Query()
+	q.Set("u", newName)
+	http.Redirect(w, r, r.URL.Path+"?"+q.Encode(), http.StatusMovedPermanently)
+	return true
+}
+
 var UserProfileHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	userName := r.FormValue("u")
 	var about, email string
-	var isBanned bool
-	var userID int64
-	if db.QueryRow(`SELECT id, about, email, is_banned FROM users WHERE username=?;`, userName).Scan(&userID, &about, &email, &isBanned) != nil {
-		ErrNotFoundHandler(w, r)
+	var userID, deleteDate int64
+	var mentionEmails bool
+	threadView := models.ThreadViewDefault
+	if db.QueryRow(`SELECT id, about, email, delete_date, mention_emails, thread_view FROM users WHERE username=?;`, userName).Scan(&userID, &about, &email, &deleteDate, &mentionEmails, &threadView) != nil {
+		if !redirectRenamedUser(w, r, userName) {
+			ErrNotFoundHandler(w, r)
+		}
 		return
 	}
 
+	commonData := readCommonData(r, sess)
+	isSelf := sess.UserID.Valid && (userID == sess.UserID.Int64)
+	showShadowBan := !isSelf && canShadowBan(sess, strconv.FormatInt(userID, 10))
+	banMessage := ""
+	ban, err := models.ReadActiveUserBan(userID)
+	isBanned := err == nil
+	if isBanned {
+		banMessage = banMsg(ban)
+	}
+
 	templates.Render(w, "profile.html", map[string]interface{}{
-		"Common":   readCommonData(r, sess),
-		"UserName": userName,
-		"About":    about,
-		"Email":    email,
-		"IsSelf":   sess.UserID.Valid && (userID == sess.UserID.Int64),
-		"IsBanned": isBanned,
+		"Common":            commonData,
+		"UserName":          userName,
+		"UserID":            userID,
+		"About":             about,
+		"Email":             email,
+		"MentionEmails":     mentionEmails,
+		"ThreadView":        threadView,
+		"IsSelf":            isSelf,
+		"IsBanned":          isBanned,
+		"IsBlocked":         sess.UserID.Valid && !isSelf && models.IsBlocked(sess.UserID.Int64, userID),
+		"BanMsg":            banMessage,
+		"CanShadowBan":      showShadowBan,
+		"IsShadowBanned":    showShadowBan && models.IsShadowBanned(userID),
+		"IsDeleteScheduled": deleteDate > 0,
+		"IsRenameAllowed":   commonData.IsSuperAdmin || (isSelf && models.Config(models.AllowUserNameChange) != "0"),
+		"TrustLevel":        models.TrustLevelName(models.ReadTrustLevel(userID)),
+		"TrustOverride":     models.ReadTrustLevelOverride(userID),
 	})
 })
 
 var UserProfileUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	userName := r.FormValue("u")
-	var about, email string
-	var isBanned bool
 	var userID int64
-	if db.QueryRow(`SELECT id, about, email, is_banned FROM users WHERE username=?;`, userName).Scan(&userID, &about, &email, &isBanned) != nil {
+	if db.QueryRow(`SELECT id FROM users WHERE username=?;`, userName).Scan(&userID) != nil {
 		ErrNotFoundHandler(w, r)
 		return
 	}
@@ -66,26 +105,88 @@ var UserProfileUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, se
 					http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
 					return
 				}
-				db.Exec(`UPDATE users SET email=?, about=? WHERE id=?;`, email, about, userID)
+				if userID != sess.UserID.Int64 {
+					var oldEmail, oldAbout string
+					db.QueryRow(`SELECT email, about FROM users WHERE id=?;`, userID).Scan(&oldEmail, &oldAbout)
+					logAction(sess, models.AuditUserEdit, "user", strconv.FormatInt(userID, 10), "", "email: "+oldEmail+"\nabout: "+oldAbout, "email: "+email+"\nabout: "+about)
+				}
+				threadView, err := strconv.Atoi(r.PostFormValue("thread_view"))
+				if err != nil || !models.IsValidThreadView(threadView) {
+					threadView = models.ThreadViewDefault
+				}
+				db.Exec(`UPDATE users SET email=?, about=?, mention_emails=?, thread_view=? WHERE id=?;`, email, about, r.PostFormValue("mention_emails") != "", threadView, userID)
 			} else {
 				ErrForbiddenHandler(w, r)
 				return
 			}
 		} else if action == "Ban" {
 			if isSuperAdmin {
-				db.Exec(`UPDATE users SET is_banned=1 WHERE id=?;`, userID)
-				db.Exec(`DELETE FROM sessions WHERE userid=?;`, userID)
+				reason := strings.TrimSpace(r.PostFormValue("ban_reason"))
+				days, err := strconv.Atoi(r.PostFormValue("ban_days"))
+				if err != nil || days < 0 {
+					sess.SetFlashMsg("Ban duration should be a number of days.")
+					http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
+					return
+				}
+				if len(reason) > 250 {
+					sess.SetFlashMsg("Ban reason should have fewer than 250 characters.")
+					http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
+					return
+				}
+				var expiry time.Time
+				if days > 0 {
+					expiry = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+				}
+				models.CreateBan(sql.NullInt64{Int64: userID, Valid: true}, sess.UserID.Int64, reason, "", expiry)
+				logAction(sess, models.AuditUserBan, "user", strconv.FormatInt(userID, 10), "", "", banDesc(reason, days))
 			} else {
 				ErrForbiddenHandler(w, r)
 				return
 			}
 		} else if action == "Unban" {
 			if isSuperAdmin {
-				db.Exec(`UPDATE users SET is_banned=0 WHERE id=?;`, userID)
+				models.LiftUserBans(userID)
+				logAction(sess, models.AuditUserUnban, "user", strconv.FormatInt(userID, 10), "", "", "")
 			} else {
 				ErrForbiddenHandler(w, r)
 				return
 			}
+		} else if action == "Shadow-ban" {
+			if userID == sess.UserID.Int64 || !canShadowBan(sess, strconv.FormatInt(userID, 10)) {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			models.ShadowBanUser(userID)
+			logAction(sess, models.AuditUserShadowBan, "user", strconv.FormatInt(userID, 10), "", "", "")
+		} else if action == "Lift shadow-ban" {
+			if !canShadowBan(sess, strconv.FormatInt(userID, 10)) {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			models.LiftShadowBan(userID)
+			logAction(sess, models.AuditUserShadowLift, "user", strconv.FormatInt(userID, 10), "", "", "")
+		} else if action == "Set trust level" {
+			level, err := strconv.Atoi(r.PostFormValue("trust_level"))
+			if !isSuperAdmin {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			if err != nil || !models.IsValidTrustLevel(level) {
+				sess.SetFlashMsg("Invalid trust level.")
+				http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
+				return
+			}
+			oldLevel := models.ReadTrustLevelOverride(userID)
+			models.SetTrustLevelOverride(userID, level)
+			logAction(sess, models.AuditUserTrust, "user", strconv.FormatInt(userID, 10), "", strconv.Itoa(oldLevel), strconv.Itoa(level))
+		} else if action == "Block" {
+			if userID == sess.UserID.Int64 {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			models.BlockUser(sess.UserID.Int64, userID)
+		} else if action == "Unblock" {
+			models.UnblockUser(sess.UserID.Int64, userID)
 		}
 	}
 	sess.SetFlashMsg("Update successful.")
@@ -102,7 +203,9 @@ var UserCommentsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess S
 
 	var ownerID string
 	if db.QueryRow(`SELECT id FROM users WHERE username=?;`, ownerName).Scan(&ownerID) != nil {
-		ErrNotFoundHandler(w, r)
+		if !redirectRenamedUser(w, r, ownerName) {
+			ErrNotFoundHandler(w, r)
+		}
 		return
 	}
 
@@ -114,27 +217,37 @@ var UserCommentsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess S
 		CreatedDate string
 		ImgSrc      string
 		IsDeleted   bool
+		CanModerate bool
 	}
 
 	commentsPerPage := 50
 
 	var comments []Comment
 	var rows *db.Rows
+	visibleCond, visibleArgs := models.VisibleGroupsCond(sess.UserID)
+	isSelf := sess.UserID.Valid && ownerID == strconv.FormatInt(sess.UserID.Int64, 10)
+	canSeeShadowed := isSelf || canShadowBan(sess, ownerID)
 	if lastCommentDate == 0 {
-		rows = db.Query(`SELECT topics.title, comments.topicid, comments.id, comments.content, comments.image, comments.created_date, comments.is_deleted FROM comments INNER JOIN topics ON topics.id = comments.topicid AND comments.userid=? ORDER BY comments.created_date DESC LIMIT ?;`, ownerID, commentsPerPage)
+		rows = db.Query(`SELECT topics.groupid, topics.title, comments.topicid, comments.id, comments.content, comments.image, comments.created_date, comments.edited_date, comments.is_deleted FROM comments INNER JOIN topics ON topics.id = comments.topicid AND comments.userid=? INNER JOIN groups ON groups.id=topics.groupid WHERE (comments.is_pending=0 OR ?=1) AND (comments.is_shadowed=0 OR ?=1) AND `+visibleCond+` ORDER BY comments.created_date DESC LIMIT ?;`,
+			append(append([]interface{}{ownerID, isSelf, canSeeShadowed}, visibleArgs...), commentsPerPage)...)
 	} else {
-		rows = db.Query(`SELECT topics.title, comments.topicid, comments.id, comments.content, comments.image, comments.created_date, comments.is_deleted FROM comments INNER JOIN topics ON topics.id = comments.topicid AND comments.userid=? AND comments.created_date < ? ORDER BY comments.created_date DESC LIMIT ?;`, ownerID, lastCommentDate, commentsPerPage)
+		rows = db.Query(`SELECT topics.groupid, topics.title, comments.topicid, comments.id, comments.content, comments.image, comments.created_date, comments.edited_date, comments.is_deleted FROM comments INNER JOIN topics ON topics.id = comments.topicid AND comments.userid=? AND comments.created_date < ? INNER JOIN groups ON groups.id=topics.groupid WHERE (comments.is_pending=0 OR ?=1) AND (comments.is_shadowed=0 OR ?=1) AND `+visibleCond+` ORDER BY comments.created_date DESC LIMIT ?;`,
+			append(append([]interface{}{ownerID, lastCommentDate, isSelf, canSeeShadowed}, visibleArgs...), commentsPerPage)...)
 	}
 
-	var cDate int64
+	var cDate, eDate int64
+	canBulk := false
 	for rows.Next() {
 		comments = append(comments, Comment{})
 		c := &comments[len(comments)-1]
 
-		var content string
-		rows.Scan(&c.TopicName, &c.TopicID, &c.ID, &content, &c.ImgSrc, &cDate, &c.IsDeleted)
+		var groupID, content string
+		rows.Scan(&groupID, &c.TopicName, &c.TopicID, &c.ID, &content, &c.ImgSrc, &cDate, &eDate, &c.IsDeleted)
 		c.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
-		c.Content = formatComment(content)
+		c.TopicName = censor(groupID, c.TopicName)
+		c.Content = formatPost("comment", c.ID, eDate, censor(groupID, content))
+		c.CanModerate = can(sess, models.CapDeleteOthers, groupID)
+		canBulk = canBulk || c.CanModerate
 	}
 
 	if len(comments) >= commentsPerPage {
@@ -148,6 +261,7 @@ var UserCommentsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess S
 		"OwnerName":       ownerName,
 		"Comments":        comments,
 		"LastCommentDate": lastCommentDate,
+		"CanBulk":         canBulk,
 	})
 })
 
@@ -155,7 +269,9 @@ var UserTopicsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 	ownerName := r.FormValue("u")
 	var ownerID string
 	if db.QueryRow(`SELECT id FROM users WHERE username=?;`, ownerName).Scan(&ownerID) != nil {
-		ErrNotFoundHandler(w, r)
+		if !redirectRenamedUser(w, r, ownerName) {
+			ErrNotFoundHandler(w, r)
+		}
 		return
 	}
 	lastTopicDate, err := strconv.ParseInt(r.FormValue("ltd"), 10, 64)
@@ -170,21 +286,31 @@ var UserTopicsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 		IsClosed    bool
 		IsDeleted   bool
 		CreatedDate string
+		CanModerate bool
 	}
 	var topics []Topic
+	canBulk := false
 	var rows *db.Rows
 	var cDate int64
+	visibleCond, visibleArgs := models.VisibleGroupsCond(sess.UserID)
+	isSelf := sess.UserID.Valid && ownerID == strconv.FormatInt(sess.UserID.Int64, 10)
+	canSeeShadowed := isSelf || canShadowBan(sess, ownerID)
 	if lastTopicDate == 0 {
-		rows = db.Query(`SELECT id, title, is_deleted, is_closed, created_date FROM topics WHERE userid=? ORDER BY created_date DESC LIMIT ?;`, ownerID, numTopicsPerPage)
+		rows = db.Query(`SELECT topics.id, topics.groupid, topics.title, topics.is_deleted, topics.is_closed, topics.created_date FROM topics INNER JOIN groups ON groups.id=topics.groupid WHERE topics.userid=? AND (topics.is_pending=0 OR ?=1) AND (topics.is_shadowed=0 OR ?=1) AND `+visibleCond+` ORDER BY topics.created_date DESC LIMIT ?;`,
+			append(append([]interface{}{ownerID, isSelf, canSeeShadowed}, visibleArgs...), numTopicsPerPage)...)
 	} else {
-		rows = db.Query(`SELECT id, title, is_deleted, is_closed, created_date FROM topics WHERE userid=? AND created_date < ? ORDER BY created_date DESC LIMIT ?;`, ownerID, lastTopicDate, numTopicsPerPage)
+		rows = db.Query(`SELECT topics.id, topics.groupid, topics.title, topics.is_deleted, topics.is_closed, topics.created_date FROM topics INNER JOIN groups ON groups.id=topics.groupid WHERE topics.userid=? AND topics.created_date < ? AND (topics.is_pending=0 OR ?=1) AND (topics.is_shadowed=0 OR ?=1) AND `+visibleCond+` ORDER BY topics.created_date DESC LIMIT ?;`,
+			append(append([]interface{}{ownerID, lastTopicDate, isSelf, canSeeShadowed}, visibleArgs...), numTopicsPerPage)...)
 	}
 	for rows.Next() {
 		topics = append(topics, Topic{})
 		t := &topics[len(topics)-1]
-		rows.Scan(&t.ID, &t.Title, &t.IsDeleted, &t.IsClosed, &cDate)
+		var groupID string
+		rows.Scan(&t.ID, &groupID, &t.Title, &t.IsDeleted, &t.IsClosed, &cDate)
 		t.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
-		t.Title = censor(t.Title)
+		t.Title = censor(groupID, t.Title)
+		t.CanModerate = canBulkModerate(sess, groupID)
+		canBulk = canBulk || t.CanModerate
 	}
 
 	if len(topics) >= numTopicsPerPage {
@@ -198,6 +324,7 @@ var UserTopicsHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 		"OwnerName":     ownerName,
 		"Topics":        topics,
 		"LastTopicDate": lastTopicDate,
+		"CanBulk":       canBulk,
 	})
 })
 
@@ -238,3 +365,258 @@ var UserGroupsHandler = A(func(w http.ResponseWriter, r *http.Request, sess Sess
 		"ModInGroups":   modInGroups,
 	})
 })
+
+var UserExportHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	type Profile struct {
+		UserName    string `json:"username"`
+		Email       string `json:"email"`
+		About       string `json:"about"`
+		CreatedDate int64  `json:"created_date"`
+	}
+	type Topic struct {
+		ID          int64  `json:"id"`
+		GroupName   string `json:"group"`
+		Title       string `json:"title"`
+		Content     string `json:"content"`
+		IsDeleted   bool   `json:"is_deleted"`
+		CreatedDate int64  `json:"created_date"`
+		UpdatedDate int64  `json:"updated_date"`
+	}
+	type Comment struct {
+		ID          int64  `json:"id"`
+		TopicID     int64  `json:"topic_id"`
+		Content     string `json:"content"`
+		Image       string `json:"image"`
+		IsDeleted   bool   `json:"is_deleted"`
+		CreatedDate int64  `json:"created_date"`
+		UpdatedDate int64  `json:"updated_date"`
+	}
+	type Message struct {
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Content     string `json:"content"`
+		CreatedDate int64  `json:"created_date"`
+	}
+	type Subscription struct {
+		Type        string `json:"type"`
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		CreatedDate int64  `json:"created_date"`
+	}
+
+	var profile Profile
+	db.QueryRow(`SELECT username, email, about, created_date FROM users WHERE id=?;`, sess.UserID).Scan(
+		&profile.UserName, &profile.Email, &profile.About, &profile.CreatedDate)
+
+	topics := []Topic{}
+	rows := db.Query(`SELECT topics.id, groups.name, topics.title, topics.content, topics.is_deleted, topics.created_date, topics.updated_date FROM topics INNER JOIN groups ON topics.groupid=groups.id WHERE topics.userid=? ORDER BY topics.created_date;`, sess.UserID)
+	for rows.Next() {
+		t := Topic{}
+		rows.Scan(&t.ID, &t.GroupName, &t.Title, &t.Content, &t.IsDeleted, &t.CreatedDate, &t.UpdatedDate)
+		topics = append(topics, t)
+	}
+
+	comments := []Comment{}
+	rows = db.Query(`SELECT id, topicid, content, image, is_deleted, created_date, updated_date FROM comments WHERE userid=? ORDER BY created_date;`, sess.UserID)
+	for rows.Next() {
+		c := Comment{}
+		rows.Scan(&c.ID, &c.TopicID, &c.Content, &c.Image, &c.IsDeleted, &c.CreatedDate, &c.UpdatedDate)
+		comments = append(comments, c)
+	}
+
+	msgs := []Message{}
+	rows = db.Query(`SELECT fromusers.username, tousers.username, messages.content, messages.created_date
+		FROM messages INNER JOIN users fromusers ON fromusers.id=messages.fromid INNER JOIN users tousers ON tousers.id=messages.toid
+		WHERE messages.fromid=? OR messages.toid=? ORDER BY messages.created_date;`, sess.UserID, sess.UserID)
+	for rows.Next() {
+		m := Message{}
+		rows.Scan(&m.From, &m.To, &m.Content, &m.CreatedDate)
+		msgs = append(msgs, m)
+	}
+
+	subs := []Subscription{}
+	rows = db.Query(`SELECT groups.id, groups.name, groupsubscriptions.created_date FROM groupsubscriptions INNER JOIN groups ON groupsubscriptions.groupid=groups.id WHERE groupsubscriptions.userid=?;`, sess.UserID)
+	for rows.Next() {
+		s := Subscription{Type: "group"}
+		rows.Scan(&s.ID, &s.Name, &s.CreatedDate)
+		subs = append(subs, s)
+	}
+	rows = db.Query(`SELECT topics.id, topics.title, topicsubscriptions.created_date FROM topicsubscriptions INNER JOIN topics ON topicsubscriptions.topicid=topics.id WHERE topicsubscriptions.userid=?;`, sess.UserID)
+	for rows.Next() {
+		s := Subscription{Type: "topic"}
+		rows.Scan(&s.ID, &s.Name, &s.CreatedDate)
+		subs = append(subs, s)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+profile.UserName+"-data.zip\"")
+	zw := zip.NewWriter(w)
+	files := []struct {
+		Name string
+		Data interface{}
+	}{
+		{"profile.json", profile},
+		{"topics.json", topics},
+		{"comments.json", comments},
+		{"messages.json", msgs},
+		{"subscriptions.json", subs},
+	}
+	for _, file := range files {
+		f, err := zw.Create(file.Name)
+		if err != nil {
+			log.Panicf("[ERROR] Error creating %s in archive: %s\n", file.Name, err)
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.Data); err != nil {
+			log.Panicf("[ERROR] Error encoding %s: %s\n", file.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Panicf("[ERROR] Error writing archive: %s\n", err)
+	}
+})
+
+var UserDeleteHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	var userName string
+	var deleteDate int64
+	db.QueryRow(`SELECT username, delete_date FROM users WHERE id=?;`, sess.UserID).Scan(&userName, &deleteDate)
+
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+		if action == "Delete account" {
+			passwd := r.PostFormValue("passwd")
+			if sess.Authenticate(userName, passwd) != nil {
+				sess.SetFlashMsg("Password incorrect.")
+				http.Redirect(w, r, "/users/delete", http.StatusSeeOther)
+				return
+			}
+			models.ScheduleUserDeletion(sess.UserID.Int64)
+			sess.SetFlashMsg("Your account is scheduled for deletion.")
+		} else if action == "Cancel deletion" {
+			models.CancelUserDeletion(sess.UserID.Int64)
+			sess.SetFlashMsg("Account deletion cancelled.")
+		}
+		http.Redirect(w, r, "/users/delete", http.StatusSeeOther)
+		return
+	}
+
+	graceDays := models.ConfigInt(models.AccountDeletionGraceDays)
+
+	deleteDateStr := ""
+	if deleteDate > 0 {
+		deleteDateStr = time.Unix(deleteDate, 0).Format("2006-01-02 15:04")
+	}
+
+	templates.Render(w, "deleteaccount.html", map[string]interface{}{
+		"Common":      readCommonData(r, sess),
+		"UserName":    userName,
+		"DeleteDate":  deleteDateStr,
+		"GraceDays":   graceDays,
+		"IsAnonymize": models.Config(models.AccountDeletionPolicy) != models.AccountDeletionHardDelete,
+	})
+})
+
+var UserRenameHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	userName := r.FormValue("u")
+	commonData := readCommonData(r, sess)
+	var userID int64
+	if db.QueryRow(`SELECT id FROM users WHERE username=?;`, userName).Scan(&userID) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	isSelf := userID == sess.UserID.Int64
+	if !commonData.IsSuperAdmin && !(isSelf && models.Config(models.AllowUserNameChange) != "0") {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	nextChangeDate := models.NextUserNameChangeDate(userID)
+	canChange := commonData.IsSuperAdmin || time.Now().After(nextChangeDate)
+
+	if r.Method == "POST" {
+		newName := strings.TrimSpace(r.PostFormValue("newname"))
+		if !canChange {
+			sess.SetFlashMsg("Username can be changed again on " + nextChangeDate.Format("2006-01-02") + ".")
+			http.Redirect(w, r, "/users/rename?u="+userName, http.StatusSeeOther)
+			return
+		}
+		if err := validateUserName(newName); err != nil {
+			sess.SetFlashMsg(err.Error())
+			http.Redirect(w, r, "/users/rename?u="+userName, http.StatusSeeOther)
+			return
+		}
+		if models.ProbeUser(newName) {
+			sess.SetFlashMsg("Username already registered.")
+			http.Redirect(w, r, "/users/rename?u="+userName, http.StatusSeeOther)
+			return
+		}
+		if models.IsUserNameOnCooldown(newName, userID) {
+			sess.SetFlashMsg("Username was recently released and is not available yet.")
+			http.Redirect(w, r, "/users/rename?u="+userName, http.StatusSeeOther)
+			return
+		}
+		models.RenameUser(userID, newName)
+		sess.SetFlashMsg("Username changed.")
+		http.Redirect(w, r, "/users?u="+newName, http.StatusSeeOther)
+		return
+	}
+
+	type OldName struct {
+		Name        string
+		CreatedDate string
+	}
+	var oldNames []OldName
+	rows := db.Query(`SELECT username, created_date FROM usernamehistory WHERE userid=? ORDER BY created_date DESC;`, userID)
+	for rows.Next() {
+		var o OldName
+		var cDate int64
+		rows.Scan(&o.Name, &cDate)
+		o.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
+		oldNames = append(oldNames, o)
+	}
+
+	nextChangeDateStr := ""
+	if !canChange {
+		nextChangeDateStr = nextChangeDate.Format("2006-01-02")
+	}
+
+	templates.Render(w, "renameuser.html", map[string]interface{}{
+		"Common":         commonData,
+		"UserName":       userName,
+		"OldNames":       oldNames,
+		"NextChangeDate": nextChangeDateStr,
+	})
+})
+
+var UserImpersonateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	if r.Method != "POST" || sess.IsImpersonating() || !sess.IsUserSuperAdmin() {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	userName := r.PostFormValue("u")
+	var userID int64
+	var isSuperAdmin bool
+	if db.QueryRow(`SELECT id, is_superadmin FROM users WHERE username=?;`, userName).Scan(&userID, &isSuperAdmin) != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if isSuperAdmin {
+		sess.SetFlashMsg("Cannot view the forum as another superadmin.")
+		http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
+		return
+	}
+	sess.StartImpersonation(userID)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+})
+
+func UserImpersonateEndHandler(w http.ResponseWriter, r *http.Request) {
+	defer ErrServerHandler(w, r)
+	sess := OpenSession(w, r)
+	if r.Method != "POST" || r.PostFormValue("csrf") != sess.CSRFToken {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	userName, _ := sess.UserName()
+	sess.EndImpersonation()
+	http.Redirect(w, r, "/users?u="+userName, http.StatusSeeOther)
+}
@@ -5,21 +5,28 @@
 package views
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"html"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func grabCSRFToken(body string) (string, error) {
 	csrfToken := ""
-	r := regexp.MustCompile("<input type=\"hidden\" name=\"csrf\" value=\"([A-Za-z0-9]+)\">")
+	r := regexp.MustCompile("<input type=\"hidden\" name=\"csrf\" value=\"([A-Za-z0-9_=&#;\\-]+)\">")
 	match := r.FindStringSubmatch(body)
 	if len(match) > 0 {
-		csrfToken = match[1]
+		csrfToken = html.UnescapeString(match[1])
 	}
 	if csrfToken == "" {
 		return "", errors.New("Unable to find CSRF token")
@@ -29,7 +36,7 @@ func grabCSRFToken(body string) (string, error) {
 
 func grabSessionID(recorder *httptest.ResponseRecorder) (string, error) {
 	sessionid := ""
-	r := regexp.MustCompile("^sessionid=([A-Za-z0-9]+);")
+	r := regexp.MustCompile("^sessionid=([A-Za-z0-9_=\\-]+);")
 	for _, cookie := range recorder.HeaderMap["Set-Cookie"] {
 		matches := r.FindStringSubmatch(cookie)
 		if len(matches) > 0 {
@@ -126,3 +133,271 @@ func TestLoggedInUserProfileHandler(t *testing.T) {
 		t.Errorf("Profile page doesn't have a link to change password page when logged in. Body: %s\n", body)
 	}
 }
+
+func postForTest(handler http.HandlerFunc, target string, sessionid string, form url.Values) *httptest.ResponseRecorder {
+	var csrfToken string
+	db.QueryRow(`SELECT csrf FROM sessions WHERE sessionid=?;`, sessionid).Scan(&csrfToken)
+	form.Set("csrf", csrfToken)
+	req, _ := http.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "sessionid", Path: "/", Value: sessionid, HttpOnly: true})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUserExportHandler(t *testing.T) {
+	models.CreateUser("exportuser", "exportuser123", "export@example.com")
+	sessionid, err := loginForTest("exportuser", "exportuser123")
+	if err != nil {
+		t.Fatalf("%v\n", err.Error())
+	}
+
+	req, _ := http.NewRequest("GET", "/users/export", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionid", Path: "/", Value: sessionid, HttpOnly: true})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UserExportHandler).ServeHTTP(rr, req)
+
+	if ctHeader := rr.Header().Get("Content-Type"); ctHeader != "application/zip" {
+		t.Fatalf("Content-Type header incorrect. Got: %s\n", ctHeader)
+	}
+	body := rr.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Export is not a valid zip archive: %s\n", err)
+	}
+	found := false
+	for _, f := range zr.File {
+		if f.Name == "profile.json" {
+			rc, _ := f.Open()
+			data, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			if !strings.Contains(string(data), "export@example.com") {
+				t.Errorf("profile.json does not contain the user's email. Got: %s\n", data)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Export does not contain profile.json\n")
+	}
+}
+
+func TestUserDeleteHandler(t *testing.T) {
+	models.CreateUser("deleteuser", "deleteuser123", "")
+	sessionid, err := loginForTest("deleteuser", "deleteuser123")
+	if err != nil {
+		t.Fatalf("%v\n", err.Error())
+	}
+	userID, _ := models.ReadUserIDByName("deleteuser")
+	db.Exec(`INSERT INTO groups(name, created_date, updated_date) VALUES(?, ?, ?);`, "deletegroup", 0, 0)
+	groupID := models.ReadGroupIDByName("deletegroup")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic by a deleted user", "content", userID, groupID, 0, 0, 0)
+
+	postForTest(http.HandlerFunc(UserDeleteHandler), "/users/delete", sessionid, url.Values{"action": {"Delete account"}, "passwd": {"wrongpasswd"}})
+	var deleteDate int64
+	db.QueryRow(`SELECT delete_date FROM users WHERE id=?;`, userID).Scan(&deleteDate)
+	if deleteDate != 0 {
+		t.Fatalf("Account scheduled for deletion with a wrong password.\n")
+	}
+
+	postForTest(http.HandlerFunc(UserDeleteHandler), "/users/delete", sessionid, url.Values{"action": {"Delete account"}, "passwd": {"deleteuser123"}})
+	db.QueryRow(`SELECT delete_date FROM users WHERE id=?;`, userID).Scan(&deleteDate)
+	if deleteDate == 0 {
+		t.Fatalf("Account not scheduled for deletion.\n")
+	}
+
+	db.Exec(`UPDATE users SET delete_date=? WHERE id=?;`, 1, userID)
+	models.DeleteExpiredUsers()
+	if models.ProbeUser("deleteuser") {
+		t.Errorf("User not deleted after the grace period.\n")
+	}
+	var ownerName string
+	db.QueryRow(`SELECT users.username FROM topics INNER JOIN users ON topics.userid=users.id WHERE topics.title=?;`, "Topic by a deleted user").Scan(&ownerName)
+	if ownerName != models.DeletedUserName {
+		t.Errorf("Topic not re-assigned to the placeholder user. Got owner: %s\n", ownerName)
+	}
+
+	oldPolicy := models.Config(models.AccountDeletionPolicy)
+	models.WriteConfig(models.AccountDeletionPolicy, models.AccountDeletionHardDelete)
+	defer models.WriteConfig(models.AccountDeletionPolicy, oldPolicy)
+	models.CreateUser("harddeleteuser", "harddeleteuser123", "")
+	hardUserID, _ := models.ReadUserIDByName("harddeleteuser")
+	adminID, _ := models.ReadUserIDByName("admin")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic by a hard-deleted user", "content", hardUserID, groupID, 0, 0, 0)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic with a hard-deleted comment", "content", adminID, groupID, 0, 0, 0)
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE title=?;`, "Topic with a hard-deleted comment").Scan(&topicID)
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`, "gone", topicID, hardUserID, 1, 1, 1)
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`, "kept", topicID, adminID, 2, 2, 2)
+	models.DeleteUser(int64(hardUserID))
+	var numTopics, pos int
+	db.QueryRow(`SELECT COUNT(*) FROM topics WHERE userid=?;`, hardUserID).Scan(&numTopics)
+	if numTopics != 0 {
+		t.Errorf("Topics of a hard-deleted user kept.\n")
+	}
+	db.QueryRow(`SELECT pos FROM comments WHERE topicid=? AND content=?;`, topicID, "kept").Scan(&pos)
+	if pos != 1 {
+		t.Errorf("Comments not renumbered after a hard delete. Got pos: %v\n", pos)
+	}
+}
+
+func TestUserRenameHandler(t *testing.T) {
+	models.CreateUser("oldname", "oldname123", "")
+	sessionid, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("%v\n", err.Error())
+	}
+
+	postForTest(http.HandlerFunc(UserRenameHandler), "/users/rename", sessionid, url.Values{"u": {"oldname"}, "newname": {"newname"}})
+	if models.ProbeUser("oldname") || !models.ProbeUser("newname") {
+		t.Fatalf("User not renamed.\n")
+	}
+
+	req, _ := http.NewRequest("GET", "/users?u=oldname", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UserProfileHandler).ServeHTTP(rr, req)
+	if loc := rr.Header().Get("Location"); loc != "/users?u=newname" {
+		t.Errorf("Old profile URL does not redirect to the new username. Got: %s\n", loc)
+	}
+
+	if !models.IsUserNameOnCooldown("oldname", 0) {
+		t.Errorf("Released username can be taken by another user right away.\n")
+	}
+}
+
+func TestUserImpersonateHandler(t *testing.T) {
+	models.CreateUser("viewasuser", "viewasuser123", "")
+	sessionid, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("%v\n", err.Error())
+	}
+
+	postForTest(http.HandlerFunc(UserImpersonateHandler), "/users/impersonate", sessionid, url.Values{"u": {"viewasuser"}})
+	req, _ := http.NewRequest("GET", "/users?u=viewasuser", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionid", Path: "/", Value: sessionid, HttpOnly: true})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UserProfileHandler).ServeHTTP(rr, req)
+	if body := rr.Body.String(); !strings.Contains(body, "Viewing the forum as <b>viewasuser</b>") {
+		t.Fatalf("View-as banner not shown. Body: %s\n", body)
+	}
+
+	rr = postForTest(http.HandlerFunc(UserProfileUpdateHandler), "/users/update", sessionid, url.Values{"u": {"viewasuser"}, "action": {"Update"}, "about": {"changed"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Write action allowed while viewing as another user. Got status: %d\n", rr.Code)
+	}
+
+	postForTest(http.HandlerFunc(UserImpersonateEndHandler), "/users/impersonate/end", sessionid, url.Values{})
+	var userName string
+	db.QueryRow(`SELECT users.username FROM sessions INNER JOIN users ON sessions.userid=users.id WHERE sessions.sessionid=?;`, sessionid).Scan(&userName)
+	if userName != "admin" {
+		t.Errorf("Session not restored after ending view-as. Got user: %s\n", userName)
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM impersonations;`).Scan(&n)
+	if n != 2 {
+		t.Errorf("Expected start and end in the audit table. Got %d rows\n", n)
+	}
+}
+
+func TestTrustLevels(t *testing.T) {
+	models.CreateUser("newbie", "newbie12345", "")
+	userID, _ := models.ReadUserIDByName("newbie")
+	if level := models.ReadTrustLevel(int64(userID)); level != models.TrustLevelNew {
+		t.Fatalf("New account has wrong trust level: got %v\n", level)
+	}
+	sessionid, err := loginForTest("newbie", "newbie12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+
+	postForTest(PrivateMessageCreateHandler, "/pm/new", sessionid, url.Values{"to": {"admin"}, "content": {"Hello from a new user"}})
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM messages WHERE fromid=?;`, userID).Scan(&count)
+	if count != 0 {
+		t.Errorf("New user was able to send a private message.\n")
+	}
+	if msg := linkLimitMsg(models.ReadTrustLevel(int64(userID)), "http://a.example.com http://b.example.com http://c.example.com"); msg == "" {
+		t.Errorf("New user was allowed to post too many links.\n")
+	}
+
+	adminSess, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	postForTest(UserProfileUpdateHandler, "/users/update?u=newbie", adminSess, url.Values{"action": {"Set trust level"}, "trust_level": {"1"}})
+	if level := models.ReadTrustLevel(int64(userID)); level != models.TrustLevelBasic {
+		t.Fatalf("Trust level override not applied: got %v\n", level)
+	}
+	postForTest(PrivateMessageCreateHandler, "/pm/new", sessionid, url.Values{"to": {"admin"}, "content": {"Hello from a basic user"}})
+	db.QueryRow(`SELECT COUNT(*) FROM messages WHERE fromid=?;`, userID).Scan(&count)
+	if count != 1 {
+		t.Errorf("Basic user unable to send a private message.\n")
+	}
+}
+
+func TestShadowBan(t *testing.T) {
+	groupID := createGroupForTest("shadowgroup")
+	shadowID, shadowSess := createUserForTest(t, "shadowuser")
+	readerID, readerSess := createUserForTest(t, "shadowreader")
+	_, modSess := createUserForTest(t, "shadowmod")
+	models.CreateGroupMod("shadowmod", groupID)
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, shadowID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic by the reader", "", readerID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	adminSess := mustLoginForTest(t, "admin", "admin12345")
+
+	postForTest(UserProfileUpdateHandler, "/users/update?u=shadowuser", adminSess, url.Values{"action": {"Shadow-ban"}})
+	if !models.IsShadowBanned(int64(shadowID)) {
+		t.Fatalf("User not shadow-banned.\n")
+	}
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, shadowSess, url.Values{"title": {"Shadowed topic title"}, "content": {"hidden"}})
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, shadowSess, url.Values{"content": {"shadowed comment text"}})
+	postForTest(PrivateMessageCreateHandler, "/pm/new", shadowSess, url.Values{"to": {"shadowreader"}, "content": {"Hello from the shadows"}})
+
+	if body := getForTest(GroupIndexHandler, "/groups?name=shadowgroup", readerSess).Body.String(); strings.Contains(body, "Shadowed topic title") {
+		t.Errorf("Shadowed topic visible to other users.\n")
+	}
+	if body := getForTest(GroupIndexHandler, "/groups?name=shadowgroup", shadowSess).Body.String(); !strings.Contains(body, "Shadowed topic title") {
+		t.Errorf("Shadowed topic not visible to its owner.\n")
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess).Body.String(); strings.Contains(body, "shadowed comment text") {
+		t.Errorf("Shadowed comment visible to other users.\n")
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, shadowSess).Body.String(); !strings.Contains(body, "shadowed comment text") {
+		t.Errorf("Shadowed comment not visible to its owner.\n")
+	}
+	var numComments, numMessages int
+	db.QueryRow(`SELECT num_comments FROM topics WHERE id=?;`, topicID).Scan(&numComments)
+	db.QueryRow(`SELECT COUNT(*) FROM messages WHERE toid=?;`, readerID).Scan(&numMessages)
+	if numComments != 0 || numMessages != 0 {
+		t.Errorf("Shadowed posts counted or messages delivered: %v comments, %v messages\n", numComments, numMessages)
+	}
+
+	if body := getForTest(UserProfileHandler, "/users?u=shadowuser", modSess).Body.String(); !strings.Contains(body, "Lift shadow-ban") {
+		t.Errorf("Shadow-ban not shown to a mod of a group the user posted in.\n")
+	}
+	if body := getForTest(UserTopicsHandler, "/users/topics?u=shadowuser", modSess).Body.String(); !strings.Contains(body, "Shadowed topic title") {
+		t.Errorf("Shadowed topic not listed for a mod of its group.\n")
+	}
+	if body := getForTest(UserProfileHandler, "/users?u=shadowuser", readerSess).Body.String(); strings.Contains(body, "Shadow-banned") {
+		t.Errorf("Shadow-ban shown to other users.\n")
+	}
+	if rr := postForTest(UserProfileUpdateHandler, "/users/update?u=shadowuser", readerSess, url.Values{"action": {"Lift shadow-ban"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("User able to lift a shadow-ban: got %v\n", rr.Code)
+	}
+	postForTest(UserProfileUpdateHandler, "/users/update?u=shadowuser", modSess, url.Values{"action": {"Lift shadow-ban"}})
+	if body := getForTest(GroupIndexHandler, "/groups?name=shadowgroup", readerSess).Body.String(); !strings.Contains(body, "Shadowed topic title") {
+		t.Errorf("Topic still hidden after the shadow-ban was lifted.\n")
+	}
+	db.QueryRow(`SELECT num_comments FROM topics WHERE id=?;`, topicID).Scan(&numComments)
+	if numComments != 1 {
+		t.Errorf("Comment not counted after the shadow-ban was lifted: got %v\n", numComments)
+	}
+}
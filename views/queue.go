@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"html/template"
+	"net/http"
+)
+
+// canApprovePosts reports whether the user may work on the approval queue of the group. The queue
+// of all groups is for superadmins.
+func canApprovePosts(sess Session, groupID string) bool {
+	if groupID == "" {
+		return sess.IsUserSuperAdmin()
+	}
+	return can(sess, models.CapApprovePosts, groupID)
+}
+
+var QueueHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	if !canApprovePosts(sess, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/queue"
+	if groupID != "" {
+		redirectURL = redirectURL + "?gid=" + groupID
+	}
+
+	if r.Method == "POST" {
+		postType := r.PostFormValue("type")
+		postID := r.PostFormValue("id")
+		action := r.PostFormValue("action")
+		var postGroupID, groupName, topicID, topicName, ownerName string
+		var ownerID int64
+		var isPending bool
+		if postType == "topic" {
+			db.QueryRow(`SELECT topics.groupid, groups.name, topics.id, topics.title, topics.is_pending, topics.userid FROM topics INNER JOIN groups ON topics.groupid=groups.id WHERE topics.id=?;`, postID).Scan(
+				&postGroupID, &groupName, &topicID, &topicName, &isPending, &ownerID)
+		} else if postType == "comment" {
+			db.QueryRow(`SELECT topics.groupid, groups.name, topics.id, topics.title, comments.is_pending, users.username, users.id FROM comments INNER JOIN topics ON comments.topicid=topics.id INNER JOIN groups ON topics.groupid=groups.id INNER JOIN users ON comments.userid=users.id WHERE comments.id=?;`, postID).Scan(
+				&postGroupID, &groupName, &topicID, &topicName, &isPending, &ownerName, &ownerID)
+		}
+		if postGroupID == "" || !can(sess, models.CapApprovePosts, postGroupID) {
+			ErrForbiddenHandler(w, r)
+			return
+		}
+		if !isPending {
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if postType == "topic" && action == "Approve" {
+			models.ApproveTopic(postID)
+			models.TrainSpam("topic", postID, false)
+			notifyGroupSubscribers(r, postGroupID, groupName, topicName)
+			notifyMentions(r, ownerID, "topic", postID)
+			logAction(sess, models.AuditTopicApprove, "topic", postID, postGroupID, "", "")
+		} else if postType == "topic" && action == "Reject" {
+			models.RejectTopic(postID)
+			logAction(sess, models.AuditTopicReject, "topic", postID, postGroupID, "", "")
+		} else if postType == "topic" && action == "Spam" {
+			models.RejectTopic(postID)
+			models.TrainSpam("topic", postID, true)
+			logAction(sess, models.AuditTopicSpam, "topic", postID, postGroupID, "", "")
+		} else if postType == "comment" && action == "Approve" {
+			models.ApproveComment(postID)
+			models.TrainSpam("comment", postID, false)
+			notifyTopicSubscribers(r, topicID, postGroupID, topicName, ownerName)
+			notifyMentions(r, ownerID, "comment", postID)
+			logAction(sess, models.AuditCommentApprove, "comment", postID, postGroupID, "", "")
+		} else if postType == "comment" && action == "Reject" {
+			models.RejectComment(postID)
+			logAction(sess, models.AuditCommentReject, "comment", postID, postGroupID, "", "")
+		} else if postType == "comment" && action == "Spam" {
+			models.RejectComment(postID)
+			models.TrainSpam("comment", postID, true)
+			logAction(sess, models.AuditCommentSpam, "comment", postID, postGroupID, "", "")
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	type Post struct {
+		ID          string
+		Type        string
+		TopicID     string
+		TopicTitle  string
+		GroupName   string
+		OwnerName   string
+		Content     template.HTML
+		SpamScore   int
+		CreatedDate string
+	}
+	var posts []Post
+	for _, p := range models.ReadPendingPosts(groupID) {
+		posts = append(posts, Post{
+			ID:          p.ID,
+			Type:        p.Type,
+			TopicID:     p.TopicID,
+			TopicTitle:  p.TopicTitle,
+			GroupName:   p.GroupName,
+			OwnerName:   p.OwnerName,
+			Content:     formatComment(censor(p.GroupID, p.Content)),
+			SpamScore:   p.SpamScore,
+			CreatedDate: timeAgoFromNow(p.CreatedDate),
+		})
+	}
+
+	var groupName string
+	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
+
+	templates.Render(w, "queue.html", map[string]interface{}{
+		"Common":    readCommonData(r, sess),
+		"GroupID":   groupID,
+		"GroupName": groupName,
+		"Posts":     posts,
+	})
+})
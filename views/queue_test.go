@@ -0,0 +1,117 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPremodQueue(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, premod_level, created_date, updated_date) VALUES(?, ?, ?, ?, ?);`, "premodgroup", "", models.PremodNew, time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("premodgroup")
+	_, modSess := createUserForTest(t, "premodmod")
+	_, newbieSess := createUserForTest(t, "premodnewbie")
+	_, readerSess := createUserForTest(t, "premodreader")
+	models.CreateGroupMod("premodmod", groupID)
+
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, newbieSess, url.Values{"title": {"Held for approval"}, "content": {"Hello"}})
+	var topicID string
+	var isPending bool
+	db.QueryRow(`SELECT id, is_pending FROM topics WHERE groupid=?;`, groupID).Scan(&topicID, &isPending)
+	if !isPending {
+		t.Fatalf("Topic by new user not held for approval.\n")
+	}
+	if rr := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess); rr.Code != http.StatusNotFound {
+		t.Errorf("Pending topic visible to other users: got %v\n", rr.Code)
+	}
+	if rr := getForTest(TopicIndexHandler, "/topics?id="+topicID, newbieSess); rr.Code != http.StatusOK {
+		t.Errorf("Pending topic not visible to its author: got %v\n", rr.Code)
+	}
+	if rr := getForTest(QueueHandler, "/queue?gid="+groupID, readerSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-moderator able to see the approval queue: got %v\n", rr.Code)
+	}
+	if body := getForTest(QueueHandler, "/queue?gid="+groupID, modSess).Body.String(); !strings.Contains(body, "Held for approval") {
+		t.Errorf("Pending topic not in the approval queue.\n")
+	}
+	postForTest(QueueHandler, "/queue?gid="+groupID, modSess, url.Values{"type": {"topic"}, "id": {topicID}, "action": {"Approve"}})
+	if rr := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess); rr.Code != http.StatusOK {
+		t.Errorf("Approved topic not visible: got %v\n", rr.Code)
+	}
+
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, newbieSess, url.Values{"content": {"A pending comment"}})
+	var numComments int
+	db.QueryRow(`SELECT num_comments FROM topics WHERE id=?;`, topicID).Scan(&numComments)
+	if numComments != 0 {
+		t.Errorf("Pending comment counted: got %v\n", numComments)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess).Body.String(); strings.Contains(body, "A pending comment") {
+		t.Errorf("Pending comment visible to other users.\n")
+	}
+	var commentID string
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&commentID)
+	postForTest(QueueHandler, "/queue?gid="+groupID, modSess, url.Values{"type": {"comment"}, "id": {commentID}, "action": {"Approve"}})
+	db.QueryRow(`SELECT num_comments FROM topics WHERE id=?;`, topicID).Scan(&numComments)
+	if numComments != 1 {
+		t.Errorf("Approved comment not counted: got %v\n", numComments)
+	}
+
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, modSess, url.Values{"content": {"Mods skip the queue"}})
+	if n := models.NumPendingPosts(groupID); n != 0 {
+		t.Errorf("Comment by mod held for approval: got %v pending\n", n)
+	}
+}
+
+func TestSpamClassifier(t *testing.T) {
+	groupID := createGroupForTest("spamgroup")
+	_, modSess := createUserForTest(t, "spammod")
+	posterID, posterSess := createUserForTest(t, "spamposter")
+	models.CreateGroupMod("spammod", groupID)
+	defer db.Exec(`DELETE FROM spamtokens;`)
+	defer db.Exec(`DELETE FROM spamtraining;`)
+	// The training topics below would otherwise count against the hourly topic limit.
+	oldLimit := models.Config(models.TopicsPerHour)
+	models.WriteConfig(models.TopicsPerHour, "0")
+	defer models.WriteConfig(models.TopicsPerHour, oldLimit)
+
+	for i := 0; i < 6; i++ {
+		for _, title := range []string{"Buy cheap replica watches online now", "Notes from the garden club meeting"} {
+			db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+				title, "", posterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+			var topicID string
+			db.QueryRow(`SELECT id FROM topics WHERE groupid=? ORDER BY id DESC LIMIT 1;`, groupID).Scan(&topicID)
+			if title == "Notes from the garden club meeting" {
+				postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, modSess, url.Values{"id": {topicID}, "title": {title}, "action": {"Not spam"}})
+			} else {
+				postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, modSess, url.Values{"id": {topicID}, "title": {title}, "action": {"Spam"}})
+			}
+		}
+	}
+	if score := models.SpamScore("Cheap replica watches"); score < 90 {
+		t.Errorf("Spam scored too low: got %v\n", score)
+	}
+	if score := models.SpamScore("The garden club meeting is on Friday"); score >= 50 {
+		t.Errorf("Non-spam scored too high: got %v\n", score)
+	}
+
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, posterID)
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, posterSess, url.Values{"title": {"Cheap replica watches here"}, "content": {"Buy now"}})
+	var isPending bool
+	var spamScore int
+	db.QueryRow(`SELECT is_pending, spam_score FROM topics WHERE groupid=? ORDER BY id DESC LIMIT 1;`, groupID).Scan(&isPending, &spamScore)
+	if !isPending || spamScore < 90 {
+		t.Errorf("Likely spam not held for approval: pending %v, score %v\n", isPending, spamScore)
+	}
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, posterSess, url.Values{"title": {"Garden club meeting on Friday"}, "content": {""}})
+	db.QueryRow(`SELECT is_pending FROM topics WHERE groupid=? ORDER BY id DESC LIMIT 1;`, groupID).Scan(&isPending)
+	if isPending {
+		t.Errorf("Non-spam held for approval.\n")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strings"
+)
+
+// readReportTarget returns the group of the reported item, a link to it and a short description of it.
+// The last value is false if the item does not exist or the user cannot see it.
+func readReportTarget(sess Session, targetType string, targetID string) (string, string, string, bool) {
+	var groupID, label string
+	if targetType == models.ReportTargetTopic {
+		if db.QueryRow(`SELECT groupid, title FROM topics WHERE id=?;`, targetID).Scan(&groupID, &label) != nil || !models.CanUserViewGroup(groupID, sess.UserID) {
+			return "", "", "", false
+		}
+		return groupID, "/topics?id=" + targetID, censor(groupID, label), true
+	} else if targetType == models.ReportTargetComment {
+		if db.QueryRow(`SELECT topics.groupid, comments.content FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.id=?;`, targetID).Scan(&groupID, &label) != nil || !models.CanUserViewGroup(groupID, sess.UserID) {
+			return "", "", "", false
+		}
+		return groupID, "/comments?id=" + targetID, excerpt(label), true
+	} else if targetType == models.ReportTargetMessage {
+		if db.QueryRow(`SELECT content FROM messages WHERE id=? AND (toid=? OR EXISTS (SELECT 1 FROM users WHERE id=? AND is_superadmin=1));`, targetID, sess.UserID, sess.UserID).Scan(&label) != nil {
+			return "", "", "", false
+		}
+		return "", "", excerpt(label), true
+	} else if targetType == models.ReportTargetUser {
+		if db.QueryRow(`SELECT username FROM users WHERE id=?;`, targetID).Scan(&label) != nil {
+			return "", "", "", false
+		}
+		return "", "/users?u=" + label, label, true
+	}
+	return "", "", "", false
+}
+
+// isHiddenPost reports whether the topic or comment is deleted, pending approval, or shadowed, and
+// the user is neither its author nor allowed to delete it.
+func isHiddenPost(sess Session, targetType string, targetID string, groupID string) bool {
+	table := "topics"
+	if targetType == models.ReportTargetComment {
+		table = "comments"
+	} else if targetType != models.ReportTargetTopic {
+		return false
+	}
+	var ownerID int64
+	var isDeleted, isPending, isShadowed bool
+	db.QueryRow(`SELECT userid, is_deleted, is_pending, is_shadowed FROM `+table+` WHERE id=?;`, targetID).Scan(&ownerID, &isDeleted, &isPending, &isShadowed)
+	if !isDeleted && !isPending && !isShadowed {
+		return false
+	}
+	return !(sess.UserID.Valid && ownerID == sess.UserID.Int64) && !can(sess, models.CapDeleteOthers, groupID)
+}
+
+func excerpt(content string) string {
+	if runes := []rune(content); len(runes) > 80 {
+		return string(runes[:80]) + "..."
+	}
+	return content
+}
+
+// canHandleReports reports whether the user may work on the report queue of the group. The global
+// queue, with reports on private messages and users, is for superadmins.
+func canHandleReports(sess Session, groupID string) bool {
+	if groupID == "" {
+		return sess.IsUserSuperAdmin()
+	}
+	return can(sess, models.CapHandleReports, groupID)
+}
+
+var ReportCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	targetType := r.FormValue("type")
+	targetID := r.FormValue("id")
+	groupID, targetURL, targetLabel, ok := readReportTarget(sess, targetType, targetID)
+	if !ok || isHiddenPost(sess, targetType, targetID, groupID) {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	redirectURL := "/reports/new?type=" + targetType + "&id=" + targetID
+
+	if r.Method == "POST" {
+		category := r.PostFormValue("category")
+		reason := strings.TrimSpace(r.PostFormValue("reason"))
+		if !models.IsValidReportCategory(category) {
+			sess.SetFlashMsg("Choose why you are reporting this.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if len(reason) > 1000 {
+			sess.SetFlashMsg("Details should have fewer than 1000 characters.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		models.CreateReport(sess.UserID.Int64, targetType, targetID, groupID, category, reason)
+		sess.SetFlashMsg("Thanks. The moderators will look into it.")
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	templates.Render(w, "reportnew.html", map[string]interface{}{
+		"Common":      readCommonData(r, sess),
+		"TargetType":  targetType,
+		"TargetID":    targetID,
+		"TargetURL":   targetURL,
+		"TargetLabel": targetLabel,
+		"Categories":  models.ReportCategories,
+	})
+})
+
+var ReportsHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	if !canHandleReports(sess, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/reports"
+	if groupID != "" {
+		redirectURL = redirectURL + "?gid=" + groupID
+	}
+
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+		resolution := strings.TrimSpace(r.PostFormValue("resolution"))
+		report, err := models.ReadReport(r.PostFormValue("id"))
+		if err != nil || !canHandleReports(sess, report.GroupID) {
+			ErrForbiddenHandler(w, r)
+			return
+		}
+		if len(resolution) > 1000 {
+			sess.SetFlashMsg("Resolution note should have fewer than 1000 characters.")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if action == "Claim" {
+			if !models.ClaimReport(report.ID, sess.UserID.Int64) {
+				sess.SetFlashMsg("The report has already been claimed by another moderator.")
+			}
+		} else if action == "Resolve" {
+			models.CloseReport(report.ID, sess.UserID.Int64, models.ReportStatusResolved, resolution)
+		} else if action == "Dismiss" {
+			models.CloseReport(report.ID, sess.UserID.Int64, models.ReportStatusDismissed, resolution)
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	type Report struct {
+		ID           string
+		ReporterName string
+		TargetType   string
+		TargetURL    string
+		TargetLabel  string
+		GroupName    string
+		Category     string
+		Reason       string
+		Status       string
+		ClaimerName  string
+		Resolution   string
+		CreatedDate  string
+		IsActive     bool
+	}
+	showAll := r.FormValue("all") != ""
+	var reports []Report
+	for _, rep := range models.ReadReports(groupID, !showAll) {
+		_, targetURL, targetLabel, _ := readReportTarget(sess, rep.TargetType, rep.TargetID)
+		if targetLabel == "" {
+			targetLabel = "[deleted " + rep.TargetType + "]"
+		}
+		reports = append(reports, Report{
+			ID:           rep.ID,
+			ReporterName: rep.ReporterName,
+			TargetType:   rep.TargetType,
+			TargetURL:    targetURL,
+			TargetLabel:  targetLabel,
+			GroupName:    rep.GroupName,
+			Category:     rep.Category,
+			Reason:       rep.Reason,
+			Status:       rep.Status,
+			ClaimerName:  rep.ClaimerName,
+			Resolution:   rep.Resolution,
+			CreatedDate:  timeAgoFromNow(rep.CreatedDate),
+			IsActive:     rep.IsActive(),
+		})
+	}
+
+	var groupName string
+	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
+
+	templates.Render(w, "reports.html", map[string]interface{}{
+		"Common":    readCommonData(r, sess),
+		"GroupID":   groupID,
+		"GroupName": groupName,
+		"Reports":   reports,
+		"ShowAll":   showAll,
+	})
+})
@@ -0,0 +1,102 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// createGroupForTest adds a group with the default settings and returns its ID.
+func createGroupForTest(name string) string {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, name, "", time.Now().Unix(), time.Now().Unix())
+	return models.ReadGroupIDByName(name)
+}
+
+// mustLoginForTest logs in and stops the test if that fails.
+func mustLoginForTest(t *testing.T, userName string, passwd string) string {
+	t.Helper()
+	sessionid, err := loginForTest(userName, passwd)
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	return sessionid
+}
+
+// createUserForTest signs up a user with the password userName+"123", logs them
+// in and returns the user ID and session ID.
+func createUserForTest(t *testing.T, userName string) (int, string) {
+	t.Helper()
+	models.CreateUser(userName, userName+"123", "")
+	userID, _ := models.ReadUserIDByName(userName)
+	return userID, mustLoginForTest(t, userName, userName+"123")
+}
+
+func TestReports(t *testing.T) {
+	groupID := createGroupForTest("reportgroup")
+	_, modSess := createUserForTest(t, "reportmod")
+	_, otherModSess := createUserForTest(t, "reportmod2")
+	reporterID, reporterSess := createUserForTest(t, "reporter")
+	models.CreateGroupMod("reportmod", groupID)
+	models.CreateGroupMod("reportmod2", groupID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A topic with a bad comment", "", reporterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID, commentID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+	db.Exec(`INSERT INTO comments(content, image, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Buy cheap watches", "", topicID, reporterID, 1, time.Now().Unix(), time.Now().Unix())
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&commentID)
+
+	report := url.Values{"type": {"comment"}, "id": {commentID}, "category": {"spam"}, "reason": {"Advertising"}}
+	postForTest(ReportCreateHandler, "/reports/new", reporterSess, report)
+	postForTest(ReportCreateHandler, "/reports/new", reporterSess, report)
+	if n := models.NumActiveReports(groupID); n != 1 {
+		t.Fatalf("Wrong number of active reports: got %v\n", n)
+	}
+
+	if rr := getForTest(ReportsHandler, "/reports?gid="+groupID, reporterSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-moderator able to see the report queue: got %v\n", rr.Code)
+	}
+	if rr := getForTest(ReportsHandler, "/reports", modSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Group moderator able to see the global report queue: got %v\n", rr.Code)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, modSess).Body.String(); !strings.Contains(body, "1 reports") {
+		t.Errorf("Report badge not shown to moderator.\n")
+	}
+
+	reports := models.ReadReports(groupID, true)
+	postForTest(ReportsHandler, "/reports?gid="+groupID, modSess, url.Values{"id": {reports[0].ID}, "action": {"Claim"}})
+	postForTest(ReportsHandler, "/reports?gid="+groupID, otherModSess, url.Values{"id": {reports[0].ID}, "action": {"Claim"}})
+	if claimed, _ := models.ReadReport(reports[0].ID); claimed.ClaimerName != "reportmod" {
+		t.Errorf("Claimed report taken over by another moderator: got %v\n", claimed.ClaimerName)
+	}
+	postForTest(ReportsHandler, "/reports?gid="+groupID, modSess, url.Values{"id": {reports[0].ID}, "action": {"Resolve"}, "resolution": {"Deleted the comment"}})
+	resolved, err := models.ReadReport(reports[0].ID)
+	if err != nil || resolved.Status != models.ReportStatusResolved || resolved.ClaimerName != "reportmod" || resolved.Resolution != "Deleted the comment" {
+		t.Errorf("Report not resolved: got %v\n", resolved)
+	}
+
+	db.Exec(`INSERT INTO comments(content, image, topicid, userid, pos, is_deleted, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`,
+		"A deleted comment", "", topicID, reporterID, 2, true, time.Now().Unix(), time.Now().Unix())
+	var deletedID string
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=? AND is_deleted=1;`, topicID).Scan(&deletedID)
+	_, outsiderSess := createUserForTest(t, "reportoutsider")
+	if rr := getForTest(ReportCreateHandler, "/reports/new?type=comment&id="+deletedID, outsiderSess); rr.Code != http.StatusNotFound || strings.Contains(rr.Body.String(), "A deleted comment") {
+		t.Errorf("Deleted comment shown on the report form: got %v\n", rr.Code)
+	}
+	if rr := getForTest(ReportCreateHandler, "/reports/new?type=comment&id="+deletedID, modSess); rr.Code != http.StatusOK {
+		t.Errorf("Moderator unable to report a deleted comment: got %v\n", rr.Code)
+	}
+
+	if s := excerpt(strings.Repeat("é", 100)); !utf8.ValidString(s) || !strings.HasSuffix(s, "...") {
+		t.Errorf("Excerpt cut a character in half: got %q\n", s)
+	}
+}
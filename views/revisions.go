@@ -0,0 +1,261 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Texts whose changed parts are longer than this (lines before x lines after) are not diffed line
+// by line; the changed part is shown as removed and added as a whole.
+var maxDiffCells = 1000000
+
+type diffRow struct {
+	Left  string
+	Right string
+	Kind  string // "same", "del", "add", or "change"
+}
+
+// diffLines compares two texts line by line for a side-by-side view. Runs of removed lines are
+// paired with the added lines that follow them.
+func diffLines(a string, b string) []diffRow {
+	var x, y []string
+	if a != "" {
+		x = strings.Split(a, "\n")
+	}
+	if b != "" {
+		y = strings.Split(b, "\n")
+	}
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+	mx, my := x[pre:len(x)-suf], y[pre:len(y)-suf]
+
+	var ops []diffRow
+	if len(mx)*len(my) > maxDiffCells {
+		for _, line := range mx {
+			ops = append(ops, diffRow{Left: line, Kind: "del"})
+		}
+		for _, line := range my {
+			ops = append(ops, diffRow{Right: line, Kind: "add"})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of mx[i:] and my[j:].
+		lcs := make([][]int, len(mx)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(my)+1)
+		}
+		for i := len(mx) - 1; i >= 0; i-- {
+			for j := len(my) - 1; j >= 0; j-- {
+				if mx[i] == my[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(mx) || j < len(my) {
+			if i < len(mx) && j < len(my) && mx[i] == my[j] {
+				ops = append(ops, diffRow{Left: mx[i], Right: my[j], Kind: "same"})
+				i++
+				j++
+			} else if j == len(my) || (i < len(mx) && lcs[i+1][j] >= lcs[i][j+1]) {
+				ops = append(ops, diffRow{Left: mx[i], Kind: "del"})
+				i++
+			} else {
+				ops = append(ops, diffRow{Right: my[j], Kind: "add"})
+				j++
+			}
+		}
+	}
+
+	var rows []diffRow
+	for _, line := range x[:pre] {
+		rows = append(rows, diffRow{Left: line, Right: line, Kind: "same"})
+	}
+	for k := 0; k < len(ops); {
+		if ops[k].Kind == "same" {
+			rows = append(rows, ops[k])
+			k++
+			continue
+		}
+		var dels, adds []string
+		for k < len(ops) && ops[k].Kind == "del" {
+			dels = append(dels, ops[k].Left)
+			k++
+		}
+		for k < len(ops) && ops[k].Kind == "add" {
+			adds = append(adds, ops[k].Right)
+			k++
+		}
+		for n := 0; n < len(dels) || n < len(adds); n++ {
+			row := diffRow{Kind: "change"}
+			if n < len(dels) {
+				row.Left = dels[n]
+			} else {
+				row.Kind = "add"
+			}
+			if n < len(adds) {
+				row.Right = adds[n]
+			} else {
+				row.Kind = "del"
+			}
+			rows = append(rows, row)
+		}
+	}
+	for _, line := range x[len(x)-suf:] {
+		rows = append(rows, diffRow{Left: line, Right: line, Kind: "same"})
+	}
+	return rows
+}
+
+// editedDateStr describes when a post was last edited, or "" if it never was.
+func editedDateStr(editedDate int64) string {
+	if editedDate == 0 {
+		return ""
+	}
+	return timeAgoFromNow(time.Unix(editedDate, 0))
+}
+
+func revisionText(rev models.Revision) string {
+	if rev.TargetType == "topic" {
+		return rev.Title + "\n\n" + rev.Content
+	}
+	return rev.Content
+}
+
+// RevisionsHandler shows the edit history of a topic or comment to its author and to moderators.
+// Moderators can restore an earlier revision, or redact one that should not be kept.
+var RevisionsHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	targetType := r.FormValue("type")
+	targetID := r.FormValue("id")
+	var groupID string
+	var ownerID int64
+	var err error
+	if targetType == "topic" {
+		err = db.QueryRow(`SELECT groupid, userid FROM topics WHERE id=?;`, targetID).Scan(&groupID, &ownerID)
+	} else if targetType == "comment" {
+		err = db.QueryRow(`SELECT topics.groupid, comments.userid FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.id=?;`, targetID).Scan(&groupID, &ownerID)
+	} else {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		ErrNotFoundHandler(w, r)
+		return
+	}
+	isOwner := sess.UserID.Valid && ownerID == sess.UserID.Int64
+	canEditOthers := can(sess, models.CapEditOthers, groupID)
+	if !models.CanUserViewGroup(groupID, sess.UserID) || (!isOwner && !canEditOthers) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	postURL := "/topics?id=" + targetID
+	if targetType == "comment" {
+		postURL = "/comments?id=" + targetID
+	}
+	redirectURL := "/revisions?type=" + targetType + "&id=" + targetID
+
+	if r.Method == "POST" {
+		isGroupClosed := true
+		db.QueryRow(`SELECT is_closed FROM groups WHERE id=?;`, groupID).Scan(&isGroupClosed)
+		if !canEditOthers || isGroupClosed || models.ReadGroupPolicy(groupID) == models.GroupPolicyReadOnly {
+			ErrForbiddenHandler(w, r)
+			return
+		}
+		rev, err := models.ReadRevision(r.PostFormValue("rev"))
+		if err != nil || rev.TargetType != targetType || rev.TargetID != targetID {
+			ErrForbiddenHandler(w, r)
+			return
+		}
+		action := r.PostFormValue("action")
+		if action == "Revert" {
+			if rev.IsRedacted {
+				sess.SetFlashMsg("A redacted revision cannot be restored.")
+				http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+				return
+			}
+			var oldTitle, oldContent string
+			if targetType == "topic" {
+				db.QueryRow(`SELECT title, content FROM topics WHERE id=?;`, targetID).Scan(&oldTitle, &oldContent)
+			} else {
+				db.QueryRow(`SELECT content FROM comments WHERE id=?;`, targetID).Scan(&oldContent)
+			}
+			if oldTitle == rev.Title && oldContent == rev.Content {
+				http.Redirect(w, r, postURL, http.StatusSeeOther)
+				return
+			}
+			models.EditPost(targetType, targetID, sess.UserID.Int64, rev.Title, rev.Content)
+			if targetType == "topic" {
+				logAction(sess, models.AuditTopicRevert, "topic", targetID, groupID, oldTitle+"\n\n"+oldContent, rev.Title+"\n\n"+rev.Content)
+			} else {
+				logAction(sess, models.AuditCommentRevert, "comment", targetID, groupID, oldContent, rev.Content)
+			}
+			http.Redirect(w, r, postURL, http.StatusSeeOther)
+			return
+		} else if action == "Redact" {
+			if err := models.RedactRevision(rev.ID); err != nil {
+				sess.SetFlashMsg(err.Error())
+				http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+				return
+			}
+			if targetType == "topic" {
+				logAction(sess, models.AuditTopicRedact, "topic", targetID, groupID, "revision "+rev.ID, "")
+			} else {
+				logAction(sess, models.AuditCommentRedact, "comment", targetID, groupID, "revision "+rev.ID, "")
+			}
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	type RevisionView struct {
+		ID          string
+		UserName    string
+		CreatedDate string
+		IsRedacted  bool
+		IsCurrent   bool
+		Rows        []diffRow
+	}
+	revisions := models.ReadRevisions(targetType, targetID)
+	var views []RevisionView
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rev := revisions[i]
+		prevText := ""
+		if i > 0 {
+			prevText = revisionText(revisions[i-1])
+		}
+		views = append(views, RevisionView{
+			ID:          rev.ID,
+			UserName:    rev.UserName,
+			CreatedDate: rev.CreatedDate.Format("2006-01-02 15:04"),
+			IsRedacted:  rev.IsRedacted,
+			IsCurrent:   i == len(revisions)-1,
+			Rows:        diffLines(prevText, revisionText(rev)),
+		})
+	}
+
+	templates.Render(w, "revisions.html", map[string]interface{}{
+		"Common":        readCommonData(r, sess),
+		"TargetType":    targetType,
+		"TargetID":      targetID,
+		"PostURL":       postURL,
+		"CanEditOthers": canEditOthers,
+		"Revisions":     views,
+	})
+})
@@ -0,0 +1,102 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffLines(t *testing.T) {
+	rows := diffLines("a\nb\nc\nd", "a\nB\nc\nd\ne")
+	kinds := []string{}
+	for _, row := range rows {
+		kinds = append(kinds, row.Kind)
+	}
+	if got := strings.Join(kinds, " "); got != "same change same same add" {
+		t.Errorf("Unexpected diff: %s\n", got)
+	}
+	if rows[1].Left != "b" || rows[1].Right != "B" {
+		t.Errorf("Changed line not paired: %+v\n", rows[1])
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	groupID := createGroupForTest("revgroup")
+	authorID, authorSess := createUserForTest(t, "revauthor")
+	_, modSess := createUserForTest(t, "revmod")
+	_, readerSess := createUserForTest(t, "revreader")
+	models.CreateGroupMod("revmod", groupID)
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, authorID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic with edits", "", authorID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID, commentID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		"first version", topicID, authorID, 1, time.Now().Unix(), time.Now().Unix())
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&commentID)
+
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess).Body.String(); strings.Contains(body, "edited") {
+		t.Errorf("Unedited comment marked as edited.\n")
+	}
+	postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, authorSess, url.Values{"action": {"Update"}, "content": {"my phone is 555-1234"}})
+	models.TrainSpam("comment", commentID, true)
+	defer db.Exec(`DELETE FROM spamtokens;`)
+	defer db.Exec(`DELETE FROM spamtraining;`)
+	postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, modSess, url.Values{"action": {"Update"}, "content": {"third version"}})
+	revisions := models.ReadRevisions("comment", commentID)
+	if len(revisions) != 3 || revisions[0].Content != "first version" || revisions[2].Content != "third version" {
+		t.Fatalf("Unexpected revisions: %+v\n", revisions)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicID, readerSess).Body.String(); !strings.Contains(body, "edited") {
+		t.Errorf("Edited comment not marked as edited.\n")
+	}
+
+	if rr := getForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, readerSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Other users able to see the edit history: got %v\n", rr.Code)
+	}
+	if body := getForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, modSess).Body.String(); !strings.Contains(body, "555-1234") {
+		t.Errorf("Old revision not in the edit history.\n")
+	}
+	if rr := postForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, authorSess, url.Values{"action": {"Revert"}, "rev": {revisions[0].ID}}); rr.Code != http.StatusForbidden {
+		t.Errorf("Author able to revert: got %v\n", rr.Code)
+	}
+
+	postForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, modSess, url.Values{"action": {"Redact"}, "rev": {revisions[1].ID}})
+	if body := getForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, modSess).Body.String(); strings.Contains(body, "555-1234") {
+		t.Errorf("Redacted revision still shown.\n")
+	}
+	for _, e := range models.ReadAuditLog(groupID, 0, "", 0) {
+		if strings.Contains(e.Before+e.After, "555-1234") {
+			t.Errorf("Redacted text still in the audit log: %+v\n", e)
+		}
+	}
+	var trainingContent string
+	var numTokens int
+	db.QueryRow(`SELECT content FROM spamtraining WHERE targettype='comment' AND targetid=?;`, commentID).Scan(&trainingContent)
+	db.QueryRow(`SELECT COUNT(*) FROM spamtokens WHERE token='1234' AND num_spam > 0;`).Scan(&numTokens)
+	if strings.Contains(trainingContent, "555-1234") || numTokens != 0 {
+		t.Errorf("Redacted text still known to the spam classifier.\n")
+	}
+	postForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, modSess, url.Values{"action": {"Redact"}, "rev": {revisions[2].ID}})
+	if rev, _ := models.ReadRevision(revisions[2].ID); rev.IsRedacted {
+		t.Errorf("Current revision redacted.\n")
+	}
+
+	postForTest(RevisionsHandler, "/revisions?type=comment&id="+commentID, modSess, url.Values{"action": {"Revert"}, "rev": {revisions[0].ID}})
+	var content string
+	db.QueryRow(`SELECT content FROM comments WHERE id=?;`, commentID).Scan(&content)
+	if content != "first version" {
+		t.Errorf("Revert did not restore the revision: got %q\n", content)
+	}
+	if n := len(models.ReadRevisions("comment", commentID)); n != 4 {
+		t.Errorf("Revert not recorded as a revision: got %v revisions\n", n)
+	}
+}
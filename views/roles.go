@@ -0,0 +1,101 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strings"
+)
+
+var AdminRolesHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	if !sess.IsUserSuperAdmin() {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+		if action == "Create role" {
+			name := strings.TrimSpace(r.PostFormValue("name"))
+			if err := validateName(name); err != nil || len(name) > 32 {
+				sess.SetFlashMsg("Role name should have up to 32 letters, numbers, - or _.")
+				http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+				return
+			}
+			if models.ReadRoleIDByName(name) != "" {
+				sess.SetFlashMsg("Role already exists: " + name)
+				http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+				return
+			}
+			models.CreateRole(name)
+		} else if action == "Save" || action == "Delete" {
+			roleID := r.PostFormValue("id")
+			var role models.Role
+			for _, rl := range models.ReadRoles() {
+				if rl.ID == roleID {
+					role = rl
+				}
+			}
+			if role.ID == "" || role.Name == models.RoleSuperAdmin {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			if action == "Delete" {
+				if role.IsSeeded() {
+					ErrForbiddenHandler(w, r)
+					return
+				}
+				models.DeleteRole(roleID)
+			} else {
+				r.ParseForm()
+				for _, capability := range models.Capabilities {
+					if r.PostForm.Get("cap_"+capability) != "" {
+						models.GrantCapability(roleID, capability)
+					} else {
+						models.RevokeCapability(roleID, capability)
+					}
+				}
+				sess.SetFlashMsg("Role updated: " + role.Name)
+			}
+		} else if action == "Assign" {
+			userName := strings.TrimSpace(r.PostFormValue("username"))
+			groupName := strings.TrimSpace(r.PostFormValue("group"))
+			roleID := models.ReadRoleIDByName(r.PostFormValue("role"))
+			userID, err := models.ReadUserIDByName(userName)
+			if err != nil {
+				sess.SetFlashMsg("Username not found: " + userName)
+				http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+				return
+			}
+			groupID := ""
+			if groupName != "" {
+				if groupID = models.ReadGroupIDByName(groupName); groupID == "" {
+					sess.SetFlashMsg("Group not found: " + groupName)
+					http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+					return
+				}
+			}
+			if roleID == "" {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			models.AssignRole(int64(userID), roleID, groupID)
+		} else if action == "Unassign" {
+			models.UnassignRole(r.PostFormValue("id"))
+		}
+		http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+		return
+	}
+
+	templates.Render(w, "adminroles.html", map[string]interface{}{
+		"Common":       readCommonData(r, sess),
+		"Roles":        models.ReadRoles(),
+		"Capabilities": models.Capabilities,
+		"UserRoles":    models.ReadUserRoles(),
+		"SuperAdmin":   models.RoleSuperAdmin,
+	})
+})
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"database/sql"
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCan(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "rolegroup", "", time.Now().Unix(), time.Now().Unix())
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "rolegroup2", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("rolegroup")
+	otherGroupID := models.ReadGroupIDByName("rolegroup2")
+	models.CreateUser("rolemod", "rolemod123", "")
+	models.CreateUser("roleuser", "roleuser123", "")
+	models.CreateGroupMod("rolemod", groupID)
+	modID, _ := models.ReadUserIDByName("rolemod")
+	userID, _ := models.ReadUserIDByName("roleuser")
+	adminID, _ := models.ReadUserIDByName("admin")
+	mod := sql.NullInt64{Int64: int64(modID), Valid: true}
+	user := sql.NullInt64{Int64: int64(userID), Valid: true}
+	superAdmin := sql.NullInt64{Int64: int64(adminID), Valid: true}
+
+	cases := []struct {
+		userID     sql.NullInt64
+		capability string
+		groupID    string
+		can        bool
+	}{
+		{user, models.CapPostTopic, groupID, true},
+		{user, models.CapClose, groupID, false},
+		{mod, models.CapClose, groupID, true},
+		{mod, models.CapClose, otherGroupID, false},
+		{mod, models.CapEditGroup, groupID, false},
+		{superAdmin, models.CapEditGroup, otherGroupID, true},
+		{sql.NullInt64{}, models.CapPostTopic, groupID, false},
+	}
+	for _, c := range cases {
+		if got := models.Can(c.userID, c.capability, c.groupID); got != c.can {
+			t.Errorf("Can(%v, %q, %q) = %v, want %v\n", c.userID.Int64, c.capability, c.groupID, got, c.can)
+		}
+	}
+
+	models.CreateRole("closer")
+	models.GrantCapability(models.ReadRoleIDByName("closer"), models.CapClose)
+	models.AssignRole(int64(userID), models.ReadRoleIDByName("closer"), otherGroupID)
+	if !models.Can(user, models.CapClose, otherGroupID) || models.Can(user, models.CapClose, groupID) {
+		t.Errorf("Group-level role assignment not scoped to its group.\n")
+	}
+
+	userRoleID := models.ReadRoleIDByName(models.RoleUser)
+	models.RevokeCapability(userRoleID, models.CapPostTopic)
+	sessionid, err := loginForTest("roleuser", "roleuser123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	rr := postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, sessionid, url.Values{"title": {"Topic without the capability"}})
+	models.GrantCapability(userRoleID, models.CapPostTopic)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("User without post_topic was able to create a topic: got %v\n", rr.Code)
+	}
+}
+
+func TestCommentEditOwnership(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "owngroup", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("owngroup")
+	models.CreateUser("commenter", "commenter123", "")
+	models.CreateUser("stranger", "stranger123", "")
+	commenterID, _ := models.ReadUserIDByName("commenter")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic with a comment", "", commenterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID, commentID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		"original", topicID, commenterID, 1, time.Now().Unix(), time.Now().Unix())
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&commentID)
+
+	sessionid, err := loginForTest("stranger", "stranger123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	rr := postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, sessionid, url.Values{"action": {"Update"}, "content": {"defaced"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("User was able to edit another user's comment: got %v\n", rr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"net/http"
+	"strings"
+)
+
+// ContentRulesHandler lists and edits the site-wide content rules for superadmins, and the rules
+// of a group for its admins. A sample post can be tested against the rules before they go live.
+var ContentRulesHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	if (groupID == "" && !sess.IsUserSuperAdmin()) || (groupID != "" && !can(sess, models.CapEditGroup, groupID)) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/rules"
+	if groupID != "" {
+		redirectURL = redirectURL + "?gid=" + groupID
+	}
+
+	var groupName string
+	if groupID != "" {
+		if db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName) != nil {
+			ErrNotFoundHandler(w, r)
+			return
+		}
+	}
+
+	var testContent string
+	var testResult *models.RuleResult
+	if r.Method == "POST" {
+		action := r.PostFormValue("action")
+		if action == "Add" {
+			rule := models.ContentRule{
+				GroupID:     groupID,
+				PatternType: r.PostFormValue("pattern_type"),
+				Pattern:     strings.TrimSpace(r.PostFormValue("pattern")),
+				Action:      r.PostFormValue("rule_action"),
+				Replacement: r.PostFormValue("replacement"),
+				Exceptions:  strings.TrimSpace(r.PostFormValue("exceptions")),
+			}
+			if err := models.ValidateContentRule(rule); err != nil {
+				sess.SetFlashMsg(err.Error())
+				http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+				return
+			}
+			ruleID := models.CreateContentRule(rule)
+			logAction(sess, models.AuditRuleCreate, "rule", ruleID, groupID, "", rule.PatternType+" "+rule.Pattern+" => "+rule.Action)
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		} else if action == "Delete" {
+			rule, err := models.ReadContentRule(r.PostFormValue("id"))
+			if err != nil || rule.GroupID != groupID {
+				ErrForbiddenHandler(w, r)
+				return
+			}
+			models.DeleteContentRule(rule.ID)
+			logAction(sess, models.AuditRuleDelete, "rule", rule.ID, groupID, rule.PatternType+" "+rule.Pattern+" => "+rule.Action, "")
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		} else if action == "Test" {
+			testContent = r.PostFormValue("content")
+			res := models.ApplyContentRules(groupID, testContent)
+			testResult = &res
+		}
+	}
+
+	templates.Render(w, "rules.html", map[string]interface{}{
+		"Common":      readCommonData(r, sess),
+		"GroupID":     groupID,
+		"GroupName":   groupName,
+		"Rules":       models.ReadContentRules(groupID),
+		"TestContent": testContent,
+		"TestResult":  testResult,
+	})
+})
@@ -0,0 +1,82 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestContentRules(t *testing.T) {
+	groupID := createGroupForTest("rulesgroup")
+	_, adminSess := createUserForTest(t, "rulesadmin")
+	posterID, posterSess := createUserForTest(t, "rulesposter")
+	models.CreateGroupAdmin("rulesadmin", groupID)
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, posterID)
+
+	if rr := getForTest(ContentRulesHandler, "/rules?gid="+groupID, posterSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-admin able to see content rules: got %v\n", rr.Code)
+	}
+	if rr := getForTest(ContentRulesHandler, "/rules", adminSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Group admin able to see site-wide content rules: got %v\n", rr.Code)
+	}
+
+	addRule := func(patternType, pattern, action, exceptions string) {
+		postForTest(ContentRulesHandler, "/rules?gid="+groupID, adminSess, url.Values{"action": {"Add"},
+			"pattern_type": {patternType}, "pattern": {pattern}, "rule_action": {action}, "exceptions": {exceptions}})
+	}
+	addRule("word", "darn", "mask", "")
+	addRule("wildcard", "heck*", "mask", "heckler")
+	addRule("word", "forbidden", "reject", "")
+	addRule("word", "suspicious", "hold", "")
+	addRule("regex", "(unclosed", "mask", "")
+	if n := len(models.ReadContentRules(groupID)); n != 4 {
+		t.Fatalf("Expected 4 rules, got %v\n", n)
+	}
+	ruleIDs := map[string]bool{}
+	for _, rule := range models.ReadContentRules(groupID) {
+		ruleIDs[rule.ID] = true
+	}
+	for _, e := range models.ReadAuditLog(groupID, 0, "", 0) {
+		if e.Action == models.AuditRuleCreate && !ruleIDs[e.TargetID] {
+			t.Errorf("New rule logged with the wrong ID: got %q\n", e.TargetID)
+		}
+	}
+
+	if out := censor(groupID, "darn darned heckle heckler"); out != "**** darned ****** heckler" {
+		t.Errorf("Unexpected masking: %q\n", out)
+	}
+	if out := censor("", "darn"); out != "darn" {
+		t.Errorf("Group rule applied outside the group: %q\n", out)
+	}
+
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, posterSess, url.Values{"title": {"A forbidden topic"}, "content": {"Hello"}})
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM topics WHERE groupid=?;`, groupID).Scan(&n)
+	if n != 0 {
+		t.Errorf("Post matching a reject rule was created.\n")
+	}
+
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, posterSess, url.Values{"title": {"A suspicious topic"}, "content": {"Hello"}})
+	var isPending bool
+	if db.QueryRow(`SELECT is_pending FROM topics WHERE groupid=?;`, groupID).Scan(&isPending) != nil || !isPending {
+		t.Errorf("Post matching a hold rule not held for approval.\n")
+	}
+
+	body := postForTest(ContentRulesHandler, "/rules?gid="+groupID, adminSess, url.Values{"action": {"Test"}, "content": {"this is forbidden"}}).Body.String()
+	if !strings.Contains(body, "Rejected") {
+		t.Errorf("Test preview does not show the rejection.\n")
+	}
+
+	rule := models.ReadContentRules(groupID)[0]
+	postForTest(ContentRulesHandler, "/rules?gid="+groupID, adminSess, url.Values{"action": {"Delete"}, "id": {rule.ID}})
+	if out := censor(groupID, "darn"); out != "darn" {
+		t.Errorf("Deleted rule still applied: %q\n", out)
+	}
+}
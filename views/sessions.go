@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
+	"github.com/s-gv/orangeforum/models"
 	"github.com/s-gv/orangeforum/models/db"
 	"golang.org/x/crypto/bcrypt"
 	"log"
@@ -16,16 +17,19 @@ import (
 )
 
 type Session struct {
-	SessionID   string
-	UserID      sql.NullInt64
-	CSRFToken   string
-	Msg         string
-	CreatedDate time.Time
-	UpdatedDate time.Time
+	SessionID       string
+	UserID          sql.NullInt64
+	CSRFToken       string
+	Msg             string
+	CreatedDate     time.Time
+	UpdatedDate     time.Time
+	ImpersonatorID  sql.NullInt64
+	ImpersonateDate time.Time
 }
 
 const maxSessionLife = 200 * time.Hour
 const maxSessionLifeBeforeUpdate = 100 * time.Hour
+const maxImpersonationLife = 30 * time.Minute
 
 var ErrAuthFail = errors.New("username / password incorrect")
 var ErrNoFlashMsg = errors.New("No flash message")
@@ -38,18 +42,25 @@ func OpenSession(w http.ResponseWriter, r *http.Request) Session {
 	cookie, err := r.Cookie("sessionid")
 	if err == nil {
 		sessionId := cookie.Value
-		r := db.QueryRow(`SELECT sessionid, userid, csrf, msg, created_date, updated_date FROM sessions WHERE sessionid=?;`, sessionId)
+		r := db.QueryRow(`SELECT sessionid, userid, csrf, msg, created_date, updated_date, impersonatorid, impersonate_date FROM sessions WHERE sessionid=?;`, sessionId)
 		sess := Session{}
 		var cDate int64
 		var uDate int64
-		if err := r.Scan(&sess.SessionID, &sess.UserID, &sess.CSRFToken, &sess.Msg, &cDate, &uDate); err == nil {
+		var iDate int64
+		if err := r.Scan(&sess.SessionID, &sess.UserID, &sess.CSRFToken, &sess.Msg, &cDate, &uDate, &sess.ImpersonatorID, &iDate); err == nil {
 			sess.CreatedDate = time.Unix(cDate, 0)
 			sess.UpdatedDate = time.Unix(uDate, 0)
+			sess.ImpersonateDate = time.Unix(iDate, 0)
 			if sess.UpdatedDate.After(time.Now().Add(-maxSessionLife)) {
 				if sess.UpdatedDate.Before(time.Now().Add(-maxSessionLifeBeforeUpdate)) {
 					nowDate := int64(time.Now().Unix())
 					db.Exec(`UPDATE sessions SET updated_date=? WHERE sessionid=?;`, nowDate, sessionId)
 				}
+				if sess.IsImpersonating() && sess.ImpersonateDate.Before(time.Now().Add(-maxImpersonationLife)) {
+					sess.EndImpersonation()
+					sess.Msg = "View-as session expired."
+					sess.SetFlashMsg(sess.Msg)
+				}
 				return sess
 			} else {
 				//log.Printf("[INFO] Session %s and last update date %s has expired.\n", sess.SessionID, sess.UpdatedDate)
@@ -59,7 +70,7 @@ func OpenSession(w http.ResponseWriter, r *http.Request) Session {
 		}
 	}
 
-	sess := Session{randSeq(32), sql.NullInt64{}, randSeq(32), "", time.Now(), time.Now()}
+	sess := Session{SessionID: randSeq(32), CSRFToken: randSeq(32), CreatedDate: time.Now(), UpdatedDate: time.Now()}
 	db.Exec(`INSERT INTO sessions(sessionid, userid, csrf, msg, created_date, updated_date) values(?, ?, ?, ?, ?, ?);`,
 		sess.SessionID, sess.UserID, sess.CSRFToken, sess.Msg, int64(sess.CreatedDate.Unix()), int64(sess.UpdatedDate.Unix()))
 	db.Exec(`DELETE FROM sessions WHERE updated_date < ?;`, int64(time.Now().Add(-maxSessionLife).Unix()))
@@ -82,16 +93,12 @@ func (sess *Session) FlashMsg() string {
 }
 
 func (sess *Session) Authenticate(userName string, passwd string) error {
-	r := db.QueryRow(`SELECT id, passwdhash, is_banned FROM users WHERE username=?;`, userName)
+	r := db.QueryRow(`SELECT id, passwdhash FROM users WHERE username=?;`, userName)
 	var passwdHashStr string
 	var userID int
-	var isBanned bool
-	if err := r.Scan(&userID, &passwdHashStr, &isBanned); err != nil {
+	if err := r.Scan(&userID, &passwdHashStr); err != nil {
 		return errors.New("Incorrect username or password")
 	}
-	if isBanned {
-		return errors.New("User banned")
-	}
 	passwdHash, err := hex.DecodeString(passwdHashStr)
 	if err != nil {
 		log.Panicf("[ERROR] Error in converting password hash from hex to byte slice: %s\n", err)
@@ -99,6 +106,9 @@ func (sess *Session) Authenticate(userName string, passwd string) error {
 	if err := bcrypt.CompareHashAndPassword(passwdHash, []byte(passwd)); err != nil {
 		return errors.New("Incorrect username or password")
 	}
+	if ban, err := models.ReadActiveUserBan(int64(userID)); err == nil {
+		return errors.New(banMsg(ban))
+	}
 	sess.UserID = sql.NullInt64{int64(userID), true}
 	db.Exec(`UPDATE sessions SET userid=? WHERE sessionid=?;`, sess.UserID, sess.SessionID)
 	return nil
@@ -119,6 +129,34 @@ func (sess *Session) IsUserSuperAdmin() bool {
 	return false
 }
 
+func (sess *Session) IsImpersonating() bool {
+	return sess.ImpersonatorID.Valid
+}
+
+// StartImpersonation lets a superadmin browse the forum as another user until EndImpersonation
+// is called or maxImpersonationLife runs out.
+func (sess *Session) StartImpersonation(userID int64) {
+	sess.ImpersonatorID = sess.UserID
+	sess.UserID = sql.NullInt64{Int64: userID, Valid: true}
+	sess.ImpersonateDate = time.Now()
+	db.Exec(`UPDATE sessions SET userid=?, impersonatorid=?, impersonate_date=? WHERE sessionid=?;`,
+		sess.UserID, sess.ImpersonatorID, sess.ImpersonateDate.Unix(), sess.SessionID)
+	db.Exec(`INSERT INTO impersonations(adminid, userid, action, created_date) VALUES(?, ?, ?, ?);`,
+		sess.ImpersonatorID, sess.UserID, "start", time.Now().Unix())
+}
+
+func (sess *Session) EndImpersonation() {
+	if !sess.IsImpersonating() {
+		return
+	}
+	db.Exec(`INSERT INTO impersonations(adminid, userid, action, created_date) VALUES(?, ?, ?, ?);`,
+		sess.ImpersonatorID, sess.UserID, "end", time.Now().Unix())
+	sess.UserID = sess.ImpersonatorID
+	sess.ImpersonatorID = sql.NullInt64{}
+	db.Exec(`UPDATE sessions SET userid=?, impersonatorid=?, impersonate_date=0 WHERE sessionid=?;`,
+		sess.UserID, sess.ImpersonatorID, sess.SessionID)
+}
+
 func (sess *Session) UserName() (string, error) {
 	if sess.UserID.Valid {
 		r := db.QueryRow(`SELECT username FROM users WHERE id=?;`, sess.UserID)
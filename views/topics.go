@@ -18,6 +18,22 @@ import (
 
 var numCommentsPerPage = 50
 
+// commentURL links to the comment on the page of the topic that shows it, in the view (flat or
+// threaded) the user sees the topic in.
+func commentURL(sess Session, topicID string, groupID string, commentID string) string {
+	page := 0
+	if models.IsThreaded(sess.UserID, groupID) {
+		page = models.ThreadPage(topicID, commentID, numCommentsPerPage)
+	} else {
+		var pos int
+		db.QueryRow(`SELECT pos FROM comments WHERE id=?;`, commentID).Scan(&pos)
+		if pos > 0 {
+			page = pos / numCommentsPerPage
+		}
+	}
+	return "/topics?id=" + topicID + "&p=" + strconv.Itoa(page) + "#comment-" + commentID
+}
+
 var TopicIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	topicID := r.FormValue("id")
 	page64, err := strconv.ParseInt(r.FormValue("p"), 10, 64)
@@ -28,15 +44,21 @@ var TopicIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 	if page < 0 {
 		page = 0
 	}
-	var title, content, groupID, groupName string
-	var isDeleted, isClosed bool
-	var ownerID, createdDate int64
-	if db.QueryRow(`SELECT title, content, userid, groupid, is_deleted, is_closed, created_date FROM topics WHERE id=?;`, topicID).Scan(
-		&title, &content, &ownerID, &groupID, &isDeleted, &isClosed, &createdDate) != nil {
+	var title, content, groupID, groupName, closeReason string
+	var isDeleted, isClosed, isPending, isShadowed bool
+	var ownerID, createdDate, editedDate int64
+	if db.QueryRow(`SELECT title, content, userid, groupid, is_deleted, is_closed, close_reason, is_pending, is_shadowed, created_date, edited_date FROM topics WHERE id=?;`, topicID).Scan(
+		&title, &content, &ownerID, &groupID, &isDeleted, &isClosed, &closeReason, &isPending, &isShadowed, &createdDate, &editedDate) != nil {
+		if newTopicID := models.ReadTopicRedirect(topicID); newTopicID != "" {
+			http.Redirect(w, r, "/topics?id="+newTopicID, http.StatusMovedPermanently)
+			return
+		}
 		ErrNotFoundHandler(w, r)
 		return
 	}
-	if isDeleted {
+	isOwner := sess.UserID.Valid && ownerID == sess.UserID.Int64
+	canApprove := can(sess, models.CapApprovePosts, groupID)
+	if isDeleted || !models.CanUserViewGroup(groupID, sess.UserID) || ((isPending || isShadowed) && !isOwner && !canApprove) {
 		ErrNotFoundHandler(w, r)
 		return
 	}
@@ -61,65 +83,138 @@ var TopicIndexHandler = UA(func(w http.ResponseWriter, r *http.Request, sess Ses
 		Content     template.HTML
 		ImgSrc      string
 		CreatedDate string
+		EditedDate  string
 		UserName    string
 		IsOwner     bool
 		IsDeleted   bool
+		IsPending   bool
+		IsShadowed  bool
+		NumReports  int
+		Replies     []*Comment
+		Topic       map[string]interface{} // For the comment template, which also renders the replies.
+	}
+
+	numTopicReports, commentReports := 0, map[string]int{}
+	if can(sess, models.CapHandleReports, groupID) {
+		numTopicReports, commentReports = models.ReadTopicReportCounts(topicID)
+	}
+
+	isThreaded := models.IsThreaded(sess.UserID, groupID)
+	var threadPage []models.ThreadComment
+	onThreadPage := make(map[string]bool)
+	if isThreaded {
+		pages := models.ReadThreadPages(topicID, numCommentsPerPage)
+		numPages = len(pages)
+		isLastPage = page >= numPages-1
+		if page < numPages {
+			threadPage = pages[page]
+		}
+		for _, tc := range threadPage {
+			onThreadPage[tc.ID] = true
+		}
 	}
 
 	var comments []Comment
-	var cDate int64
+	var cDate, eDate int64
 	var rows *db.Rows
-	if page == 0 {
-		rows = db.Query(`SELECT users.id, users.username, comments.id, comments.content, comments.image, comments.is_deleted, comments.created_date FROM comments INNER JOIN users ON comments.userid=users.id AND comments.topicid=? AND comments.pos < ? ORDER BY comments.pos;`, topicID, numCommentsPerPage)
+	if isThreaded && len(threadPage) > 0 {
+		args := []interface{}{topicID}
+		for _, tc := range threadPage {
+			args = append(args, tc.ID)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(threadPage)), ", ")
+		rows = db.Query(`SELECT users.id, users.username, comments.id, comments.content, comments.image, comments.is_deleted, comments.is_pending, comments.is_shadowed, comments.created_date, comments.edited_date FROM comments INNER JOIN users ON comments.userid=users.id AND comments.topicid=? WHERE comments.id IN (`+placeholders+`) ORDER BY comments.pos;`, args...)
+	} else if page == 0 {
+		rows = db.Query(`SELECT users.id, users.username, comments.id, comments.content, comments.image, comments.is_deleted, comments.is_pending, comments.is_shadowed, comments.created_date, comments.edited_date FROM comments INNER JOIN users ON comments.userid=users.id AND comments.topicid=? AND comments.pos < ? ORDER BY comments.pos;`, topicID, numCommentsPerPage)
 	} else {
-		rows = db.Query(`SELECT users.id, users.username, comments.id, comments.content, comments.image, comments.is_deleted, comments.created_date FROM comments INNER JOIN users ON comments.userid=users.id AND comments.topicid=? AND comments.pos >= ? AND comments.pos < ? ORDER BY comments.pos;`, topicID, page*numCommentsPerPage, (page+1)*numCommentsPerPage)
+		rows = db.Query(`SELECT users.id, users.username, comments.id, comments.content, comments.image, comments.is_deleted, comments.is_pending, comments.is_shadowed, comments.created_date, comments.edited_date FROM comments INNER JOIN users ON comments.userid=users.id AND comments.topicid=? AND comments.pos >= ? AND comments.pos < ? ORDER BY comments.pos;`, topicID, page*numCommentsPerPage, (page+1)*numCommentsPerPage)
 	}
 	for rows.Next() {
-		comments = append(comments, Comment{})
-		c := &comments[len(comments)-1]
+		var c Comment
 		var ownerID int64
 		var content string
-		rows.Scan(&ownerID, &c.UserName, &c.ID, &content, &c.ImgSrc, &c.IsDeleted, &cDate)
-		c.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
+		rows.Scan(&ownerID, &c.UserName, &c.ID, &content, &c.ImgSrc, &c.IsDeleted, &c.IsPending, &c.IsShadowed, &cDate, &eDate)
 		c.IsOwner = sess.UserID.Valid && (ownerID == sess.UserID.Int64)
-		c.Content = formatComment(content)
+		if (c.IsPending || c.IsShadowed) && !c.IsOwner && !canApprove {
+			continue
+		}
+		if isThreaded && !onThreadPage[c.ID] {
+			continue
+		}
+		c.CreatedDate = timeAgoFromNow(time.Unix(cDate, 0))
+		c.EditedDate = editedDateStr(eDate)
+		c.Content = formatPost("comment", c.ID, eDate, censor(groupID, content))
+		c.NumReports = commentReports[c.ID]
+		comments = append(comments, c)
+	}
+
+	// In the threaded view, replies to comments that are not shown go where the comment would have been.
+	var threads []*Comment
+	if isThreaded {
+		byID := make(map[string]*Comment)
+		for i := range comments {
+			byID[comments[i].ID] = &comments[i]
+		}
+		shownParent := make(map[string]string)
+		for _, tc := range threadPage {
+			parentID := shownParent[tc.ParentID]
+			c, ok := byID[tc.ID]
+			if !ok {
+				shownParent[tc.ID] = parentID
+				continue
+			}
+			shownParent[tc.ID] = tc.ID
+			if parent, ok := byID[parentID]; ok {
+				parent.Replies = append(parent.Replies, c)
+			} else {
+				threads = append(threads, c)
+			}
+		}
 	}
 
-	var tmp string
 	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
-	isMod := db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?`, sess.UserID).Scan(&isSuperAdmin)
-	isOwner := sess.UserID.Valid && ownerID == sess.UserID.Int64
+	policy := models.ReadGroupPolicy(groupID)
 
 	commonData := readCommonData(r, sess)
-	commonData.PageTitle = censor(title)
+	commonData.PageTitle = censor(groupID, title)
 
-	templates.Render(w, "topicindex.html", map[string]interface{}{
+	data := map[string]interface{}{
 		"Common":               commonData,
 		"GroupID":              groupID,
 		"TopicID":              topicID,
 		"GroupName":            groupName,
-		"TopicName":            censor(title),
+		"TopicName":            censor(groupID, title),
 		"OwnerName":            ownerName,
 		"CreatedDate":          timeAgoFromNow(time.Unix(createdDate, 0)),
+		"EditedDate":           editedDateStr(editedDate),
 		"SubToken":             subToken,
 		"Title":                title,
-		"Content":              formatComment(content),
+		"Content":              formatPost("topic", topicID, editedDate, censor(groupID, content)),
 		"IsClosed":             isClosed,
+		"CloseReason":          closeReason,
+		"IsPending":            isPending,
+		"IsShadowed":           isShadowed,
+		"CanApprove":           canApprove,
 		"IsOwner":              isOwner,
-		"IsMod":                isMod,
-		"IsAdmin":              isAdmin,
-		"IsSuperAdmin":         isSuperAdmin,
-		"IsImageUploadEnabled": models.Config(models.ImageUploadEnabled) != "0",
+		"NumReports":           numTopicReports,
+		"IsReadOnly":           policy == models.GroupPolicyReadOnly,
+		"CanReply":             !isClosed && !isPending && policy != models.GroupPolicyReadOnly && (!sess.UserID.Valid || can(sess, models.CapPostComment, groupID)),
+		"CanEditOthers":        can(sess, models.CapEditOthers, groupID),
+		"CanMove":              can(sess, models.CapMoveTopics, groupID),
+		"IsImageUploadEnabled": models.Config(models.ImageUploadEnabled) != "0" && can(sess, models.CapUploadImages, groupID) && trustLevel(sess) > models.TrustLevelNew,
 		"Comments":             comments,
 		"IsLastPage":           isLastPage,
 		"NextPage":             page + 1,
 		"CurrentPage":          page,
 		"Pages":                make([]int, numPages),
 		"NumPages":             numPages,
-	})
+		"IsThreaded":           isThreaded,
+		"Threads":              threads,
+	}
+	for i := range comments {
+		comments[i].Topic = data
+	}
+	templates.Render(w, "topicindex.html", data)
 })
 
 var TopicCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
@@ -127,21 +222,27 @@ var TopicCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Ses
 	var groupName string
 	isGroupClosed := 1
 	db.QueryRow(`SELECT name, is_closed FROM groups WHERE id=?;`, groupID).Scan(&groupName, &isGroupClosed)
-	if isGroupClosed == 1 {
+	if isGroupClosed == 1 || !models.CanUserViewGroup(groupID, sess.UserID) {
 		ErrForbiddenHandler(w, r)
 		return
 	}
 
-	var tmp int
-	isMod := db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?`, sess.UserID).Scan(&isSuperAdmin)
+	if !canPostTopic(sess, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	canSticky := can(sess, models.CapSticky, groupID)
+
+	if ban, err := models.ReadActiveGroupBan(groupID, sess.UserID.Int64); err == nil && !can(sess, models.CapBanUsers, groupID) {
+		http.Error(w, groupBanMsg(ban, groupName), http.StatusForbidden)
+		return
+	}
 
 	if r.Method == "POST" {
 		title := strings.TrimSpace(r.PostFormValue("title"))
 		content := strings.TrimSpace(r.PostFormValue("content"))
-		isSticky := r.PostFormValue("is_sticky") != ""
+		isSticky := canSticky && r.PostFormValue("is_sticky") != ""
+		trust := trustLevel(sess)
 		if len(title) < 8 || len(title) > 80 {
 			sess.SetFlashMsg("Title should have 8-80 characters.")
 			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
@@ -152,39 +253,67 @@ var TopicCreateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Ses
 			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
 			return
 		}
-		db.Exec(`INSERT INTO topics(title, content, userid, groupid, is_sticky, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`,
-			title, content, sess.UserID, groupID, isSticky, int(time.Now().Unix()), int(time.Now().Unix()), int(time.Now().Unix()))
-
-		if models.Config(models.AllowGroupSubscription) != "0" {
-			groupURL := "http://" + r.Host + "/groups?name=" + groupName
-			rows := db.Query(`SELECT users.email, groupsubscriptions.token FROM users INNER JOIN groupsubscriptions ON users.id=groupsubscriptions.userid AND groupsubscriptions.groupid=?;`, groupID)
-			for rows.Next() {
-				var email, token string
-				rows.Scan(&email, &token)
-				if email != "" {
-					unSubURL := "http://" + r.Host + "/groups/unsubscribe?token=" + token
-					utils.SendMail(email, `New topic in `+groupName,
-						"A new topic titled \""+title+"\" has been posted to "+groupName+".\r\nSee topics posted to the group at "+groupURL+"\r\n\r\nIf you do not want these emails, unsubscribe by following this link: "+unSubURL)
-				}
+		if msg := linkLimitMsg(trust, title+" "+content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
+			return
+		}
+		if msg := mentionLimitMsg(sess, content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
+			return
+		}
+		if msg := rateLimitMsg(sess, "topic", 1); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
+			return
+		}
+		ruleMsg, isHeld := contentRulesMsg(sess, groupID, title+"\n"+content, false)
+		if ruleMsg != "" {
+			sess.SetFlashMsg(ruleMsg)
+			http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
+			return
+		}
+		if trust == models.TrustLevelNew {
+			maxTopics := models.ConfigInt(models.NewUserTopicsPerDay)
+			var numTopics int
+			db.QueryRow(`SELECT COUNT(*) FROM topics WHERE userid=? AND created_date>?;`, sess.UserID, time.Now().Add(-24*time.Hour).Unix()).Scan(&numTopics)
+			if numTopics >= maxTopics {
+				sess.SetFlashMsg("New users can start at most " + strconv.Itoa(maxTopics) + " topics a day.")
+				http.Redirect(w, r, "/topics/new?gid="+groupID, http.StatusSeeOther)
+				return
 			}
 		}
+		spamScore := models.SpamScore(title + "\n" + content)
+		isShadowed := models.IsShadowBanned(sess.UserID.Int64)
+		isPending := !isShadowed && (needsApproval(sess, groupID, trust) || isHeldAsSpam(sess, groupID, spamScore) || isHeld)
+		db.Exec(`INSERT INTO topics(title, content, userid, groupid, is_sticky, is_pending, is_shadowed, spam_score, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+			title, content, sess.UserID, groupID, isSticky, isPending, isShadowed, spamScore, int(time.Now().Unix()), int(time.Now().Unix()), int(time.Now().Unix()))
+
+		if !isPending && !isShadowed {
+			notifyGroupSubscribers(r, groupID, groupName, title)
+			var topicID string
+			db.QueryRow(`SELECT id FROM topics WHERE userid=? ORDER BY id DESC LIMIT 1;`, sess.UserID).Scan(&topicID)
+			notifyMentions(r, sess.UserID.Int64, "topic", topicID)
+		}
 		http.Redirect(w, r, "/groups?name="+groupName, http.StatusSeeOther)
 		return
 	}
 
 	templates.Render(w, "topicedit.html", map[string]interface{}{
-		"Common":       readCommonData(r, sess),
-		"GroupID":      groupID,
-		"GroupName":    groupName,
-		"TopicID":      "",
-		"Title":        "",
-		"Content":      "",
-		"IsSticky":     false,
-		"IsClosed":     false,
-		"IsDeleted":    false,
-		"IsMod":        isMod,
-		"IsAdmin":      isAdmin,
-		"IsSuperAdmin": isSuperAdmin,
+		"Common":    readCommonData(r, sess),
+		"GroupID":   groupID,
+		"GroupName": groupName,
+		"TopicID":   "",
+		"Title":     "",
+		"Content":   "",
+		"IsSticky":  false,
+		"IsClosed":  false,
+		"IsDeleted": false,
+		"CanSticky": canSticky,
+		"CanClose":  false,
+		"CanDelete": false,
+		"CanMove":   false,
 	})
 })
 
@@ -206,30 +335,85 @@ var TopicUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Ses
 	isGroupClosed := 1
 	var groupName string
 	db.QueryRow(`SELECT name, is_closed FROM groups WHERE id=?;`, groupID).Scan(&groupName, &isGroupClosed)
-	if isGroupClosed == 1 {
+	if isGroupClosed == 1 || !models.CanUserViewGroup(groupID, sess.UserID) {
 		ErrForbiddenHandler(w, r)
 		return
 	}
 
-	var tmp int
 	var uID int64
 	db.QueryRow(`SELECT userid FROM topics WHERE id=?;`, topicID).Scan(&uID)
 
+	// In read-only groups, owners can no longer change their topics but moderators can.
 	isOwner := (uID == sess.UserID.Int64)
-	isMod := db.QueryRow(`SELECT id FROM mods WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isAdmin := db.QueryRow(`SELECT id FROM admins WHERE groupid=? AND userid=?;`, groupID, sess.UserID).Scan(&tmp) == nil
-	isSuperAdmin := false
-	db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?`, sess.UserID).Scan(&isSuperAdmin)
+	isReadOnly := models.ReadGroupPolicy(groupID) == models.GroupPolicyReadOnly
+	canEdit := (isOwner && !isReadOnly) || can(sess, models.CapEditOthers, groupID)
+	canSticky := can(sess, models.CapSticky, groupID)
+	canClose := can(sess, models.CapClose, groupID)
+	canDelete := (isOwner && !isReadOnly) || can(sess, models.CapDeleteOthers, groupID)
+	canMarkSpam := can(sess, models.CapDeleteOthers, groupID)
+	canMove := can(sess, models.CapMoveTopics, groupID)
 
-	if !isMod && !isAdmin && !isSuperAdmin {
-		db.QueryRow(`SELECT is_sticky FROM topics WHERE id=?;`, topicID).Scan(&isSticky)
-		if !isOwner {
+	if r.Method == "POST" && (action == "Move" || action == "Split" || action == "Merge") {
+		if !canMove {
 			ErrForbiddenHandler(w, r)
 			return
 		}
+		if action == "Move" {
+			targetGroupID := models.ReadGroupIDByName(strings.TrimSpace(r.PostFormValue("group")))
+			if targetGroupID == "" || targetGroupID == groupID || !canMoveInto(sess, targetGroupID) {
+				sess.SetFlashMsg("You cannot move topics to that group.")
+				http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+				return
+			}
+			models.MoveTopic(topicID, targetGroupID)
+			logAction(sess, models.AuditTopicMove, "topic", topicID, groupID, groupName, strings.TrimSpace(r.PostFormValue("group")))
+			http.Redirect(w, r, "/topics?id="+topicID, http.StatusSeeOther)
+		} else if action == "Split" {
+			splitTitle := strings.TrimSpace(r.PostFormValue("split_title"))
+			if len(splitTitle) < 8 || len(splitTitle) > 80 {
+				sess.SetFlashMsg("Title should have 8-80 characters.")
+				http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+				return
+			}
+			newTopicID := models.SplitTopic(topicID, r.PostFormValue("comment"), splitTitle)
+			if newTopicID == "" {
+				sess.SetFlashMsg("Comment not found in this topic.")
+				http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+				return
+			}
+			logAction(sess, models.AuditTopicSplit, "topic", topicID, groupID, "", newTopicID)
+			http.Redirect(w, r, "/topics?id="+newTopicID, http.StatusSeeOther)
+		} else if action == "Merge" {
+			targetID := r.PostFormValue("into")
+			var targetGroupID string
+			if targetID == topicID || db.QueryRow(`SELECT groupid FROM topics WHERE id=?;`, targetID).Scan(&targetGroupID) != nil ||
+				!can(sess, models.CapMoveTopics, targetGroupID) || !canMoveInto(sess, targetGroupID) {
+				sess.SetFlashMsg("You cannot merge into that topic.")
+				http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+				return
+			}
+			models.MergeTopics(topicID, targetID)
+			logAction(sess, models.AuditTopicMerge, "topic", topicID, groupID, "", targetID)
+			http.Redirect(w, r, "/topics?id="+targetID, http.StatusSeeOther)
+		}
+		return
+	}
+
+	if !canEdit && !canSticky && !canClose && !canDelete && !canMarkSpam && !canMove {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	if !canSticky {
+		db.QueryRow(`SELECT is_sticky FROM topics WHERE id=?;`, topicID).Scan(&isSticky)
 	}
 
 	if r.Method == "POST" {
+		var oldTitle, oldContent string
+		var oldSticky bool
+		db.QueryRow(`SELECT title, content, is_sticky FROM topics WHERE id=?;`, topicID).Scan(&oldTitle, &oldContent, &oldSticky)
+		if !canEdit {
+			title, content = oldTitle, oldContent
+		}
 		if len(title) < 8 || len(title) > 80 {
 			sess.SetFlashMsg("Title should have 8-80 characters.")
 			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
@@ -240,18 +424,58 @@ var TopicUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Ses
 			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
 			return
 		}
+		if msg := linkLimitMsg(trustLevel(sess), title+" "+content); msg != "" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+			return
+		}
+		if msg := mentionLimitMsg(sess, content); msg != "" && action == "Update" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+			return
+		}
+		if msg, _ := contentRulesMsg(sess, groupID, title+"\n"+content, true); msg != "" && action == "Update" {
+			sess.SetFlashMsg(msg)
+			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+			return
+		}
 		if action == "Update" {
-			db.Exec(`UPDATE topics SET title=?, content=?, is_sticky=?, updated_date=? WHERE id=?;`, title, content, isSticky, int(time.Now().Unix()), topicID)
-		} else if action == "Close" && (isMod || isAdmin || isSuperAdmin) {
-			db.Exec(`UPDATE topics SET is_closed=1 WHERE id=?;`, topicID)
-		} else if action == "Reopen" && (isMod || isAdmin || isSuperAdmin) {
-			db.Exec(`UPDATE topics SET is_closed=0 WHERE id=?;`, topicID)
-		} else if action == "Delete" {
-			db.Exec(`UPDATE topics SET is_deleted=1 WHERE id=?;`, topicID)
+			db.Exec(`UPDATE topics SET is_sticky=?, updated_date=? WHERE id=?;`, isSticky, int(time.Now().Unix()), topicID)
+			if title != oldTitle || content != oldContent {
+				models.EditPost("topic", topicID, sess.UserID.Int64, title, content)
+			}
+			if isOwner && content != oldContent {
+				notifyMentions(r, sess.UserID.Int64, "topic", topicID)
+			}
+			if !isOwner && (title != oldTitle || content != oldContent) {
+				logAction(sess, models.AuditTopicEdit, "topic", topicID, groupID, oldTitle+"\n\n"+oldContent, title+"\n\n"+content)
+			}
+			if isSticky != oldSticky {
+				logAction(sess, models.AuditTopicSticky, "topic", topicID, groupID, boolStr(oldSticky), boolStr(isSticky))
+			}
+		} else if action == "Close" && canClose {
+			models.CloseTopic(topicID, "")
+			logAction(sess, models.AuditTopicClose, "topic", topicID, groupID, "", "")
+		} else if action == "Reopen" && canClose {
+			models.ReopenTopic(topicID)
+			logAction(sess, models.AuditTopicReopen, "topic", topicID, groupID, "", "")
+		} else if action == "Delete" && canDelete {
+			models.DeleteTopic(topicID)
+			logAction(sess, models.AuditTopicDelete, "topic", topicID, groupID, "", "")
 			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
 			return
-		} else if action == "Undelete" {
-			db.Exec(`UPDATE topics SET is_deleted=0 WHERE id=?;`, topicID)
+		} else if action == "Undelete" && canDelete {
+			models.UndeleteTopic(topicID)
+			logAction(sess, models.AuditTopicUndelete, "topic", topicID, groupID, "", "")
+		} else if action == "Spam" && canMarkSpam {
+			models.DeleteTopic(topicID)
+			models.TrainSpam("topic", topicID, true)
+			logAction(sess, models.AuditTopicSpam, "topic", topicID, groupID, "", "")
+			http.Redirect(w, r, "/topics/edit?id="+topicID, http.StatusSeeOther)
+			return
+		} else if action == "Not spam" && canMarkSpam {
+			models.TrainSpam("topic", topicID, false)
+			logAction(sess, models.AuditTopicNotSpam, "topic", topicID, groupID, "", "")
 		}
 		http.Redirect(w, r, "/topics?id="+topicID, http.StatusSeeOther)
 		return
@@ -272,20 +496,34 @@ var TopicUpdateHandler = A(func(w http.ResponseWriter, r *http.Request, sess Ses
 		"IsSticky":     isSticky,
 		"IsClosed":     isClosed,
 		"IsDeleted":    isDeleted,
-		"IsMod":        isMod,
-		"IsAdmin":      isAdmin,
-		"IsSuperAdmin": isSuperAdmin,
+		"CanEdit":      canEdit,
+		"CanSticky":    canSticky,
+		"CanClose":     canClose,
+		"CanDelete":    canDelete,
+		"CanMarkSpam":  canMarkSpam,
+		"CanMove":      canMove,
+		"SplitComment": r.FormValue("split"),
 	})
 })
 
+// canMoveInto reports whether topics may be moved into the given group by this user.
+func canMoveInto(sess Session, groupID string) bool {
+	isClosed := true
+	db.QueryRow(`SELECT is_closed FROM groups WHERE id=?;`, groupID).Scan(&isClosed)
+	if isClosed || !models.CanUserViewGroup(groupID, sess.UserID) || models.ReadGroupPolicy(groupID) == models.GroupPolicyReadOnly {
+		return false
+	}
+	return can(sess, models.CapMoveTopics, groupID) || canPostTopic(sess, groupID)
+}
+
 var TopicSubscribeHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
 	topicID := r.FormValue("id")
 	if models.Config(models.AllowTopicSubscription) == "0" {
 		ErrForbiddenHandler(w, r)
 		return
 	}
-	var tmp string
-	if db.QueryRow(`SELECT id FROM topics WHERE id=?;`, topicID).Scan(&tmp) != nil {
+	var groupID string
+	if db.QueryRow(`SELECT groupid FROM topics WHERE id=?;`, topicID).Scan(&groupID) != nil || !models.CanUserViewGroup(groupID, sess.UserID) {
 		ErrNotFoundHandler(w, r)
 		return
 	}
@@ -327,3 +565,21 @@ var TopicUnsubscribeHandler = UA(func(w http.ResponseWriter, r *http.Request, se
 	<input type="submit" value="Unsubscribe">
 	</form></body></html>`))
 })
+
+// notifyGroupSubscribers emails the subscribers of the group about a new topic.
+func notifyGroupSubscribers(r *http.Request, groupID string, groupName string, title string) {
+	if models.Config(models.AllowGroupSubscription) == "0" {
+		return
+	}
+	groupURL := "http://" + r.Host + "/groups?name=" + groupName
+	rows := db.Query(`SELECT users.email, groupsubscriptions.token FROM users INNER JOIN groupsubscriptions ON users.id=groupsubscriptions.userid AND groupsubscriptions.groupid=? INNER JOIN groups ON groups.id=groupsubscriptions.groupid WHERE `+models.GroupViewersCond+`;`, groupID)
+	for rows.Next() {
+		var email, token string
+		rows.Scan(&email, &token)
+		if email != "" {
+			unSubURL := "http://" + r.Host + "/groups/unsubscribe?token=" + token
+			utils.SendMail(email, `New topic in `+groupName,
+				"A new topic titled \""+title+"\" has been posted to "+groupName+".\r\nSee topics posted to the group at "+groupURL+"\r\n\r\nIf you do not want these emails, unsubscribe by following this link: "+unSubURL)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoveSplitMergeTopics(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "movefrom", "", time.Now().Unix(), time.Now().Unix())
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "moveto", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("movefrom")
+	otherGroupID := models.ReadGroupIDByName("moveto")
+	models.CreateUser("movemod", "movemod123", "")
+	models.CreateUser("mover", "mover12345", "")
+	models.CreateGroupMod("movemod", groupID)
+	models.CreateGroupMod("movemod", otherGroupID)
+
+	modSess, err := loginForTest("movemod", "movemod123")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	userSess, err := loginForTest("mover", "mover12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+
+	postForTest(TopicCreateHandler, "/topics/new?gid="+groupID, userSess, url.Values{"title": {"A topic that will be split"}, "content": {"Hello"}})
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=? ORDER BY id DESC LIMIT 1;`, groupID).Scan(&topicID)
+	db.Exec(`INSERT INTO topicsubscriptions(userid, topicid, token, created_date) VALUES((SELECT id FROM users WHERE username=?), ?, ?, ?);`,
+		"mover", topicID, "movetoken", time.Now().Unix())
+	for _, content := range []string{"first", "second", "third"} {
+		postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, userSess, url.Values{"content": {content}})
+	}
+	var splitCommentID string
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=? AND content=?;`, topicID, "second").Scan(&splitCommentID)
+	db.Exec(`UPDATE comments SET pos=-pos WHERE id=?;`, splitCommentID)
+
+	rr := postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, userSess, url.Values{"action": {"Split"}, "comment": {splitCommentID}, "split_title": {"The tangent topic"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Non-moderator was able to split a topic: got %v\n", rr.Code)
+	}
+
+	postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, modSess, url.Values{"action": {"Split"}, "comment": {splitCommentID}, "split_title": {"The tangent topic"}})
+	var newTopicID string
+	var numComments int
+	db.QueryRow(`SELECT id, num_comments FROM topics WHERE title=?;`, "The tangent topic").Scan(&newTopicID, &numComments)
+	if newTopicID == "" || numComments != 2 {
+		t.Fatalf("Split topic has wrong number of comments: got %v\n", numComments)
+	}
+	var pos int
+	db.QueryRow(`SELECT pos FROM comments WHERE id=?;`, splitCommentID).Scan(&pos)
+	if pos != -1 {
+		t.Errorf("Sticky split comment has wrong pos: got %v\n", pos)
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM topicsubscriptions WHERE topicid=?;`, newTopicID).Scan(&count)
+	if count != 1 {
+		t.Errorf("Subscribers were not carried over to the split topic.\n")
+	}
+
+	postForTest(TopicUpdateHandler, "/topics/edit?id="+newTopicID, modSess, url.Values{"action": {"Merge"}, "into": {topicID}})
+	db.QueryRow(`SELECT num_comments FROM topics WHERE id=?;`, topicID).Scan(&numComments)
+	if numComments != 3 {
+		t.Errorf("Merged topic has wrong number of comments: got %v\n", numComments)
+	}
+	db.QueryRow(`SELECT pos FROM comments WHERE content=?;`, "third").Scan(&pos)
+	if pos != 3 {
+		t.Errorf("Merged comment has wrong pos: got %v\n", pos)
+	}
+	rr = getForTest(TopicIndexHandler, "/topics?id="+newTopicID, "")
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/topics?id="+topicID {
+		t.Errorf("Merged topic URL does not redirect: got %v %v\n", rr.Code, rr.Header().Get("Location"))
+	}
+
+	postForTest(TopicUpdateHandler, "/topics/edit?id="+topicID, modSess, url.Values{"action": {"Move"}, "group": {"moveto"}})
+	var movedGroupID string
+	db.QueryRow(`SELECT groupid FROM topics WHERE id=?;`, topicID).Scan(&movedGroupID)
+	if movedGroupID != otherGroupID {
+		t.Errorf("Topic was not moved to the other group.\n")
+	}
+}
+
+func TestAutoCloseTopics(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, created_date, updated_date) VALUES(?, ?, ?, ?);`, "closegroup", "", time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("closegroup")
+	models.UpdateAutoClose(groupID, models.AutoClose{IdleDays: 30, MaxComments: 2, StaleDays: 7})
+	models.CreateUser("necro", "necro12345", "")
+	userID, _ := models.ReadUserIDByName("necro")
+	db.Exec(`UPDATE users SET trust_level=? WHERE id=?;`, models.TrustLevelMember, userID)
+	topicIDs := map[string]string{}
+	for title, activity := range map[string]time.Duration{"Idle topic": 40, "Busy topic": 0, "Stale topic": 10} {
+		date := time.Now().Add(-activity * 24 * time.Hour).Unix()
+		db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+			title, "", userID, groupID, date, date, date)
+		var topicID string
+		db.QueryRow(`SELECT id FROM topics WHERE title=? AND groupid=?;`, title, groupID).Scan(&topicID)
+		topicIDs[title] = topicID
+	}
+	db.Exec(`UPDATE topics SET num_comments=2 WHERE id=?;`, topicIDs["Busy topic"])
+
+	isClosed := func(title string) bool {
+		var closed bool
+		db.QueryRow(`SELECT is_closed FROM topics WHERE id=?;`, topicIDs[title]).Scan(&closed)
+		return closed
+	}
+	models.AutoCloseTopics()
+	if !isClosed("Idle topic") || !isClosed("Busy topic") || isClosed("Stale topic") {
+		t.Errorf("Wrong topics closed: idle %v, busy %v, stale %v\n", isClosed("Idle topic"), isClosed("Busy topic"), isClosed("Stale topic"))
+	}
+	sessionid, err := loginForTest("necro", "necro12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	if body := getForTest(TopicIndexHandler, "/topics?id="+topicIDs["Idle topic"], sessionid).Body.String(); !strings.Contains(body, "30 days without activity") {
+		t.Errorf("Reason for closing not shown.\n")
+	}
+	if rr := postForTest(CommentCreateHandler, "/comments/new?tid="+topicIDs["Idle topic"], sessionid, url.Values{"content": {"Replying anyway"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("Reply posted to an auto-closed topic: got %v\n", rr.Code)
+	}
+	models.ReopenTopic(topicIDs["Busy topic"])
+	models.AutoCloseTopics()
+	if isClosed("Busy topic") {
+		t.Errorf("Reopened topic closed again.\n")
+	}
+
+	numComments := func() int {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM comments WHERE topicid=?;`, topicIDs["Stale topic"]).Scan(&n)
+		return n
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicIDs["Stale topic"], sessionid, url.Values{"content": {"Reviving this"}})
+	if n := numComments(); n != 0 {
+		t.Errorf("Reply to a stale topic not confirmed first.\n")
+	}
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicIDs["Stale topic"], sessionid, url.Values{"content": {"Reviving this"}, "confirm_stale": {"1"}})
+	if n := numComments(); n != 1 {
+		t.Errorf("Confirmed reply to a stale topic not posted.\n")
+	}
+
+	adminSess := mustLoginForTest(t, "admin", "admin12345")
+	postForTest(GroupEditHandler, "/groups/edit", adminSess, url.Values{"id": {groupID}, "action": {"Update"}, "name": {"closegroup"},
+		"post_policy": {models.GroupPolicyOpen}, "autoclose_days": {"ten"}, "autoclose_comments": {"0"}, "stale_reply_days": {"0"}})
+	if ac := models.ReadAutoClose(groupID); ac.IdleDays != 30 || ac.StaleDays != 7 {
+		t.Errorf("Auto-close settings changed by a non-numeric value: got %+v\n", ac)
+	}
+}
+
+func TestThreadedReplies(t *testing.T) {
+	db.Exec(`INSERT INTO groups(name, description, is_threaded, created_date, updated_date) VALUES(?, ?, ?, ?, ?);`, "threadgroup", "", true, time.Now().Unix(), time.Now().Unix())
+	groupID := models.ReadGroupIDByName("threadgroup")
+	adminID, _ := models.ReadUserIDByName("admin")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"A threaded topic", "", adminID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+
+	sess, err := loginForTest("admin", "admin12345")
+	if err != nil {
+		t.Fatalf("Error logging in: %s\n", err)
+	}
+	lastCommentID := func() string {
+		var id string
+		db.QueryRow(`SELECT id FROM comments WHERE topicid=? ORDER BY id DESC LIMIT 1;`, topicID).Scan(&id)
+		return id
+	}
+
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, sess, url.Values{"content": {"first thread"}})
+	rootID := lastCommentID()
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID, sess, url.Values{"content": {"second thread"}})
+	otherID := lastCommentID()
+	postForTest(CommentCreateHandler, "/comments/new?tid="+topicID+"&parent="+rootID, sess, url.Values{"content": {"a reply"}})
+	replyID := lastCommentID()
+	var parentID string
+	db.QueryRow(`SELECT parentid FROM comments WHERE id=?;`, replyID).Scan(&parentID)
+	if parentID != rootID {
+		t.Fatalf("Expected parent %v, got %v\n", rootID, parentID)
+	}
+
+	body := getForTest(TopicIndexHandler, "/topics?id="+topicID, sess).Body.String()
+	if !strings.Contains(body, `<details class="replies" open>`) || strings.Index(body, "a reply") > strings.Index(body, "second thread") {
+		t.Errorf("Reply not shown under its parent.\n")
+	}
+	if !strings.Contains(body, "&parent="+rootID) {
+		t.Errorf("Reply link not shown.\n")
+	}
+
+	id := replyID
+	for i := 0; i < models.MaxThreadDepth; i++ {
+		postForTest(CommentCreateHandler, "/comments/new?tid="+topicID+"&parent="+id, sess, url.Values{"content": {"a deeper reply"}})
+		id = lastCommentID()
+	}
+	for _, c := range models.ReadThreadPages(topicID, 100)[0] {
+		if c.Depth >= models.MaxThreadDepth {
+			t.Errorf("Comment %v nested %v deep.\n", c.ID, c.Depth)
+		}
+	}
+
+	pages := models.ReadThreadPages(topicID, 2)
+	if len(pages) != 2 || pages[0][0].ID != rootID || pages[0][len(pages[0])-1].ID != id || pages[1][0].ID != otherID {
+		t.Errorf("Thread split across pages: %v\n", pages)
+	}
+	if page := models.ThreadPage(topicID, otherID, 2); page != 1 {
+		t.Errorf("Expected comment on page 1, got %v\n", page)
+	}
+
+	models.DeleteComment(rootID)
+	db.Exec(`UPDATE comments SET deleted_date=? WHERE id=?;`, time.Now().Add(-1000*24*time.Hour).Unix(), rootID)
+	models.PurgeTrash()
+	var numComments int
+	db.QueryRow(`SELECT COUNT(*) FROM comments WHERE id=?;`, replyID).Scan(&numComments)
+	if numComments != 1 {
+		t.Errorf("Reply purged with its parent.\n")
+	}
+}
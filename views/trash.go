@@ -0,0 +1,107 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"github.com/s-gv/orangeforum/templates"
+	"html/template"
+	"net/http"
+)
+
+// canSeeTrash reports whether the user may see and restore the deleted posts of the group. The
+// trash of all groups, which also holds the deleted groups, is for superadmins.
+func canSeeTrash(sess Session, groupID string) bool {
+	if groupID == "" {
+		return sess.IsUserSuperAdmin()
+	}
+	return can(sess, models.CapDeleteOthers, groupID)
+}
+
+var TrashHandler = A(func(w http.ResponseWriter, r *http.Request, sess Session) {
+	groupID := r.FormValue("gid")
+	if !canSeeTrash(sess, groupID) {
+		ErrForbiddenHandler(w, r)
+		return
+	}
+	redirectURL := "/trash"
+	if groupID != "" {
+		redirectURL = redirectURL + "?gid=" + groupID
+	}
+
+	if r.Method == "POST" && r.PostFormValue("action") == "Restore" {
+		itemType := r.PostFormValue("type")
+		itemID := r.PostFormValue("id")
+		var itemGroupID string
+		if itemType == "topic" {
+			db.QueryRow(`SELECT groupid FROM topics WHERE id=? AND is_deleted=1;`, itemID).Scan(&itemGroupID)
+		} else if itemType == "comment" {
+			db.QueryRow(`SELECT topics.groupid FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.id=? AND comments.is_deleted=1;`, itemID).Scan(&itemGroupID)
+		} else if itemType == "group" && sess.IsUserSuperAdmin() {
+			db.QueryRow(`SELECT id FROM groups WHERE id=? AND is_closed=1;`, itemID).Scan(&itemGroupID)
+		}
+		if itemGroupID == "" || (groupID != "" && itemGroupID != groupID) || !can(sess, models.CapDeleteOthers, itemGroupID) {
+			ErrForbiddenHandler(w, r)
+			return
+		}
+		if itemType == "topic" {
+			models.UndeleteTopic(itemID)
+			logAction(sess, models.AuditTopicUndelete, "topic", itemID, itemGroupID, "", "")
+		} else if itemType == "comment" {
+			models.UndeleteComment(itemID)
+			logAction(sess, models.AuditCommentUndelete, "comment", itemID, itemGroupID, "", "")
+		} else if itemType == "group" {
+			models.UndeleteGroup(itemID)
+			logAction(sess, models.AuditGroupUndelete, "group", itemID, itemGroupID, "", "")
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	type TrashItem struct {
+		Type        string
+		ID          string
+		TopicID     string
+		Title       string
+		Content     template.HTML
+		GroupName   string
+		OwnerName   string
+		DeletedDate string
+	}
+	var items []TrashItem
+	for _, item := range models.ReadTrash(groupID) {
+		t := TrashItem{
+			Type:        item.Type,
+			ID:          item.ID,
+			TopicID:     item.TopicID,
+			Title:       item.Title,
+			GroupName:   item.GroupName,
+			OwnerName:   item.OwnerName,
+			DeletedDate: timeAgoFromNow(item.DeletedDate),
+		}
+		if item.Type == "group" {
+			t.Content = template.HTML(template.HTMLEscapeString(item.Content))
+		} else {
+			t.Content = formatComment(item.Content)
+		}
+		items = append(items, t)
+	}
+
+	var groupName string
+	db.QueryRow(`SELECT name FROM groups WHERE id=?;`, groupID).Scan(&groupName)
+	retentionDays := models.Config(models.TrashRetentionDays)
+	if retentionDays == "0" {
+		retentionDays = ""
+	}
+
+	templates.Render(w, "trash.html", map[string]interface{}{
+		"Common":        readCommonData(r, sess),
+		"GroupID":       groupID,
+		"GroupName":     groupName,
+		"Items":         items,
+		"RetentionDays": retentionDays,
+	})
+})
@@ -0,0 +1,91 @@
+// Copyright (c) 2017 Sagar Gubbi. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package views
+
+import (
+	"github.com/s-gv/orangeforum/models"
+	"github.com/s-gv/orangeforum/models/db"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	groupID := createGroupForTest("trashgroup")
+	_, modSess := createUserForTest(t, "trashmod")
+	posterID, posterSess := createUserForTest(t, "trashposter")
+	models.CreateGroupMod("trashmod", groupID)
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic with a trashed comment", "", posterID, groupID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var topicID, commentID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, groupID).Scan(&topicID)
+	db.Exec(`INSERT INTO comments(content, image, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"a comment to throw away", "trashed.png", topicID, posterID, 1, time.Now().Unix(), time.Now().Unix())
+	db.QueryRow(`SELECT id FROM comments WHERE topicid=?;`, topicID).Scan(&commentID)
+
+	dataDir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatalf("Error creating data dir: %s\n", err)
+	}
+	defer os.RemoveAll(dataDir)
+	oldDataDir := models.Config(models.DataDir)
+	models.WriteConfig(models.DataDir, dataDir+"/")
+	defer models.WriteConfig(models.DataDir, oldDataDir)
+	ioutil.WriteFile(dataDir+"/trashed.png", []byte("png"), 0644)
+
+	postForTest(CommentUpdateHandler, "/comments/edit?id="+commentID, modSess, url.Values{"action": {"Delete"}})
+	if rr := getForTest(TrashHandler, "/trash?gid="+groupID, posterSess); rr.Code != http.StatusForbidden {
+		t.Errorf("Non-moderator able to see the trash: got %v\n", rr.Code)
+	}
+	if body := getForTest(TrashHandler, "/trash?gid="+groupID, modSess).Body.String(); !strings.Contains(body, "a comment to throw away") {
+		t.Errorf("Deleted comment not in the trash.\n")
+	}
+
+	postForTest(TrashHandler, "/trash?gid="+groupID, modSess, url.Values{"action": {"Restore"}, "type": {"comment"}, "id": {commentID}})
+	var isDeleted bool
+	db.QueryRow(`SELECT is_deleted FROM comments WHERE id=?;`, commentID).Scan(&isDeleted)
+	if isDeleted {
+		t.Errorf("Comment not restored from the trash.\n")
+	}
+
+	models.PurgeTrash()
+	if db.QueryRow(`SELECT is_deleted FROM comments WHERE id=?;`, commentID).Scan(&isDeleted) != nil {
+		t.Fatalf("Restored comment purged.\n")
+	}
+
+	models.DeleteComment(commentID)
+	db.Exec(`UPDATE comments SET deleted_date=? WHERE id=?;`, time.Now().Add(-31*24*time.Hour).Unix(), commentID)
+	models.PurgeTrash()
+	if db.QueryRow(`SELECT is_deleted FROM comments WHERE id=?;`, commentID).Scan(&isDeleted) == nil {
+		t.Errorf("Comment not purged after the retention period.\n")
+	}
+	if _, err := os.Stat(dataDir + "/trashed.png"); !os.IsNotExist(err) {
+		t.Errorf("Image of a purged comment not removed.\n")
+	}
+
+	goneID := createGroupForTest("trashgone")
+	db.Exec(`INSERT INTO topics(title, content, userid, groupid, created_date, updated_date, activity_date) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		"Topic in a trashed group", "", posterID, goneID, time.Now().Unix(), time.Now().Unix(), time.Now().Unix())
+	var goneTopicID string
+	db.QueryRow(`SELECT id FROM topics WHERE groupid=?;`, goneID).Scan(&goneTopicID)
+	db.Exec(`INSERT INTO comments(content, topicid, userid, pos, created_date, updated_date) VALUES(?, ?, ?, ?, ?, ?);`,
+		"a comment in a trashed group", goneTopicID, posterID, 1, time.Now().Unix(), time.Now().Unix())
+	db.Exec(`UPDATE topics SET is_deleted=1, deleted_date=? WHERE id=?;`, time.Now().Add(-31*24*time.Hour).Unix(), topicID)
+	db.Exec(`UPDATE groups SET is_closed=1, deleted_date=? WHERE id=?;`, time.Now().Add(-31*24*time.Hour).Unix(), goneID)
+	models.PurgeTrash()
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM topics WHERE id=? OR id=?;`, topicID, goneTopicID).Scan(&n)
+	if n != 0 {
+		t.Errorf("Topics not purged with their group or after the retention period: got %v\n", n)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM comments WHERE topicid=? OR topicid=?;`, topicID, goneTopicID).Scan(&n)
+	if n != 0 {
+		t.Errorf("Comments of purged topics left behind: got %v\n", n)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -30,6 +31,7 @@ type CommonData struct {
 	UserName          string
 	IsSuperAdmin      bool
 	IsNotification    bool
+	IsMentioned       bool
 	ForumName         string
 	PageTitle         string
 	CurrentURL        template.URL
@@ -37,6 +39,8 @@ type CommonData struct {
 	IsGroupSubAllowed bool
 	IsTopicSubAllowed bool
 	ExtraNotesShort   []ExtraNote
+	ImpersonatorName  string
+	ImpersonationEnd  string
 }
 
 type ExtraNote struct {
@@ -49,21 +53,12 @@ type ExtraNote struct {
 }
 
 var linkRe *regexp.Regexp
-var italicRe *regexp.Regexp
-var boldRe *regexp.Regexp
 var codeRe *regexp.Regexp
-var oldCodeRe *regexp.Regexp
 var quoteRe *regexp.Regexp
-var censorRe *regexp.Regexp
-
-var censored string
 
 func init() {
 	linkRe = regexp.MustCompile("https?://([A-Za-z0-9\\-]+\\.[A-Za-z0-9\\-\\.]+|localhost)(:[0-9]+)?[a-zA-Z0-9@:%_\\+\\.~#?&/=;\\-]*[a-zA-Z0-9@:%_\\+~#?&/=;\\-]")
-	italicRe = regexp.MustCompile("\\*([^\\*\n]+)\\*")
-	boldRe = regexp.MustCompile("\\*\\*([^\\*\n]+)\\*\\*")
 	codeRe = regexp.MustCompile("(?:^|\n)```.*\n(?s:(.+))\n```(?:$|\n)")
-	oldCodeRe = regexp.MustCompile("(?:^|\n)    ([^\n]+)")
 	quoteRe = regexp.MustCompile("((?:^|\n)>*)[ ]*(\\S[^\n]*)")
 }
 
@@ -82,6 +77,14 @@ func ErrForbiddenHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "403 Forbidden", http.StatusForbidden)
 }
 
+func ErrImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Write actions are disabled while viewing the forum as another user.", http.StatusForbidden)
+}
+
+func isImpersonationWrite(r *http.Request, sess Session) bool {
+	return r.Method == "POST" && sess.IsImpersonating() && models.Config(models.AllowImpersonationWrites) == "0"
+}
+
 func UA(handler func(w http.ResponseWriter, r *http.Request, sess Session)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer ErrServerHandler(w, r)
@@ -90,6 +93,10 @@ func UA(handler func(w http.ResponseWriter, r *http.Request, sess Session)) func
 			ErrForbiddenHandler(w, r)
 			return
 		}
+		if isImpersonationWrite(r, sess) {
+			ErrImpersonationHandler(w, r)
+			return
+		}
 		//log.Printf("[INFO] Request: %s\n", r.URL)
 		handler(w, r, sess)
 	}
@@ -111,6 +118,10 @@ func A(handler func(w http.ResponseWriter, r *http.Request, sess Session)) func(
 			http.Redirect(w, r, "/login?next="+url.QueryEscape(redirectURL), http.StatusSeeOther)
 			return
 		}
+		if isImpersonationWrite(r, sess) {
+			ErrImpersonationHandler(w, r)
+			return
+		}
 		if r.Method == "POST" && models.Config(models.ReadOnlyMode) != "0" && !sess.IsUserSuperAdmin() {
 			http.Error(w, "Forum is in read-only mode.", http.StatusForbidden)
 			return
@@ -148,30 +159,19 @@ func validateName(name string) error {
 	return nil
 }
 
-func formatComment(comment string) template.HTML {
-	comment = strings.Replace(comment, "\r", "", -1)
-
-	comment = template.HTMLEscapeString(comment)
-
-	if strings.Contains(comment, "```") {
-		// Newer version -- Markdown style backtick marked code blocks
-		comment = codeRe.ReplaceAllString(comment, "<pre>$1</pre>")
-	} else {
-		// Older version -- SO style 4-space indented code blocks
-		comment = oldCodeRe.ReplaceAllString(comment, "<pre>$1</pre>")
-		comment = strings.Replace(comment, "</pre><pre>", "\n", -1)
+func validateUserName(userName string) error {
+	if len(userName) < 2 || len(userName) > 32 {
+		return errors.New("Username should have 2-32 characters.")
 	}
-
-	comment = strings.Replace(comment, "\n\n", "</p><p>", -1)
-	comment = strings.Replace(comment, "\n", "<br>", -1)
-
-	comment = boldRe.ReplaceAllString(comment, "<b>$1</b>")
-	comment = italicRe.ReplaceAllString(comment, "<em>$1</em>")
-
-	comment = linkRe.ReplaceAllString(comment, "<a href=\"$0\">$0</a>")
-
-	formatted := "<p>" + comment + "</p>"
-	return template.HTML(censor(formatted))
+	if censored := censor("", userName); censored != userName {
+		return errors.New("Fix username: " + censored)
+	}
+	for _, ch := range userName {
+		if (ch < 'A' || ch > 'Z') && (ch < 'a' || ch > 'z') && ch != '_' && (ch < '0' || ch > '9') {
+			return errors.New("Username can contain only alphabets, numbers, and underscore.")
+		}
+	}
+	return nil
 }
 
 func formatReply(quotedUser string, quoteContent string) string {
@@ -185,29 +185,26 @@ func formatReply(quotedUser string, quoteContent string) string {
 	return quoteContent
 }
 
-func censor(content string) string {
-	cWords := models.Config(models.CensoredWords)
-	if cWords != censored {
-		censored = cWords
-		cWordList := strings.Split(censored, ",")
-		if len(cWordList) > 0 {
-			r := "(?i:"
-			for i, w := range cWordList {
-				r = r + strings.TrimSpace(w)
-				if i < len(cWordList)-1 {
-					r = r + "|"
-				}
-			}
-			r = r + ")"
-			censorRe = regexp.MustCompile(r)
-		} else {
-			censorRe = nil
-		}
+// censor applies the mask and replace rules of the group, and the site-wide rules, to the content.
+func censor(groupID string, content string) string {
+	return models.ApplyContentRules(groupID, content).Content
+}
+
+// contentRulesMsg returns why a post is refused by the content rules, or "". When a hold rule
+// matches, new posts are to be held for approval unless the user approves posts, and edits are
+// refused.
+func contentRulesMsg(sess Session, groupID string, content string, isEdit bool) (string, bool) {
+	res := models.ApplyContentRules(groupID, content)
+	if rejected := res.Rejection(); rejected != "" {
+		return "Your post contains \"" + rejected + "\", which is not allowed here.", false
 	}
-	if censorRe != nil {
-		return censorRe.ReplaceAllString(content, "****")
+	if held := res.Hold(); held != "" && !can(sess, models.CapApprovePosts, groupID) {
+		if isEdit {
+			return "Your post contains \"" + held + "\", which needs a moderator's review. It cannot be added in an edit.", false
+		}
+		return "", true
 	}
-	return content
+	return "", false
 }
 
 func saveImage(r *http.Request) string {
@@ -238,6 +235,94 @@ func saveImage(r *http.Request) string {
 	return imageName
 }
 
+// clientAddrs returns the addresses a request may be identified by for network bans:
+// the remote IP and, when served over i2p, the client destination set by the tunnel.
+func clientAddrs(r *http.Request) []string {
+	var addrs []string
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		addrs = append(addrs, host)
+	} else if r.RemoteAddr != "" {
+		addrs = append(addrs, r.RemoteAddr)
+	}
+	for _, header := range []string{"X-I2P-DestB32", "X-I2P-DestHash", "X-I2P-DestB64"} {
+		if dest := r.Header.Get(header); dest != "" {
+			addrs = append(addrs, dest)
+		}
+	}
+	return addrs
+}
+
+func banMsg(ban models.Ban) string {
+	msg := "You are banned"
+	if !ban.IsPermanent() {
+		msg = msg + " until " + ban.ExpiryDate.Format("2006-01-02 15:04")
+	}
+	msg = msg + "."
+	if ban.Reason != "" {
+		msg = msg + " Reason: " + ban.Reason
+	}
+	return msg
+}
+
+// can reports whether the session's user has the capability in the group.
+func can(sess Session, capability string, groupID string) bool {
+	return models.Can(sess.UserID, capability, groupID)
+}
+
+// canShadowBan reports whether the session's user may see and change whether a user is
+// shadow-banned. Superadmins may for everyone, mods for users other than superadmins who posted in a
+// group they moderate.
+func canShadowBan(sess Session, userID string) bool {
+	if !sess.UserID.Valid {
+		return false
+	}
+	if sess.IsUserSuperAdmin() {
+		return true
+	}
+	var isSuperAdmin bool
+	if db.QueryRow(`SELECT is_superadmin FROM users WHERE id=?;`, userID).Scan(&isSuperAdmin) != nil || isSuperAdmin {
+		return false
+	}
+	for _, groupID := range models.ReadPostedGroupIDs(userID) {
+		if can(sess, models.CapApprovePosts, groupID) || can(sess, models.CapDeleteOthers, groupID) {
+			return true
+		}
+	}
+	return false
+}
+
+// canPostTopic applies the group's posting policy on top of the post_topic capability.
+func canPostTopic(sess Session, groupID string) bool {
+	policy := models.ReadGroupPolicy(groupID)
+	if policy == models.GroupPolicyReadOnly {
+		return false
+	}
+	if policy == models.GroupPolicyAnnouncement && !can(sess, models.CapPostAnnouncement, groupID) {
+		return false
+	}
+	return can(sess, models.CapPostTopic, groupID)
+}
+
+// canPostComment applies the group's posting policy on top of the post_comment capability.
+func canPostComment(sess Session, groupID string) bool {
+	return models.ReadGroupPolicy(groupID) != models.GroupPolicyReadOnly && can(sess, models.CapPostComment, groupID)
+}
+
+func groupBanMsg(ban models.GroupBan, groupName string) string {
+	msg := "You are banned from " + groupName
+	if ban.IsMute() {
+		msg = "You are muted in " + groupName
+	}
+	if !ban.IsPermanent() {
+		msg = msg + " until " + ban.ExpiryDate.Format("2006-01-02 15:04")
+	}
+	msg = msg + "."
+	if ban.Reason != "" {
+		msg = msg + " Reason: " + ban.Reason
+	}
+	return msg
+}
+
 func validatePasswd(passwd string, passwdConfirm string) error {
 	if len(passwd) < 8 || len(passwd) > 40 {
 		return errors.New("Password should have 8-40 characters.")
@@ -279,6 +364,13 @@ func readCommonData(r *http.Request, sess Session) CommonData {
 		}
 	}
 
+	impersonatorName := ""
+	impersonationEnd := ""
+	if sess.IsImpersonating() {
+		db.QueryRow(`SELECT username FROM users WHERE id=?;`, sess.ImpersonatorID).Scan(&impersonatorName)
+		impersonationEnd = sess.ImpersonateDate.Add(maxImpersonationLife).Format("15:04")
+	}
+
 	rows := db.Query(`SELECT id, name FROM extranotes;`)
 	var extraNotes []ExtraNote
 	for rows.Next() {
@@ -293,11 +385,130 @@ func readCommonData(r *http.Request, sess Session) CommonData {
 		UserName:          userName,
 		IsSuperAdmin:      isSuperAdmin,
 		IsNotification:    pmNotification,
+		IsMentioned:       sess.UserID.Valid && models.HasUnreadMentions(sess.UserID.Int64),
 		ForumName:         models.Config(models.ForumName),
 		CurrentURL:        template.URL(url.QueryEscape(currentURL)),
 		IsGroupSubAllowed: models.Config(models.AllowGroupSubscription) != "0",
 		IsTopicSubAllowed: models.Config(models.AllowTopicSubscription) != "0",
 		BodyAppendage:     models.Config(models.BodyAppendage),
 		ExtraNotesShort:   extraNotes,
+		ImpersonatorName:  impersonatorName,
+		ImpersonationEnd:  impersonationEnd,
+	}
+}
+
+// trustLevel returns the trust level of the session's user.
+func trustLevel(sess Session) int {
+	if !sess.UserID.Valid {
+		return models.TrustLevelNew
+	}
+	return models.ReadTrustLevel(sess.UserID.Int64)
+}
+
+// needsApproval reports whether new posts by a user with the given trust level in the group are
+// held for a mod to approve.
+func needsApproval(sess Session, groupID string, trust int) bool {
+	level := models.ReadPremodLevel(groupID)
+	return level != models.PremodOff && !can(sess, models.CapApprovePosts, groupID) && trust < level
+}
+
+// isHeldAsSpam reports whether a post with the given spam score goes to the approval queue.
+func isHeldAsSpam(sess Session, groupID string, spamScore int) bool {
+	return models.IsLikelySpam(spamScore) && !can(sess, models.CapApprovePosts, groupID)
+}
+
+// linkLimitMsg returns an error message if the content has more links than the trust level allows, or "".
+func linkLimitMsg(trust int, content string) string {
+	if trust > models.TrustLevelNew {
+		return ""
+	}
+	maxLinks := models.ConfigInt(models.NewUserMaxLinks)
+	if len(linkRe.FindAllString(content, -1)) > maxLinks {
+		return "New users can post at most " + strconv.Itoa(maxLinks) + " links."
+	}
+	return ""
+}
+
+// rateLimitMsg returns a message if numNew more topics, comments, or messages (kind) would take
+// the user over the site-wide hourly limit. Superadmins are not limited.
+func rateLimitMsg(sess Session, kind string, numNew int) string {
+	if sess.IsUserSuperAdmin() {
+		return ""
+	}
+	key, query, noun := models.TopicsPerHour, `SELECT COUNT(*) FROM topics WHERE userid=? AND created_date>?;`, "topics"
+	if kind == "comment" {
+		key, query, noun = models.CommentsPerHour, `SELECT COUNT(*) FROM comments WHERE userid=? AND created_date>?;`, "comments"
+	} else if kind == "message" {
+		key, query, noun = models.MessagesPerHour, `SELECT COUNT(*) FROM messages WHERE fromid=? AND created_date>?;`, "private messages"
+	}
+	limit := models.ConfigInt(key)
+	if limit == 0 {
+		return ""
+	}
+	var num int
+	db.QueryRow(query, sess.UserID, time.Now().Add(-time.Hour).Unix()).Scan(&num)
+	if num+numNew > limit {
+		return "You can post at most " + strconv.Itoa(limit) + " " + noun + " an hour. Please try again later."
+	}
+	return ""
+}
+
+// slowModeMsg returns a message if the group is in slow mode and the user commented in it too
+// recently. Users who can close topics in the group are not slowed down.
+func slowModeMsg(sess Session, groupID string) string {
+	var interval int64
+	db.QueryRow(`SELECT slow_mode_secs FROM groups WHERE id=?;`, groupID).Scan(&interval)
+	if interval <= 0 || can(sess, models.CapClose, groupID) {
+		return ""
+	}
+	var lastDate int64
+	db.QueryRow(`SELECT comments.created_date FROM comments INNER JOIN topics ON comments.topicid=topics.id WHERE comments.userid=? AND topics.groupid=? ORDER BY comments.created_date DESC LIMIT 1;`,
+		sess.UserID, groupID).Scan(&lastDate)
+	if wait := lastDate + interval - time.Now().Unix(); wait > 0 {
+		return "Slow mode is on in this group. You can comment again in " + slowModeStr(wait) + "."
+	}
+	return ""
+}
+
+// slowModeStr describes an interval in seconds the way slow mode settings are shown.
+func slowModeStr(secs int64) string {
+	n, unit := secs, "second"
+	if secs >= 3600 && secs%3600 == 0 {
+		n, unit = secs/3600, "hour"
+	} else if secs >= 60 && secs%60 == 0 {
+		n, unit = secs/60, "minute"
+	}
+	if n != 1 {
+		unit = unit + "s"
+	}
+	return strconv.FormatInt(n, 10) + " " + unit
+}
+
+// logAction records a moderation action in the audit log. Actions taken while viewing the forum as
+// another user are recorded against the superadmin doing so.
+func logAction(sess Session, action string, targetType string, targetID string, groupID string, before string, after string) {
+	actorID := sess.UserID.Int64
+	if sess.IsImpersonating() {
+		actorID = sess.ImpersonatorID.Int64
+	}
+	models.LogAction(actorID, action, targetType, targetID, groupID, before, after)
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// banDesc describes a ban for the audit log. Zero days means the ban is permanent.
+func banDesc(reason string, days int) string {
+	duration := "forever"
+	if days > 0 {
+		duration = strconv.Itoa(days) + " days"
+	}
+	if reason == "" {
+		return duration
 	}
+	return duration + ": " + reason
 }